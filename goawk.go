@@ -30,15 +30,18 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 
 	"github.com/nuvolaris/goawk/internal/compiler"
 	"github.com/nuvolaris/goawk/internal/cover"
+	"github.com/nuvolaris/goawk/internal/debugger"
 	"github.com/nuvolaris/goawk/internal/parseutil"
 	"github.com/nuvolaris/goawk/internal/resolver"
 	"github.com/nuvolaris/goawk/interp"
@@ -49,20 +52,27 @@ import (
 const (
 	version    = "v1.21.0"
 	copyright  = "GoAWK " + version + " - Copyright (c) 2022 Ben Hoyt"
-	shortUsage = "usage: goawk [-F fs] [-v var=value] [-f progfile | 'prog'] [file ...]"
+	shortUsage = "usage: goawk [-F fs] [-v var=value] [-f progfile | -c compiled | 'prog'] [file ...]"
 	longUsage  = `Standard AWK arguments:
   -F separator      field separator (default " ")
   -f progfile       load AWK source from progfile (multiple allowed)
   -v var=value      variable assignment (multiple allowed)
 
 Additional GoAWK features:
+  -c compiled       load program compiled by -compile instead of AWK source
+  -compile fn       compile program to file fn (loaded with -c) and exit
   -E progfile       load program, treat as last option, disable var=value args
   -H                parse header row and enable @"field" in CSV input mode
+  -H=name,...       use given field names (no header row) in CSV input mode
   -h, --help        show this help message
+  -I                integer mode: exact 64-bit integer arithmetic
+  -M                arbitrary-precision arithmetic (see PREC and ROUNDMODE)
+  -j workers        process input records in parallel using this many workers
   -i mode           parse input into fields using CSV format (ignore FS and RS)
-                    'csv|tsv [separator=<char>] [comment=<char>] [header]'
+                    'csv|tsv [separator=<char>] [comment=<char>] [header[=name,...]]'
+                    or JSON Lines format: 'jsonl'
   -o mode           use CSV output for print with args (ignore OFS and ORS)
-                    'csv|tsv [separator=<char>]'
+                    'csv|tsv [separator=<char>]' or JSON Lines output: 'jsonl'
   -version          show GoAWK version and exit
 
 GoAWK debugging arguments:
@@ -71,9 +81,11 @@ GoAWK debugging arguments:
   -coverprofile fn  write coverage profile to file
   -cpuprofile fn    write CPU profile to file
   -d                print parsed syntax tree to stdout and exit
+  -debug            run program in interactive debugger (commands from terminal)
   -da               print VM assembly instructions to stdout and exit
   -dt               print variable type information to stdout and exit
   -memprofile fn    write memory profile to file
+  -noopt            disable compiler optimizations (for example, with -da)
 `
 )
 
@@ -82,16 +94,24 @@ func AwkMain() error {
 	// "flag" package, so we can support flags with no space between
 	// flag and argument, like '-F:' (allowed by POSIX)
 	var progFiles []string
+	compiledFile := ""
+	compileFile := ""
 	var vars []string
 	fieldSep := " "
 	cpuProfile := ""
 	debug := false
 	debugAsm := false
 	debugTypes := false
+	debugREPL := false
+	noOptimize := false
 	memProfile := ""
 	inputMode := ""
 	outputMode := ""
 	header := false
+	headerNames := ""
+	integerMode := false
+	precision := 0
+	parallel := 0
 	noArgVars := false
 	coverMode := cover.ModeUnspecified
 	coverProfile := ""
@@ -125,6 +145,18 @@ argsLoop:
 			coverProfile = os.Args[i]
 		case "-coverappend":
 			coverAppend = true
+		case "-c":
+			if i+1 >= len(os.Args) {
+				return errorExitf("flag needs an argument: -c")
+			}
+			i++
+			compiledFile = os.Args[i]
+		case "-compile":
+			if i+1 >= len(os.Args) {
+				return errorExitf("flag needs an argument: -compile")
+			}
+			i++
+			compileFile = os.Args[i]
 		case "-E":
 			if i+1 >= len(os.Args) {
 				return errorExitf("flag needs an argument: -E")
@@ -162,10 +194,28 @@ argsLoop:
 			debug = true
 		case "-da":
 			debugAsm = true
+		case "-debug":
+			debugREPL = true
 		case "-dt":
 			debugTypes = true
+		case "-noopt":
+			noOptimize = true
 		case "-H":
 			header = true
+		case "-I":
+			integerMode = true
+		case "-M":
+			precision = 53
+		case "-j":
+			if i+1 >= len(os.Args) {
+				return errorExitf("flag needs an argument: -j")
+			}
+			i++
+			n, err := strconv.Atoi(os.Args[i])
+			if err != nil || n < 1 {
+				return errorExitf("invalid -j value %q: must be a positive number", os.Args[i])
+			}
+			parallel = n
 		case "-h", "--help":
 			fmt.Printf("%s\n\n%s\n\n%s", copyright, shortUsage, longUsage)
 			return nil
@@ -199,14 +249,25 @@ argsLoop:
 				break argsLoop
 			case strings.HasPrefix(arg, "-F"):
 				fieldSep = arg[2:]
+			case strings.HasPrefix(arg, "-H="):
+				header = true
+				headerNames = arg[3:]
 			case strings.HasPrefix(arg, "-f"):
 				progFiles = append(progFiles, arg[2:])
 			case strings.HasPrefix(arg, "-i"):
 				inputMode = arg[2:]
+			case strings.HasPrefix(arg, "-j"):
+				n, err := strconv.Atoi(arg[2:])
+				if err != nil || n < 1 {
+					return errorExitf("invalid -j value %q: must be a positive number", arg[2:])
+				}
+				parallel = n
 			case strings.HasPrefix(arg, "-o"):
 				outputMode = arg[2:]
 			case strings.HasPrefix(arg, "-v"):
 				vars = append(vars, arg[2:])
+			case strings.HasPrefix(arg, "-compile="):
+				compileFile = arg[len("-compile="):]
 			case strings.HasPrefix(arg, "-cpuprofile="):
 				cpuProfile = arg[len("-cpuprofile="):]
 			case strings.HasPrefix(arg, "-memprofile="):
@@ -227,8 +288,21 @@ argsLoop:
 	// Any remaining args are program and input files
 	args := os.Args[i:]
 
+	if compiledFile != "" {
+		switch {
+		case len(progFiles) > 0:
+			return errorExitf("-c not allowed together with -f or -E")
+		case compileFile != "":
+			return errorExitf("-c not allowed together with -compile")
+		case debug || debugREPL || coverMode != cover.ModeUnspecified:
+			return errorExitf("-c not allowed together with -d, -debug, or coverage")
+		}
+	}
+
 	fileReader := &parseutil.FileReader{}
-	if len(progFiles) > 0 {
+	if compiledFile != "" {
+		// Program is loaded below; all args are input files
+	} else if len(progFiles) > 0 {
 		// Read source: the concatenation of all source files specified
 		progFiles = expandWildcardsOnWindows(progFiles)
 		for _, progFile := range progFiles {
@@ -263,10 +337,25 @@ argsLoop:
 
 	// Parse source code and setup interpreter
 	parserConfig := &parser.ParserConfig{
-		DebugTypes:  debugTypes,
-		DebugWriter: os.Stdout,
+		DebugTypes:     debugTypes,
+		DebugWriter:    os.Stdout,
+		IncludeOSFiles: true,
+		IncludeSources: fileReader,
+		NoOptimize:     noOptimize,
+	}
+	if awkPath := os.Getenv("AWKPATH"); awkPath != "" {
+		parserConfig.IncludePaths = filepath.SplitList(awkPath)
+	}
+	var prog *parser.Program
+	var err error
+	if compiledFile != "" {
+		prog, err = loadCompiled(compiledFile)
+		if err != nil {
+			return errorExit(err)
+		}
+	} else {
+		prog, err = parser.ParseProgram(fileReader.Source(), parserConfig)
 	}
-	prog, err := parser.ParseProgram(fileReader.Source(), parserConfig)
 	if err != nil {
 		if err, ok := err.(*parser.ParseError); ok {
 			name, line := fileReader.FileLine(err.Position.Line)
@@ -290,7 +379,8 @@ argsLoop:
 			DebugWriter: parserConfig.DebugWriter})
 
 		// re-compile it
-		prog.Compiled, err = compiler.Compile(&prog.ResolvedProgram)
+		prog.Compiled, err = compiler.Compile(&prog.ResolvedProgram, &compiler.Config{
+			NoOptimize: parserConfig.NoOptimize})
 		if err != nil {
 			return errorExitf("%s", err)
 		}
@@ -311,11 +401,27 @@ argsLoop:
 		return nil
 	}
 
+	if compileFile != "" {
+		data, err := prog.MarshalBinary()
+		if err != nil {
+			return errorExitf("could not compile program: %v", err)
+		}
+		err = ioutil.WriteFile(compileFile, data, 0644)
+		if err != nil {
+			return errorExit(err)
+		}
+		return nil
+	}
+
 	if header {
 		if inputMode == "" {
 			return errorExitf("-H only allowed together with -i")
 		}
-		inputMode += " header"
+		if headerNames != "" {
+			inputMode += " header=" + headerNames
+		} else {
+			inputMode += " header"
+		}
 	}
 
 	// Don't buffer output if stdout is a terminal (default output writer when
@@ -327,10 +433,13 @@ argsLoop:
 	}
 
 	config := &interp.Config{
-		Argv0:     filepath.Base(os.Args[0]),
-		Args:      expandWildcardsOnWindows(args),
-		NoArgVars: noArgVars,
-		Output:    stdout,
+		Argv0:       filepath.Base(os.Args[0]),
+		Args:        expandWildcardsOnWindows(args),
+		NoArgVars:   noArgVars,
+		Output:      stdout,
+		IntegerMode: integerMode,
+		Precision:   precision,
+		Parallel:    parallel,
 		Vars: []string{
 			"FS", fieldSep,
 			"INPUTMODE", inputMode,
@@ -351,6 +460,18 @@ argsLoop:
 		config.Vars = append(config.Vars, name, value)
 	}
 
+	if debugREPL {
+		// Read debugger commands from the terminal rather than stdin, so
+		// that program input can still be piped in.
+		tty, err := openTerminal()
+		if err != nil {
+			return errorExitf("-debug requires a terminal: %v", err)
+		}
+		defer tty.Close()
+		repl := debugger.New(tty, os.Stderr, fileReader)
+		config.Debugger = repl.Debugger()
+	}
+
 	if cpuProfile != "" {
 		f, err := os.Create(cpuProfile)
 		if err != nil {
@@ -364,7 +485,9 @@ argsLoop:
 	// Run the program!
 	interpreter, err := interp.New(prog)
 	status, err := interpreter.Execute(config)
-
+	if err == interp.ErrDebugQuit {
+		return nil
+	}
 	if err != nil {
 		return errorExit(err)
 	}
@@ -430,10 +553,32 @@ func errorExit(err error) error {
 	return errorExitf("%s", err)
 }
 
+// Load a program compiled by -compile.
+func loadCompiled(path string) (*parser.Program, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	prog := &parser.Program{}
+	err = prog.UnmarshalBinary(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return prog, nil
+}
+
 func errorExitf(format string, args ...interface{}) error {
 	return fmt.Errorf(format+"\n", args...)
 }
 
+// Open the controlling terminal for reading.
+func openTerminal() (*os.File, error) {
+	if runtime.GOOS == "windows" {
+		return os.Open("CONIN$")
+	}
+	return os.Open("/dev/tty")
+}
+
 func expandWildcardsOnWindows(args []string) []string {
 	if runtime.GOOS != "windows" {
 		return args
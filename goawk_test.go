@@ -411,7 +411,7 @@ func TestCommandLine(t *testing.T) {
 		{[]string{"-E", "testdata/awc.awk", "foo=bar"}, "", "", `file "foo=bar" not found`},
 
 		// Error handling
-		{[]string{}, "", "", "usage: goawk [-F fs] [-v var=value] [-f progfile | 'prog'] [file ...]"},
+		{[]string{}, "", "", "usage: goawk [-F fs] [-v var=value] [-f progfile | -c compiled | 'prog'] [file ...]"},
 		{[]string{"-F"}, "", "", "flag needs an argument: -F"},
 		{[]string{"-f"}, "", "", "flag needs an argument: -f"},
 		{[]string{"-v"}, "", "", "flag needs an argument: -v"},
@@ -437,6 +437,11 @@ func TestCommandLine(t *testing.T) {
 			"", "", "testdata/parseerror/bad.awk:2:3: expected expression instead of <newline>\nx*\n  ^"},
 		{[]string{"-f", "testdata/parseerror/good.awk", "-f", "-", "-f", "testdata/parseerror/bad.awk"},
 			"`", "", "<stdin>:1:1: unexpected char\n`\n^"},
+		{[]string{"BEGIN {}\n@include \"testdata/parseerror/bad.awk\""},
+			"", "", "testdata/parseerror/bad.awk:2:3: expected expression instead of <newline>\nx*\n  ^"},
+
+		// @include directive
+		{[]string{"@include \"testdata/parseerror/good.awk\"\nEND { print NR }"}, "a b\nc d", "a\nc\n2\n", ""},
 	}
 	for _, test := range tests {
 		testName := strings.Join(test.args, " ")
@@ -610,6 +615,13 @@ func TestGoAWKSpecificOptions(t *testing.T) {
 		{[]string{"-oxyz", `{}`}, "", "", "invalid output mode \"xyz\"\n"},
 		{[]string{"-H", `{}`}, "", "", "-H only allowed together with -i\n"},
 
+		// Parallel mode
+		{[]string{"-j", "2", `{ print FILENAME ":" FNR "/" NR ": " $0; n++ } END { print n }`, "testdata/g.1", "testdata/g.4"}, "",
+			"testdata/g.1:1/1: ONE\ntestdata/g.4:1/2: FOUR a\ntestdata/g.4:2/3: FOUR b\n3\n", ""},
+		{[]string{"-j2", "-v", "RS=;", `{ n++ } END { print n }`, "-", "testdata/g.1"}, "a;b;c", "4\n", ""},
+		{[]string{"-j", "x", `{}`}, "", "", "invalid -j value \"x\": must be a positive number\n"},
+		{[]string{"-j0", `{}`}, "", "", "invalid -j value \"0\": must be a positive number\n"},
+
 		// Debug options (don't test -dt as its output is not stable)
 		{[]string{"-d", `$1 { print 1+1 }`}, "", `
 $1 {
@@ -620,6 +632,15 @@ $1 {
         // pattern
 0000    FieldInt 1
 
+        // { body }
+0000    Num 2 (0)
+0002    Print 1
+
+`[1:], ""},
+		{[]string{"-noopt", "-da", `$1 { print 1+1 }`}, "", `
+        // pattern
+0000    FieldInt 1
+
         // { body }
 0000    Num 1 (0)
 0002    Num 1 (0)
@@ -635,10 +656,18 @@ $1 {
 0000    FieldInt 1
 
         // { body }
-0000    Num 1 (0)
-0002    Num 1 (0)
-0004    Add
-0005    Print 1
+0000    Num 2 (0)
+0002    Print 1
+
+`[1:], ""},
+		{[]string{"-da", `$1 == "x" { while (1) if ($2 != "y") break }`}, "", `
+        // pattern
+0000    FieldIntEqualsStr 1 "x" (0)
+
+        // { body }
+0000    JumpFieldIntEqualsStr 2 "y" (1) 0x0000
+0004    Jump 0x0008
+0006    Jump 0x0000
 
 `[1:], ""},
 	}
@@ -661,6 +690,61 @@ $1 {
 	}
 }
 
+func TestCompile(t *testing.T) {
+	tempFile, err := ioutil.TempFile("", "goawk_compile_*.awkc")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	err = tempFile.Close()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	compiled := tempFile.Name()
+	defer os.Remove(compiled)
+
+	src := `function f(s) { return toupper(s) } /[oO]/ { n++; print f($2) } END { print n, x }`
+	stdout, stderr, err := runGoAWK([]string{"-compile", compiled, src}, "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v (%q)", err, stderr)
+	}
+	if stdout != "" {
+		t.Fatalf("expected no output from -compile, got %q", stdout)
+	}
+
+	tests := []struct {
+		args   []string
+		input  string
+		output string
+		error  string
+	}{
+		{[]string{"-c", compiled}, "one a\ntwo b\nthree c\n", "A\nB\n2 \n", ""},
+		{[]string{"-v", "x=y", "-c", compiled, "testdata/g.1", "x=z", "testdata/g.4"}, "", "\nA\nB\n3 z\n", ""},
+		{[]string{"-c", compiled, "-f", "testdata/g.1"}, "", "", "-c not allowed together with -f or -E\n"},
+		{[]string{"-c", compiled, "-compile", compiled}, "", "", "-c not allowed together with -compile\n"},
+		{[]string{"-c", compiled, "-d"}, "", "", "-c not allowed together with -d, -debug, or coverage\n"},
+		{[]string{"-c", "testdata/g.1"}, "", "", "testdata/g.1: not a compiled GoAWK program\n"},
+		{[]string{"-c"}, "", "", "flag needs an argument: -c\n"},
+	}
+	for _, test := range tests {
+		testName := strings.Join(test.args[1:], " ")
+		t.Run(testName, func(t *testing.T) {
+			stdout, stderr, err := runGoAWK(test.args, test.input)
+			if err != nil {
+				if test.error == "" {
+					t.Fatalf("expected no error, got %v (%q)", err, stderr)
+				} else if stderr != test.error {
+					t.Fatalf("expected error message %q, got %q", test.error, stderr)
+				}
+			} else if test.error != "" {
+				t.Fatalf("expected error %q, got none", test.error)
+			}
+			if stdout != test.output {
+				t.Fatalf("output differs, got:\n%s\nexpected:\n%s", stdout, test.output)
+			}
+		})
+	}
+}
+
 func TestMultipleCSVFiles(t *testing.T) {
 	// Ensure CSV handling works across multiple files with different headers (field names).
 	src := `
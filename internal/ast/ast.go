@@ -24,6 +24,10 @@ type ResolvedProgram struct {
 	Program
 	Scalars map[string]int
 	Arrays  map[string]int
+
+	// Native functions the program calls, indexed like UserCallExpr.Index
+	// (entries for functions that aren't called are zero)
+	NativeFuncs []NativeFunc
 }
 
 // String returns an indented, pretty-printed version of the parsed
@@ -201,16 +205,26 @@ func (e *BinaryExpr) String() string {
 
 // ArrayExpr is an array reference. Not really a stand-alone
 // expression, except as an argument to split() or a user function
-// call.
+// call. If Subscripts is non-empty, it refers to a subarray, for
+// example a[i][j] has Name "a" and Subscripts [[i], [j]].
 type ArrayExpr struct {
-	Scope VarScope
-	Index int
-	Name  string
-	Pos   Position
+	Scope      VarScope
+	Index      int
+	Name       string
+	Pos        Position
+	Subscripts [][]Expr
 }
 
 func (e *ArrayExpr) String() string {
-	return e.Name
+	s := e.Name
+	for _, index := range e.Subscripts {
+		indices := make([]string, len(index))
+		for i, expr := range index {
+			indices[i] = expr.String()
+		}
+		s += "[" + strings.Join(indices, ", ") + "]"
+	}
+	return s
 }
 
 // InExpr is an expression like (index in array).
@@ -244,6 +258,7 @@ func (e *CondExpr) String() string {
 // NumExpr is a literal number like 1234.
 type NumExpr struct {
 	Value float64
+	Text  string // source text, if from the source code (for the exact modes)
 }
 
 func (e *NumExpr) String() string {
@@ -424,7 +439,7 @@ func (e *GetlineExpr) String() string {
 // operation, or as the third argument to sub or gsub).
 func IsLValue(expr Expr) bool {
 	switch expr.(type) {
-	case *VarExpr, *IndexExpr, *FieldExpr:
+	case *VarExpr, *IndexExpr, *FieldExpr, *NamedFieldExpr:
 		return true
 	default:
 		return false
@@ -718,6 +733,15 @@ type Function struct {
 	Pos    Position
 }
 
+// NativeFunc records how the resolver typed a native Go function the
+// program calls, so that it can be called with the same Go signature.
+type NativeFunc struct {
+	Name        string
+	Arrays      []bool // whether each parameter passed from AWK is an array
+	Variadic    bool
+	ArrayResult bool
+}
+
 func (f *Function) String() string {
 	return "function " + f.Name + "(" + strings.Join(f.Params, ", ") + ") {\n" +
 		f.Body.String() + "}"
@@ -737,7 +761,7 @@ func VarRef(name string, pos Position) *VarExpr {
 
 // ArrayRef is a constructor for *ArrayExpr
 func ArrayRef(name string, pos Position) *ArrayExpr {
-	return &ArrayExpr{resolvedLater, resolvedLater, name, pos}
+	return &ArrayExpr{resolvedLater, resolvedLater, name, pos, nil}
 }
 
 // UserCall is a constructor for *UserCallExpr
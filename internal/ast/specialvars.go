@@ -10,8 +10,10 @@ const (
 	V_ILLEGAL = iota
 	V_ARGC
 	V_CONVFMT
+	V_FIELDWIDTHS
 	V_FILENAME
 	V_FNR
+	V_FPAT
 	V_FS
 	V_INPUTMODE
 	V_NF
@@ -20,7 +22,9 @@ const (
 	V_OFS
 	V_ORS
 	V_OUTPUTMODE
+	V_PREC
 	V_RLENGTH
+	V_ROUNDMODE
 	V_RS
 	V_RSTART
 	V_RT
@@ -30,25 +34,33 @@ const (
 )
 
 var specialVars = map[string]int{
-	"ARGC":       V_ARGC,
-	"CONVFMT":    V_CONVFMT,
-	"FILENAME":   V_FILENAME,
-	"FNR":        V_FNR,
-	"FS":         V_FS,
-	"INPUTMODE":  V_INPUTMODE,
-	"NF":         V_NF,
-	"NR":         V_NR,
-	"OFMT":       V_OFMT,
-	"OFS":        V_OFS,
-	"ORS":        V_ORS,
-	"OUTPUTMODE": V_OUTPUTMODE,
-	"RLENGTH":    V_RLENGTH,
-	"RS":         V_RS,
-	"RSTART":     V_RSTART,
-	"RT":         V_RT,
-	"SUBSEP":     V_SUBSEP,
+	"ARGC":        V_ARGC,
+	"CONVFMT":     V_CONVFMT,
+	"FIELDWIDTHS": V_FIELDWIDTHS,
+	"FILENAME":    V_FILENAME,
+	"FNR":         V_FNR,
+	"FPAT":        V_FPAT,
+	"FS":          V_FS,
+	"INPUTMODE":   V_INPUTMODE,
+	"NF":          V_NF,
+	"NR":          V_NR,
+	"OFMT":        V_OFMT,
+	"OFS":         V_OFS,
+	"ORS":         V_ORS,
+	"OUTPUTMODE":  V_OUTPUTMODE,
+	"PREC":        V_PREC,
+	"RLENGTH":     V_RLENGTH,
+	"ROUNDMODE":   V_ROUNDMODE,
+	"RS":          V_RS,
+	"RSTART":      V_RSTART,
+	"RT":          V_RT,
+	"SUBSEP":      V_SUBSEP,
 }
 
+// SpecialArrays lists the names of the built-in arrays. The resolver always
+// defines these, as the interpreter relies on them being present.
+var SpecialArrays = []string{"ARGV", "ENVIRON", "FIELDS", "OFIELDS", "PROCINFO"}
+
 // SpecialVarIndex returns the "index" of the special variable, or 0
 // if it's not a special variable.
 func SpecialVarIndex(name string) int {
@@ -64,10 +76,14 @@ func SpecialVarName(index int) string {
 		return "ARGC"
 	case V_CONVFMT:
 		return "CONVFMT"
+	case V_FIELDWIDTHS:
+		return "FIELDWIDTHS"
 	case V_FILENAME:
 		return "FILENAME"
 	case V_FNR:
 		return "FNR"
+	case V_FPAT:
+		return "FPAT"
 	case V_FS:
 		return "FS"
 	case V_INPUTMODE:
@@ -84,8 +100,12 @@ func SpecialVarName(index int) string {
 		return "ORS"
 	case V_OUTPUTMODE:
 		return "OUTPUTMODE"
+	case V_PREC:
+		return "PREC"
 	case V_RLENGTH:
 		return "RLENGTH"
+	case V_ROUNDMODE:
+		return "ROUNDMODE"
 	case V_RS:
 		return "RS"
 	case V_RSTART:
@@ -12,8 +12,10 @@ func TestNameIndex(t *testing.T) {
 		{"ILLEGAL", V_ILLEGAL},
 		{"ARGC", V_ARGC},
 		{"CONVFMT", V_CONVFMT},
+		{"FIELDWIDTHS", V_FIELDWIDTHS},
 		{"FILENAME", V_FILENAME},
 		{"FNR", V_FNR},
+		{"FPAT", V_FPAT},
 		{"FS", V_FS},
 		{"INPUTMODE", V_INPUTMODE},
 		{"NF", V_NF},
@@ -22,7 +24,9 @@ func TestNameIndex(t *testing.T) {
 		{"OFS", V_OFS},
 		{"ORS", V_ORS},
 		{"OUTPUTMODE", V_OUTPUTMODE},
+		{"PREC", V_PREC},
 		{"RLENGTH", V_RLENGTH},
+		{"ROUNDMODE", V_ROUNDMODE},
 		{"RS", V_RS},
 		{"RSTART", V_RSTART},
 		{"RT", V_RT},
@@ -55,7 +55,11 @@ func Walk(v Visitor, node Node) {
 		Walk(v, n.Left)
 		Walk(v, n.Right)
 
-	case *ArrayExpr: // leaf
+	case *ArrayExpr:
+		for _, index := range n.Subscripts {
+			WalkExprList(v, index)
+		}
+
 	case *InExpr:
 		WalkExprList(v, n.Index)
 		Walk(v, n.Array)
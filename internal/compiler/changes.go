@@ -0,0 +1,351 @@
+// Analysis of how the pattern-actions change global variables, used to
+// merge the variables of parallel workers, and of whether they do I/O that
+// parallel workers can't.
+
+package compiler
+
+import (
+	"github.com/nuvolaris/goawk/internal/ast"
+	"github.com/nuvolaris/goawk/lexer"
+)
+
+// VarChange describes how the pattern-actions (and the functions they
+// call) change a global variable. For arrays, it describes how the
+// elements are changed; deleting elements doesn't count as a change.
+type VarChange uint8
+
+const (
+	ChangeNone   VarChange = iota // Not changed
+	ChangeAdd                     // Only added to, as in "n++" or "sum += $2"
+	ChangeAppend                  // Only appended to, as in "s = s $1"
+	ChangeOther                   // Changed in other ways, as in "max = $1"
+
+	// Only read, used while finding changes: reading a variable that's
+	// added or appended to makes it ChangeOther, as a worker would read
+	// its partial value.
+	changeRead
+)
+
+// Combine two changes made to the same variable.
+func (c VarChange) join(other VarChange) VarChange {
+	switch {
+	case c == ChangeNone || c == other:
+		return other
+	case other == ChangeNone:
+		return c
+	default:
+		return ChangeOther
+	}
+}
+
+// Return the changes made to global scalars and arrays (indexed like the
+// variables) by the pattern-actions, including changes made by functions
+// they call directly or indirectly, and whether they use getline or
+// redirect output (other than to "/dev/stderr").
+func varChanges(prog *ast.ResolvedProgram) (scalars, arrays []VarChange, usesIO bool) {
+	funcs := make([]*changeFinder, len(prog.Functions))
+	for i, f := range prog.Functions {
+		funcs[i] = newChangeFinder(prog)
+		ast.WalkStmtList(funcs[i], f.Body)
+	}
+	// Add the changes made by called functions to their callers until
+	// there's nothing new, to handle calls of any depth (and recursion).
+	for added := true; added; {
+		added = false
+		for _, f := range funcs {
+			added = f.addCalls(funcs) || added
+		}
+	}
+
+	actions := newChangeFinder(prog)
+	for _, action := range prog.Actions {
+		ast.WalkExprList(actions, action.Pattern)
+		ast.WalkStmtList(actions, action.Stmts)
+	}
+	actions.addCalls(funcs)
+	return readToNone(actions.scalars), readToNone(actions.arrays), actions.usesIO
+}
+
+// Replace changeRead (variables only read) with ChangeNone in changes.
+func readToNone(changes []VarChange) []VarChange {
+	for i, change := range changes {
+		if change == changeRead {
+			changes[i] = ChangeNone
+		}
+	}
+	return changes
+}
+
+// Finds the changes made to global variables and local arrays (function
+// array parameters) by a function or by the pattern-actions.
+type changeFinder struct {
+	prog    *ast.ResolvedProgram
+	scalars []VarChange
+	arrays  []VarChange
+	locals  map[int]VarChange
+	calls   []funcCall
+	usesIO  bool
+}
+
+// A call to a user-defined function, with the array passed to each of its
+// local arrays (nil if the argument wasn't passed).
+type funcCall struct {
+	index  int
+	arrays []*arrayRef
+}
+
+type arrayRef struct {
+	scope ast.VarScope
+	index int
+}
+
+func newChangeFinder(prog *ast.ResolvedProgram) *changeFinder {
+	return &changeFinder{
+		prog:    prog,
+		scalars: make([]VarChange, len(prog.Scalars)),
+		arrays:  make([]VarChange, len(prog.Arrays)),
+		locals:  make(map[int]VarChange),
+	}
+}
+
+func (f *changeFinder) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.ExprStmt:
+		// The value of an assignment or increment statement is discarded,
+		// so on its own "n++" only adds to n; used in an expression, it
+		// also reads n.
+		switch e := n.Expr.(type) {
+		case *ast.AssignExpr, *ast.AugAssignExpr, *ast.IncrExpr:
+			f.assign(e, false)
+			return nil
+		}
+	case *ast.AssignExpr, *ast.AugAssignExpr, *ast.IncrExpr:
+		f.assign(n.(ast.Expr), true)
+		return nil
+	case *ast.VarExpr:
+		f.changeLvalue(n, changeRead)
+	case *ast.ArrayExpr:
+		f.changeArray(n, changeRead)
+	case *ast.PrintStmt:
+		f.usesIO = f.usesIO || isRedirect(n.Redirect, n.Dest)
+	case *ast.PrintfStmt:
+		f.usesIO = f.usesIO || isRedirect(n.Redirect, n.Dest)
+	case *ast.GetlineExpr:
+		f.usesIO = true
+		if n.Target != nil {
+			f.changeLvalue(n.Target, ChangeOther)
+		}
+	case *ast.ForInStmt:
+		f.changeLvalue(n.Var, ChangeOther)
+	case *ast.DeleteStmt:
+		// Deleting elements doesn't read the array or count as a change.
+		f.walkSubscripts(n.Array)
+		ast.WalkExprList(f, n.Index)
+		return nil
+	case *ast.CallExpr:
+		switch n.Func {
+		case lexer.F_SUB, lexer.F_GSUB:
+			if len(n.Args) == 3 {
+				f.changeLvalue(n.Args[2], ChangeOther)
+			}
+		case lexer.F_SPLIT:
+			f.changeArray(n.Args[1].(*ast.ArrayExpr), ChangeOther)
+		case lexer.F_MATCH:
+			if len(n.Args) == 3 {
+				f.changeArray(n.Args[2].(*ast.ArrayExpr), ChangeOther)
+			}
+		case lexer.F_ASORT, lexer.F_ASORTI:
+			dest := n.Args[0]
+			if len(n.Args) > 1 {
+				dest = n.Args[1]
+			}
+			f.changeArray(dest.(*ast.ArrayExpr), ChangeOther)
+		}
+	case *ast.UserCallExpr:
+		if n.Native {
+			// Native functions may change the maps they're passed.
+			for _, arg := range n.Args {
+				if a, ok := arg.(*ast.ArrayExpr); ok {
+					f.changeArray(a, ChangeOther)
+				}
+			}
+			break
+		}
+		// Arrays passed to the function are only read or changed as the
+		// function reads or changes its local arrays (see addCalls).
+		call := funcCall{index: n.Index}
+		arrayArgs := make(map[int]bool)
+		for i, isArray := range f.prog.Functions[n.Index].Arrays {
+			if !isArray {
+				continue
+			}
+			var ref *arrayRef
+			if i < len(n.Args) {
+				arrayArgs[i] = true
+				switch a := n.Args[i].(type) {
+				case *ast.VarExpr:
+					ref = &arrayRef{a.Scope, a.Index}
+				case *ast.ArrayExpr:
+					ref = &arrayRef{a.Scope, a.Index}
+					f.walkSubscripts(a)
+				}
+			}
+			call.arrays = append(call.arrays, ref)
+		}
+		f.calls = append(f.calls, call)
+		for i, arg := range n.Args {
+			if !arrayArgs[i] {
+				ast.Walk(f, arg)
+			}
+		}
+		return nil
+	}
+	return f
+}
+
+// Report whether a print or printf statement's output is redirected to
+// something other than "/dev/stderr".
+func isRedirect(redirect lexer.Token, dest ast.Expr) bool {
+	if redirect == lexer.ILLEGAL {
+		return false
+	}
+	str, ok := dest.(*ast.StrExpr)
+	return !ok || redirect == lexer.PIPE || str.Value != "/dev/stderr"
+}
+
+// Record the change made by an assignment or increment expression, and
+// walk its subexpressions. If used is true, the expression's value is
+// used, which also reads the variable or element it changes.
+func (f *changeFinder) assign(expr ast.Expr, used bool) {
+	var lvalue ast.Expr
+	var change VarChange
+	switch e := expr.(type) {
+	case *ast.AssignExpr:
+		var operands []ast.Expr
+		lvalue = e.Left
+		change, operands = assignChange(e.Left, e.Right)
+		ast.WalkExprList(f, operands)
+	case *ast.AugAssignExpr:
+		lvalue = e.Left
+		change = ChangeOther
+		if e.Op == lexer.ADD || e.Op == lexer.SUB {
+			change = ChangeAdd
+		}
+		ast.Walk(f, e.Right)
+	case *ast.IncrExpr:
+		lvalue = e.Expr
+		change = ChangeAdd
+	}
+	switch e := lvalue.(type) {
+	case *ast.VarExpr:
+	case *ast.IndexExpr:
+		f.walkSubscripts(e.Array)
+		ast.WalkExprList(f, e.Index)
+	default:
+		ast.Walk(f, e)
+	}
+	f.changeLvalue(lvalue, change)
+	if used {
+		f.changeLvalue(lvalue, changeRead)
+	}
+}
+
+// Walk the subscripts of array (as in "a[i][j]" passed to a function),
+// without reading the array itself.
+func (f *changeFinder) walkSubscripts(array *ast.ArrayExpr) {
+	for _, index := range array.Subscripts {
+		ast.WalkExprList(f, index)
+	}
+}
+
+// Return the change made by assigning right to left: "x = x y" appends to
+// x, "x = x + y" and "x = x - y" add to it, and others are ChangeOther.
+// Also return the operands of right that are evaluated other than x itself.
+func assignChange(left, right ast.Expr) (VarChange, []ast.Expr) {
+	binary, ok := right.(*ast.BinaryExpr)
+	if !ok {
+		return ChangeOther, []ast.Expr{right}
+	}
+	switch binary.Op {
+	case lexer.CONCAT:
+		// Concatenation is left-associative, so "x y z" is "(x y) z".
+		operands := []ast.Expr{binary.Right}
+		for {
+			inner, ok := binary.Left.(*ast.BinaryExpr)
+			if !ok || inner.Op != lexer.CONCAT {
+				break
+			}
+			binary = inner
+			operands = append(operands, binary.Right)
+		}
+		if binary.Left.String() == left.String() {
+			return ChangeAppend, operands
+		}
+	case lexer.ADD, lexer.SUB:
+		if binary.Left.String() == left.String() {
+			return ChangeAdd, []ast.Expr{binary.Right}
+		}
+	}
+	return ChangeOther, []ast.Expr{right}
+}
+
+// Record a change to the variable or array element lvalue.
+func (f *changeFinder) changeLvalue(lvalue ast.Expr, change VarChange) {
+	switch e := lvalue.(type) {
+	case *ast.VarExpr:
+		if e.Scope == ast.ScopeGlobal {
+			f.scalars[e.Index] = f.scalars[e.Index].join(change)
+		}
+	case *ast.IndexExpr:
+		f.changeArray(e.Array, change)
+	}
+}
+
+// Record a change to the elements of array (or one of its subarrays).
+func (f *changeFinder) changeArray(array *ast.ArrayExpr, change VarChange) {
+	f.change(arrayRef{array.Scope, array.Index}, change)
+}
+
+// Record a change to the elements of the referenced array, and report
+// whether that's new.
+func (f *changeFinder) change(ref arrayRef, change VarChange) bool {
+	switch ref.scope {
+	case ast.ScopeGlobal:
+		old := f.arrays[ref.index]
+		f.arrays[ref.index] = old.join(change)
+		return f.arrays[ref.index] != old
+	case ast.ScopeLocal:
+		old := f.locals[ref.index]
+		f.locals[ref.index] = old.join(change)
+		return f.locals[ref.index] != old
+	default:
+		return false
+	}
+}
+
+// Add the changes made by the functions f calls (and whether they use
+// I/O), and report whether any of them are new.
+func (f *changeFinder) addCalls(funcs []*changeFinder) bool {
+	added := false
+	for _, call := range f.calls {
+		callee := funcs[call.index]
+		if callee.usesIO && !f.usesIO {
+			f.usesIO = true
+			added = true
+		}
+		for i, change := range callee.scalars {
+			old := f.scalars[i]
+			f.scalars[i] = old.join(change)
+			added = added || f.scalars[i] != old
+		}
+		for i, change := range callee.arrays {
+			added = f.change(arrayRef{ast.ScopeGlobal, i}, change) || added
+		}
+		for i, change := range callee.locals {
+			if i < len(call.arrays) && call.arrays[i] != nil {
+				added = f.change(*call.arrays[i], change) || added
+			}
+		}
+	}
+	return added
+}
@@ -0,0 +1,101 @@
+package compiler_test
+
+import (
+	"testing"
+
+	"github.com/nuvolaris/goawk/internal/compiler"
+	"github.com/nuvolaris/goawk/parser"
+)
+
+func TestVarChanges(t *testing.T) {
+	tests := []struct {
+		src     string
+		scalars map[string]compiler.VarChange
+		arrays  map[string]compiler.VarChange
+	}{
+		{
+			`BEGIN { x = 1; a[1] = 2 } { n++; sum += $1; total = total - $2; count[$1]-- } END { y = n; b[1] = 1 }`,
+			map[string]compiler.VarChange{"x": compiler.ChangeNone, "n": compiler.ChangeAdd, "sum": compiler.ChangeAdd, "total": compiler.ChangeAdd, "y": compiler.ChangeNone},
+			map[string]compiler.VarChange{"a": compiler.ChangeNone, "count": compiler.ChangeAdd, "b": compiler.ChangeNone},
+		},
+		{
+			`{ s = s $1 "," ; lines[$1] = lines[$1] $0; t = $1 t; u *= 2; v = 1; v++ }`,
+			map[string]compiler.VarChange{"s": compiler.ChangeAppend, "t": compiler.ChangeOther, "u": compiler.ChangeOther, "v": compiler.ChangeOther},
+			map[string]compiler.VarChange{"lines": compiler.ChangeAppend},
+		},
+		{
+			`{ split($0, parts); sub(/x/, "y", r); for (k in parts) ; getline line < "f"; match($0, /x/, m); asort(parts, sorted) }`,
+			map[string]compiler.VarChange{"r": compiler.ChangeOther, "k": compiler.ChangeOther, "line": compiler.ChangeOther},
+			map[string]compiler.VarChange{"parts": compiler.ChangeOther, "m": compiler.ChangeOther, "sorted": compiler.ChangeOther},
+		},
+		{
+			`function inc(arr, k) { arr[k]++; calls++ }
+			 function outer(arr) { inc(arr, 1) }
+			 function set(arr) { arr[1] = 1; last = 1 }
+			 { outer(counts); inc(nested[$1], 2) }
+			 END { set(final) }`,
+			map[string]compiler.VarChange{"calls": compiler.ChangeAdd, "last": compiler.ChangeNone},
+			map[string]compiler.VarChange{"counts": compiler.ChangeAdd, "nested": compiler.ChangeAdd, "final": compiler.ChangeNone},
+		},
+		{
+			`function f(n) { if (n > 0) { g(n - 1); x = n } } function g(n) { f(n) } { g($1) }`,
+			map[string]compiler.VarChange{"x": compiler.ChangeOther},
+			nil,
+		},
+		{
+			`function get(arr) { return arr[1] } function add(arr) { arr[1]++ }
+			 { n++; if (n == 10) print; m++; print m++; x = x + y; print length(s); s = s "a" }
+			 { t += 1; print t; !seen[$0]++; add(c); add(d); get(d); if ($1 in e) e[$1]++; for (k in f) f[k]++ }`,
+			map[string]compiler.VarChange{"n": compiler.ChangeOther, "m": compiler.ChangeOther, "x": compiler.ChangeAdd, "y": compiler.ChangeNone, "s": compiler.ChangeOther, "t": compiler.ChangeOther},
+			map[string]compiler.VarChange{"seen": compiler.ChangeOther, "c": compiler.ChangeAdd, "d": compiler.ChangeOther, "e": compiler.ChangeOther, "f": compiler.ChangeOther},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			prog, err := parser.ParseProgram([]byte(test.src), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, expected := range test.scalars {
+				change := prog.Compiled.ScalarChanges[prog.Scalars[name]]
+				if change != expected {
+					t.Errorf("scalar %s: expected change %d, got %d", name, expected, change)
+				}
+			}
+			for name, expected := range test.arrays {
+				change := prog.Compiled.ArrayChanges[prog.Arrays[name]]
+				if change != expected {
+					t.Errorf("array %s: expected change %d, got %d", name, expected, change)
+				}
+			}
+		})
+	}
+}
+
+func TestActionsUseIO(t *testing.T) {
+	tests := []struct {
+		src    string
+		usesIO bool
+	}{
+		{`{ print; printf "x" } END { getline; print > "out" }`, false},
+		{`{ print > "/dev/stderr"; print >> "/dev/stderr" }`, false},
+		{`{ print > "out" }`, true},
+		{`{ printf "x" | "cat" }`, true},
+		{`{ print | "/dev/stderr" }`, true},
+		{`{ print > name }`, true},
+		{`{ getline }`, true},
+		{`NR == 1 || ("cmd" | getline) > 0`, true},
+		{`function f() { g() } function g() { getline x < "in" } { f() }`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			prog, err := parser.ParseProgram([]byte(test.src), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if prog.Compiled.ActionsUseIO != test.usesIO {
+				t.Errorf("expected ActionsUseIO %v, got %v", test.usesIO, prog.Compiled.ActionsUseIO)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"regexp"
+	"sort"
 	"strconv"
 
 	"github.com/nuvolaris/goawk/internal/ast"
@@ -21,16 +22,42 @@ type Program struct {
 	Strs      []string
 	Regexes   []*regexp.Regexp
 
+	// Source text of each number constant, indexed like Nums, so that the
+	// exact modes can convert it without losing precision ("" if the
+	// float64 value is exact enough)
+	NumTexts []string
+
+	// Source position tables for the Begin and End code (used by the
+	// debugger)
+	BeginLines Lines
+	EndLines   Lines
+
+	// Number of temporary arrays (after the program's global arrays) used
+	// to hold subarrays like the a[x] in a[x][y]
+	TempArrays int
+
+	// How the pattern-actions change each global scalar and array, indexed
+	// like the variables (used to merge variables in parallel mode)
+	ScalarChanges []VarChange
+	ArrayChanges  []VarChange
+
+	// Whether the pattern-actions use getline or redirect output (other
+	// than to "/dev/stderr"), which parallel workers can't do
+	ActionsUseIO bool
+
 	// For disassembly
-	scalarNames     []string
-	arrayNames      []string
-	nativeFuncNames []string
+	scalarNames []string
+	arrayNames  []string
+
+	// Native functions called, as typed by the resolver
+	nativeFuncs []ast.NativeFunc
 }
 
 // Action holds a compiled pattern-action block.
 type Action struct {
-	Pattern [][]Opcode
-	Body    []Opcode
+	Pattern   [][]Opcode
+	Body      []Opcode
+	BodyLines Lines
 }
 
 // Function holds a compiled function.
@@ -41,6 +68,28 @@ type Function struct {
 	NumScalars int
 	NumArrays  int
 	Body       []Opcode
+	Lines      Lines
+}
+
+// LinePos records that the statement at source position Pos starts at
+// opcode address Addr.
+type LinePos struct {
+	Addr int
+	Pos  lexer.Position
+}
+
+// Lines maps opcode addresses in a block of code to source positions. It's
+// sorted by address, and there's at most one entry per address.
+type Lines []LinePos
+
+// Index returns the index of the entry for the statement starting at
+// address addr, or -1 if no statement starts there.
+func (ls Lines) Index(addr int) int {
+	i := sort.Search(len(ls), func(i int) bool { return ls[i].Addr >= addr })
+	if i < len(ls) && ls[i].Addr == addr {
+		return i
+	}
+	return -1
 }
 
 // compileError is the internal error type raised in the rare cases when
@@ -54,8 +103,17 @@ func (e *compileError) Error() string {
 	return e.message
 }
 
+// Config holds the compiler configuration.
+type Config struct {
+	// Disable optimizations: constant folding, removal of code that can't
+	// be reached due to a constant condition, jump threading, and use of
+	// superinstructions (useful for comparing disassembly output).
+	NoOptimize bool
+}
+
 // Compile compiles an AST (parsed program) into virtual machine instructions.
-func Compile(prog *ast.ResolvedProgram) (compiledProg *Program, err error) {
+// "config" is allowed to be nil.
+func Compile(prog *ast.ResolvedProgram, config *Config) (compiledProg *Program, err error) {
 	defer func() {
 		// The compiler uses panic with a *compileError to signal compile
 		// errors internally, and they're caught here. This avoids the
@@ -67,13 +125,29 @@ func Compile(prog *ast.ResolvedProgram) (compiledProg *Program, err error) {
 	}()
 
 	p := &Program{}
+	p.ScalarChanges, p.ArrayChanges, p.ActionsUseIO = varChanges(prog)
+
+	// These are mostly used for disassembly, but set them up here (array
+	// names for subarrays are added during compilation).
+	p.scalarNames = make([]string, len(prog.Scalars))
+	for name, index := range prog.Scalars {
+		p.scalarNames[index] = name
+	}
+	p.arrayNames = make([]string, len(prog.Arrays))
+	for name, index := range prog.Arrays {
+		p.arrayNames[index] = name
+	}
 
 	// Reuse identical constants across entire program.
 	indexes := constantIndexes{
-		nums:    make(map[float64]int),
+		nums:    make(map[numConstant]int),
 		strs:    make(map[string]int),
 		regexes: make(map[string]int),
 	}
+	optimize := config == nil || !config.NoOptimize
+	newCompiler := func() *compiler {
+		return &compiler{program: p, resolved: prog, indexes: indexes, optimize: optimize}
+	}
 
 	// Compile functions. For functions called before they're defined or
 	// recursive functions, we have to set most p.Functions data first, then
@@ -96,15 +170,17 @@ func Compile(prog *ast.ResolvedProgram) (compiledProg *Program, err error) {
 		p.Functions[i] = compiledFunc
 	}
 	for i, astFunc := range prog.Functions {
-		c := &compiler{program: p, indexes: indexes}
+		c := newCompiler()
 		c.stmts(astFunc.Body)
 		p.Functions[i].Body = c.finish()
+		p.Functions[i].Lines = c.lines
 	}
 
 	// Compile BEGIN blocks.
 	for _, stmts := range prog.Begin {
-		c := &compiler{program: p, indexes: indexes}
+		c := newCompiler()
 		c.stmts(stmts)
+		p.BeginLines = appendLines(p.BeginLines, c.lines, len(p.Begin))
 		p.Begin = append(p.Begin, c.finish()...)
 	}
 
@@ -115,63 +191,79 @@ func Compile(prog *ast.ResolvedProgram) (compiledProg *Program, err error) {
 		case 0:
 			// Always considered a match
 		case 1:
-			c := &compiler{program: p, indexes: indexes}
+			c := newCompiler()
+			if value := c.constant(action.Pattern[0]); value != nil && isTruthy(value) {
+				// Constant pattern like "1" that always matches
+				break
+			}
 			c.expr(action.Pattern[0])
 			pattern = [][]Opcode{c.finish()}
 		case 2:
-			c := &compiler{program: p, indexes: indexes}
+			c := newCompiler()
 			c.expr(action.Pattern[0])
 			pattern = append(pattern, c.finish())
-			c = &compiler{program: p, indexes: indexes}
+			c = newCompiler()
 			c.expr(action.Pattern[1])
 			pattern = append(pattern, c.finish())
 		}
 		var body []Opcode
+		var bodyLines Lines
 		if len(action.Stmts) > 0 {
-			c := &compiler{program: p, indexes: indexes}
+			c := newCompiler()
 			c.stmts(action.Stmts)
 			body = c.finish()
+			bodyLines = c.lines
 		}
 		p.Actions = append(p.Actions, Action{
-			Pattern: pattern,
-			Body:    body,
+			Pattern:   pattern,
+			Body:      body,
+			BodyLines: bodyLines,
 		})
 	}
 
 	// Compile END blocks.
 	for _, stmts := range prog.End {
-		c := &compiler{program: p, indexes: indexes}
+		c := newCompiler()
 		c.stmts(stmts)
+		p.EndLines = appendLines(p.EndLines, c.lines, len(p.End))
 		p.End = append(p.End, c.finish()...)
 	}
 
-	// These are only used for disassembly, but set them up here.
-	p.scalarNames = make([]string, len(prog.Scalars))
-	for name, index := range prog.Scalars {
-		p.scalarNames[index] = name
-	}
-	p.arrayNames = make([]string, len(prog.Arrays))
-	for name, index := range prog.Arrays {
-		p.arrayNames[index] = name
-	}
-
 	return p, nil
 }
 
+// Append line table src (for code starting at address offset) to dst.
+func appendLines(dst, src Lines, offset int) Lines {
+	for _, l := range src {
+		dst = append(dst, LinePos{l.Addr + offset, l.Pos})
+	}
+	return dst
+}
+
 // So we can look up the indexes of constants that have been used before.
 type constantIndexes struct {
-	nums    map[float64]int
+	nums    map[numConstant]int
 	strs    map[string]int
 	regexes map[string]int
 }
 
+// A number constant's value and source text (see exactText).
+type numConstant struct {
+	value float64
+	text  string
+}
+
 // Holds the compilation state.
 type compiler struct {
 	program   *Program
+	resolved  *ast.ResolvedProgram
 	indexes   constantIndexes
+	optimize  bool
 	code      []Opcode
 	breaks    [][]int
 	continues [][]int
+	lines     Lines
+	jumps     []jumpAddr
 }
 
 func (c *compiler) add(ops ...Opcode) {
@@ -179,6 +271,9 @@ func (c *compiler) add(ops ...Opcode) {
 }
 
 func (c *compiler) finish() []Opcode {
+	if c.optimize {
+		c.threadJumps()
+	}
 	return c.code
 }
 
@@ -189,6 +284,15 @@ func (c *compiler) stmts(stmts []ast.Stmt) {
 }
 
 func (c *compiler) stmt(stmt ast.Stmt) {
+	// Record where the statement starts. Nested statements (for example the
+	// first statement in a block) starting at the same address replace the
+	// outer one, as they're more specific.
+	addr := len(c.code)
+	if n := len(c.lines); n > 0 && c.lines[n-1].Addr == addr {
+		c.lines = c.lines[:n-1]
+	}
+	c.lines = append(c.lines, LinePos{addr, stmt.StartPos()})
+
 	switch s := stmt.(type) {
 	case *ast.ExprStmt:
 		// Optimize assignment expressions to avoid the extra Dupe and Drop
@@ -213,13 +317,17 @@ func (c *compiler) stmt(stmt ast.Stmt) {
 			case *ast.FieldExpr:
 				c.expr(target.Index)
 				c.add(IncrField, incrAmount(expr.Op))
+			case *ast.NamedFieldExpr:
+				c.expr(target.Field)
+				c.add(IncrFieldByName, incrAmount(expr.Op))
 			case *ast.IndexExpr:
 				c.index(target.Index)
-				switch target.Array.Scope {
+				arrayScope, arrayIndex := c.arrayRef(target.Array)
+				switch arrayScope {
 				case ast.ScopeGlobal:
-					c.add(IncrArrayGlobal, incrAmount(expr.Op), opcodeInt(target.Array.Index))
+					c.add(IncrArrayGlobal, incrAmount(expr.Op), opcodeInt(arrayIndex))
 				default: // ScopeLocal
-					c.add(IncrArrayLocal, incrAmount(expr.Op), opcodeInt(target.Array.Index))
+					c.add(IncrArrayLocal, incrAmount(expr.Op), opcodeInt(arrayIndex))
 				}
 			}
 			return
@@ -256,13 +364,17 @@ func (c *compiler) stmt(stmt ast.Stmt) {
 			case *ast.FieldExpr:
 				c.expr(target.Index)
 				c.add(AugAssignField, Opcode(augOp))
+			case *ast.NamedFieldExpr:
+				c.expr(target.Field)
+				c.add(AugAssignFieldByName, Opcode(augOp))
 			case *ast.IndexExpr:
 				c.index(target.Index)
-				switch target.Array.Scope {
+				arrayScope, arrayIndex := c.arrayRef(target.Array)
+				switch arrayScope {
 				case ast.ScopeGlobal:
-					c.add(AugAssignArrayGlobal, Opcode(augOp), opcodeInt(target.Array.Index))
+					c.add(AugAssignArrayGlobal, Opcode(augOp), opcodeInt(arrayIndex))
 				default: // ScopeLocal
-					c.add(AugAssignArrayLocal, Opcode(augOp), opcodeInt(target.Array.Index))
+					c.add(AugAssignArrayLocal, Opcode(augOp), opcodeInt(arrayIndex))
 				}
 			}
 			return
@@ -291,14 +403,21 @@ func (c *compiler) stmt(stmt ast.Stmt) {
 		c.add(Printf, opcodeInt(len(s.Args)), Opcode(s.Redirect))
 
 	case *ast.IfStmt:
-		if len(s.Else) == 0 {
-			jumpOp := c.condition(s.Cond, true)
-			ifMark := c.jumpForward(jumpOp)
+		if value := c.constant(s.Cond); value != nil {
+			// Only compile the branch that will be taken
+			if isTruthy(value) {
+				c.stmts(s.Body)
+			} else {
+				c.stmts(s.Else)
+			}
+		} else if len(s.Else) == 0 {
+			jumpOp, args := c.condition(s.Cond, true)
+			ifMark := c.jumpForward(jumpOp, args...)
 			c.stmts(s.Body)
 			c.patchForward(ifMark)
 		} else {
-			jumpOp := c.condition(s.Cond, true)
-			ifMark := c.jumpForward(jumpOp)
+			jumpOp, args := c.condition(s.Cond, true)
+			ifMark := c.jumpForward(jumpOp, args...)
 			c.stmts(s.Body)
 			elseMark := c.jumpForward(Jump)
 			c.patchForward(ifMark)
@@ -310,6 +429,13 @@ func (c *compiler) stmt(stmt ast.Stmt) {
 		if s.Pre != nil {
 			c.stmt(s.Pre)
 		}
+		cond := s.Cond
+		if value := c.constant(cond); value != nil {
+			if !isTruthy(value) {
+				break // body is never executed
+			}
+			cond = nil
+		}
 		c.breaks = append(c.breaks, []int{})
 		c.continues = append(c.continues, []int{})
 
@@ -318,9 +444,9 @@ func (c *compiler) stmt(stmt ast.Stmt) {
 		// unconditional one at the end). This idea was stolen from an
 		// optimization CPython did recently in its "while" loop.
 		var mark int
-		if s.Cond != nil {
-			jumpOp := c.condition(s.Cond, true)
-			mark = c.jumpForward(jumpOp)
+		if cond != nil {
+			jumpOp, args := c.condition(cond, true)
+			mark = c.jumpForward(jumpOp, args...)
 		}
 
 		loopStart := c.labelBackward()
@@ -330,9 +456,9 @@ func (c *compiler) stmt(stmt ast.Stmt) {
 			c.stmt(s.Post)
 		}
 
-		if s.Cond != nil {
-			jumpOp := c.condition(s.Cond, false)
-			c.jumpBackward(loopStart, jumpOp)
+		if cond != nil {
+			jumpOp, args := c.condition(cond, false)
+			c.jumpBackward(loopStart, jumpOp, args...)
 			c.patchForward(mark)
 		} else {
 			c.jumpBackward(loopStart, Jump)
@@ -346,8 +472,9 @@ func (c *compiler) stmt(stmt ast.Stmt) {
 		// Otherwise we'd need to build a slice of all keys rather than
 		// iterating, or write our own hash table that has a more flexible
 		// iterator.
+		arrayScope, arrayIndex := c.arrayRef(s.Array)
 		mark := c.jumpForward(ForIn, opcodeInt(int(s.Var.Scope)), opcodeInt(s.Var.Index),
-			Opcode(s.Array.Scope), opcodeInt(s.Array.Index))
+			Opcode(arrayScope), opcodeInt(arrayIndex))
 
 		c.breaks = append(c.breaks, nil) // nil tells BreakStmt it's a for-in loop
 		c.continues = append(c.continues, []int{})
@@ -367,21 +494,35 @@ func (c *compiler) stmt(stmt ast.Stmt) {
 		}
 
 	case *ast.WhileStmt:
+		cond := s.Cond
+		if value := c.constant(cond); value != nil {
+			if !isTruthy(value) {
+				break // body is never executed
+			}
+			cond = nil
+		}
 		c.breaks = append(c.breaks, []int{})
 		c.continues = append(c.continues, []int{})
 
 		// Optimization: include condition once before loop and at the end.
 		// See ForStmt for more details.
-		jumpOp := c.condition(s.Cond, true)
-		mark := c.jumpForward(jumpOp)
+		var mark int
+		if cond != nil {
+			jumpOp, args := c.condition(cond, true)
+			mark = c.jumpForward(jumpOp, args...)
+		}
 
 		loopStart := c.labelBackward()
 		c.stmts(s.Body)
 		c.patchContinues()
 
-		jumpOp = c.condition(s.Cond, false)
-		c.jumpBackward(loopStart, jumpOp)
-		c.patchForward(mark)
+		if cond != nil {
+			jumpOp, args := c.condition(cond, false)
+			c.jumpBackward(loopStart, jumpOp, args...)
+			c.patchForward(mark)
+		} else {
+			c.jumpBackward(loopStart, Jump)
+		}
 
 		c.patchBreaks()
 
@@ -393,8 +534,14 @@ func (c *compiler) stmt(stmt ast.Stmt) {
 		c.stmts(s.Body)
 		c.patchContinues()
 
-		jumpOp := c.condition(s.Cond, false)
-		c.jumpBackward(loopStart, jumpOp)
+		if value := c.constant(s.Cond); value != nil {
+			if isTruthy(value) {
+				c.jumpBackward(loopStart, Jump)
+			}
+		} else {
+			jumpOp, args := c.condition(s.Cond, false)
+			c.jumpBackward(loopStart, jumpOp, args...)
+		}
 
 		c.patchBreaks()
 
@@ -420,16 +567,18 @@ func (c *compiler) stmt(stmt ast.Stmt) {
 		if s.Status != nil {
 			c.expr(s.Status)
 		} else {
-			c.expr(&ast.NumExpr{0})
+			c.expr(&ast.NumExpr{Value: 0})
 		}
 		c.add(Exit)
 
 	case *ast.DeleteStmt:
 		if len(s.Index) > 0 {
 			c.index(s.Index)
-			c.add(Delete, Opcode(s.Array.Scope), opcodeInt(s.Array.Index))
+			arrayScope, arrayIndex := c.arrayRef(s.Array)
+			c.add(Delete, Opcode(arrayScope), opcodeInt(arrayIndex))
 		} else {
-			c.add(DeleteAll, Opcode(s.Array.Scope), opcodeInt(s.Array.Index))
+			arrayScope, arrayIndex := c.arrayRef(s.Array)
+			c.add(DeleteAll, Opcode(arrayScope), opcodeInt(arrayIndex))
 		}
 
 	case *ast.BlockStmt:
@@ -450,6 +599,26 @@ func incrAmount(op lexer.Token) Opcode {
 	}
 }
 
+// Generate opcodes to fetch the subarray referred to by array (if it has
+// subscripts), and return the scope and index of the array to use. A
+// subarray is fetched into a temporary global array that's only used by this
+// reference, and its subscripts are evaluated after anything else already on
+// the stack (such as the index of an element of the subarray).
+func (c *compiler) arrayRef(array *ast.ArrayExpr) (ast.VarScope, int) {
+	if len(array.Subscripts) == 0 {
+		return array.Scope, array.Index
+	}
+	for _, index := range array.Subscripts {
+		c.index(index)
+	}
+	tempIndex := len(c.program.arrayNames)
+	c.program.arrayNames = append(c.program.arrayNames, array.String())
+	c.program.TempArrays++
+	c.add(SubArray, Opcode(array.Scope), opcodeInt(array.Index),
+		opcodeInt(len(array.Subscripts)), opcodeInt(tempIndex))
+	return ast.ScopeGlobal, tempIndex
+}
+
 // Generate opcodes for an assignment.
 func (c *compiler) assign(target ast.Expr) {
 	switch target := target.(type) {
@@ -465,14 +634,27 @@ func (c *compiler) assign(target ast.Expr) {
 	case *ast.FieldExpr:
 		c.expr(target.Index)
 		c.add(AssignField)
+	case *ast.NamedFieldExpr:
+		switch field := target.Field.(type) {
+		case *ast.StrExpr:
+			c.add(AssignFieldByNameStr, opcodeInt(c.strIndex(field.Value)))
+			return
+		}
+		c.expr(target.Field)
+		c.add(AssignFieldByName)
 	case *ast.IndexExpr:
 		c.index(target.Index)
-		switch target.Array.Scope {
+		arrayScope, arrayIndex := c.arrayRef(target.Array)
+		switch arrayScope {
 		case ast.ScopeGlobal:
-			c.add(AssignArrayGlobal, opcodeInt(target.Array.Index))
+			c.add(AssignArrayGlobal, opcodeInt(arrayIndex))
 		case ast.ScopeLocal:
-			c.add(AssignArrayLocal, opcodeInt(target.Array.Index))
+			c.add(AssignArrayLocal, opcodeInt(arrayIndex))
 		}
+	case *ast.ArrayExpr:
+		// Assigning an array returned by a native function
+		arrayScope, arrayIndex := c.arrayRef(target)
+		c.add(CopyArray, Opcode(arrayScope), opcodeInt(arrayIndex))
 	}
 }
 
@@ -505,6 +687,7 @@ func (c *compiler) patchContinues() {
 
 // Generate a forward jump (patched later) and return a "mark".
 func (c *compiler) jumpForward(jumpOp Opcode, args ...Opcode) int {
+	c.jumps = append(c.jumps, jumpAddr{len(c.code), len(c.code) + len(args) + 1})
 	c.add(jumpOp)
 	c.add(args...)
 	c.add(0)
@@ -525,13 +708,15 @@ func (c *compiler) labelBackward() int {
 // Jump to a previously-created label.
 func (c *compiler) jumpBackward(label int, jumpOp Opcode, args ...Opcode) {
 	offset := label - (len(c.code) + len(args) + 2)
+	c.jumps = append(c.jumps, jumpAddr{len(c.code), len(c.code) + len(args) + 1})
 	c.add(jumpOp)
 	c.add(args...)
 	c.add(opcodeInt(offset))
 }
 
-// Generate opcodes for a boolean condition.
-func (c *compiler) condition(expr ast.Expr, invert bool) Opcode {
+// Generate opcodes for a boolean condition, and return the jump opcode to
+// use (and any arguments that go before its offset).
+func (c *compiler) condition(expr ast.Expr, invert bool) (Opcode, []Opcode) {
 	jumpOp := func(normal, inverted Opcode) Opcode {
 		if invert {
 			return inverted
@@ -545,59 +730,66 @@ func (c *compiler) condition(expr ast.Expr, invert bool) Opcode {
 		// JumpLess instead of two instructions (Less and JumpTrue).
 		switch cond.Op {
 		case lexer.EQUALS:
+			if index, strIndex, ok := c.fieldStrComparison(cond); ok {
+				return jumpOp(JumpFieldIntEqualsStr, JumpFieldIntNotEqualsStr), []Opcode{index, strIndex}
+			}
 			c.expr(cond.Left)
 			c.expr(cond.Right)
-			return jumpOp(JumpEquals, JumpNotEquals)
+			return jumpOp(JumpEquals, JumpNotEquals), nil
 
 		case lexer.NOT_EQUALS:
+			if index, strIndex, ok := c.fieldStrComparison(cond); ok {
+				return jumpOp(JumpFieldIntNotEqualsStr, JumpFieldIntEqualsStr), []Opcode{index, strIndex}
+			}
 			c.expr(cond.Left)
 			c.expr(cond.Right)
-			return jumpOp(JumpNotEquals, JumpEquals)
+			return jumpOp(JumpNotEquals, JumpEquals), nil
 
 		case lexer.LESS:
 			c.expr(cond.Left)
 			c.expr(cond.Right)
-			return jumpOp(JumpLess, JumpGreaterOrEqual)
+			return jumpOp(JumpLess, JumpGreaterOrEqual), nil
 
 		case lexer.LTE:
 			c.expr(cond.Left)
 			c.expr(cond.Right)
-			return jumpOp(JumpLessOrEqual, JumpGreater)
+			return jumpOp(JumpLessOrEqual, JumpGreater), nil
 
 		case lexer.GREATER:
 			c.expr(cond.Left)
 			c.expr(cond.Right)
-			return jumpOp(JumpGreater, JumpLessOrEqual)
+			return jumpOp(JumpGreater, JumpLessOrEqual), nil
 
 		case lexer.GTE:
 			c.expr(cond.Left)
 			c.expr(cond.Right)
-			return jumpOp(JumpGreaterOrEqual, JumpLess)
+			return jumpOp(JumpGreaterOrEqual, JumpLess), nil
 		}
 	}
 
 	// Fall back to evaluating the expression normally, followed by JumpTrue
 	// or JumpFalse.
 	c.expr(expr)
-	return jumpOp(JumpTrue, JumpFalse)
+	return jumpOp(JumpTrue, JumpFalse), nil
 }
 
 func (c *compiler) expr(expr ast.Expr) {
+	if value := c.constant(expr); value != nil {
+		expr = value
+	}
+
 	switch e := expr.(type) {
 	case *ast.NumExpr:
-		c.add(Num, opcodeInt(c.numIndex(e.Value)))
+		c.add(Num, opcodeInt(c.numIndex(e.Value, exactText(e))))
 
 	case *ast.StrExpr:
 		c.add(Str, opcodeInt(c.strIndex(e.Value)))
 
 	case *ast.FieldExpr:
-		switch index := e.Index.(type) {
-		case *ast.NumExpr:
-			if index.Value == float64(Opcode(index.Value)) {
-				// Optimize $i to FieldInt opcode with integer argument
-				c.add(FieldInt, opcodeInt(int(index.Value)))
-				return
-			}
+		if index, ok := c.fieldIntIndex(e); ok {
+			// Optimize $i to FieldInt opcode with integer argument
+			c.add(FieldInt, index)
+			return
 		}
 		c.expr(e.Index)
 		c.add(Field)
@@ -628,6 +820,13 @@ func (c *compiler) expr(expr ast.Expr) {
 		// && and || are special cases as they're short-circuit operators.
 		switch e.Op {
 		case lexer.AND:
+			if left := c.constant(e.Left); left != nil {
+				// Left side must be true, otherwise the whole expression
+				// would have been folded, so the result is the right side.
+				c.expr(e.Right)
+				c.add(Boolean)
+				return
+			}
 			c.expr(e.Left)
 			c.add(Dupe)
 			mark := c.jumpForward(JumpFalse)
@@ -636,6 +835,12 @@ func (c *compiler) expr(expr ast.Expr) {
 			c.patchForward(mark)
 			c.add(Boolean)
 		case lexer.OR:
+			if left := c.constant(e.Left); left != nil {
+				// Left side must be false (see AND above)
+				c.expr(e.Right)
+				c.add(Boolean)
+				return
+			}
 			c.expr(e.Left)
 			c.add(Dupe)
 			mark := c.jumpForward(JumpTrue)
@@ -645,6 +850,18 @@ func (c *compiler) expr(expr ast.Expr) {
 			c.add(Boolean)
 		case lexer.CONCAT:
 			c.concatOp(e)
+		case lexer.EQUALS, lexer.NOT_EQUALS:
+			if index, strIndex, ok := c.fieldStrComparison(e); ok {
+				op := FieldIntEqualsStr
+				if e.Op == lexer.NOT_EQUALS {
+					op = FieldIntNotEqualsStr
+				}
+				c.add(op, index, strIndex)
+				return
+			}
+			c.expr(e.Left)
+			c.expr(e.Right)
+			c.binaryOp(e.Op)
 		default:
 			// All other binary expressions
 			c.expr(e.Left)
@@ -660,15 +877,15 @@ func (c *compiler) expr(expr ast.Expr) {
 		}
 		if e.Pre {
 			c.expr(e.Expr)
-			c.expr(&ast.NumExpr{1})
+			c.expr(&ast.NumExpr{Value: 1})
 			c.add(op)
 			c.add(Dupe)
 		} else {
 			c.expr(e.Expr)
-			c.expr(&ast.NumExpr{0})
+			c.expr(&ast.NumExpr{Value: 0})
 			c.add(Add)
 			c.add(Dupe)
-			c.expr(&ast.NumExpr{1})
+			c.expr(&ast.NumExpr{Value: 1})
 			c.add(op)
 		}
 		c.assign(e.Expr)
@@ -689,8 +906,16 @@ func (c *compiler) expr(expr ast.Expr) {
 		c.assign(e.Left)
 
 	case *ast.CondExpr:
-		jump := c.condition(e.Cond, true)
-		ifMark := c.jumpForward(jump)
+		if value := c.constant(e.Cond); value != nil {
+			if isTruthy(value) {
+				c.expr(e.True)
+			} else {
+				c.expr(e.False)
+			}
+			return
+		}
+		jump, args := c.condition(e.Cond, true)
+		ifMark := c.jumpForward(jump, args...)
 		c.expr(e.True)
 		elseMark := c.jumpForward(Jump)
 		c.patchForward(ifMark)
@@ -699,11 +924,12 @@ func (c *compiler) expr(expr ast.Expr) {
 
 	case *ast.IndexExpr:
 		c.index(e.Index)
-		switch e.Array.Scope {
+		arrayScope, arrayIndex := c.arrayRef(e.Array)
+		switch arrayScope {
 		case ast.ScopeGlobal:
-			c.add(ArrayGlobal, opcodeInt(e.Array.Index))
+			c.add(ArrayGlobal, opcodeInt(arrayIndex))
 		case ast.ScopeLocal:
-			c.add(ArrayLocal, opcodeInt(e.Array.Index))
+			c.add(ArrayLocal, opcodeInt(arrayIndex))
 		}
 
 	case *ast.CallExpr:
@@ -712,19 +938,52 @@ func (c *compiler) expr(expr ast.Expr) {
 		case lexer.F_SPLIT:
 			c.expr(e.Args[0])
 			arrayExpr := e.Args[1].(*ast.ArrayExpr)
-			if len(e.Args) > 2 {
+			switch {
+			case len(e.Args) > 3:
+				c.expr(e.Args[2])
+				c.expr(e.Args[3])
+				c.add(CallSplitSepPat, Opcode(arrayExpr.Scope), opcodeInt(arrayExpr.Index))
+			case len(e.Args) > 2:
 				c.expr(e.Args[2])
 				c.add(CallSplitSep, Opcode(arrayExpr.Scope), opcodeInt(arrayExpr.Index))
-			} else {
+			default:
 				c.add(CallSplit, Opcode(arrayExpr.Scope), opcodeInt(arrayExpr.Index))
 			}
 			return
+		case lexer.F_PRINTROW:
+			arrayExpr := e.Args[0].(*ast.ArrayExpr)
+			if len(e.Args) > 1 {
+				fieldsExpr := e.Args[1].(*ast.ArrayExpr)
+				c.add(CallPrintrowFields, Opcode(arrayExpr.Scope), opcodeInt(arrayExpr.Index),
+					Opcode(fieldsExpr.Scope), opcodeInt(fieldsExpr.Index))
+			} else {
+				c.add(CallPrintrow, Opcode(arrayExpr.Scope), opcodeInt(arrayExpr.Index))
+			}
+			return
+		case lexer.F_ASORT, lexer.F_ASORTI:
+			op, how := CallAsort, "@val_type_asc"
+			if e.Func == lexer.F_ASORTI {
+				op, how = CallAsorti, "@ind_str_asc"
+			}
+			srcExpr := e.Args[0].(*ast.ArrayExpr)
+			destExpr := srcExpr // with no destination, sort in place
+			if len(e.Args) > 1 {
+				destExpr = e.Args[1].(*ast.ArrayExpr)
+			}
+			if len(e.Args) > 2 {
+				c.expr(e.Args[2])
+			} else {
+				c.expr(&ast.StrExpr{how})
+			}
+			c.add(op, Opcode(srcExpr.Scope), opcodeInt(srcExpr.Index),
+				Opcode(destExpr.Scope), opcodeInt(destExpr.Index))
+			return
 		case lexer.F_SUB, lexer.F_GSUB:
 			op := BuiltinSub
 			if e.Func == lexer.F_GSUB {
 				op = BuiltinGsub
 			}
-			var target ast.Expr = &ast.FieldExpr{&ast.NumExpr{0}} // default value and target is $0
+			var target ast.Expr = &ast.FieldExpr{&ast.NumExpr{Value: 0}} // default value and target is $0
 			if len(e.Args) == 3 {
 				target = e.Args[2]
 			}
@@ -734,16 +993,68 @@ func (c *compiler) expr(expr ast.Expr) {
 			c.add(CallBuiltin, Opcode(op))
 			c.assign(target)
 			return
+		case lexer.F_MATCH:
+			if len(e.Args) < 3 {
+				break // regular 2-argument match is handled below
+			}
+			c.expr(e.Args[0])
+			c.expr(e.Args[1])
+			arrayExpr := e.Args[2].(*ast.ArrayExpr)
+			c.add(CallMatchArray, Opcode(arrayExpr.Scope), opcodeInt(arrayExpr.Index))
+			return
+		case lexer.F_ISARRAY:
+			// The resolver has turned isarray(name) into an array
+			// reference if name is an array, so only array elements
+			// need to be checked at runtime.
+			switch arg := e.Args[0].(type) {
+			case *ast.ArrayExpr:
+				c.expr(&ast.NumExpr{Value: 1})
+			case *ast.IndexExpr:
+				c.index(arg.Index)
+				arrayScope, arrayIndex := c.arrayRef(arg.Array)
+				c.add(IsArray, Opcode(arrayScope), opcodeInt(arrayIndex))
+			default:
+				c.expr(arg)
+				c.add(Drop)
+				c.expr(&ast.NumExpr{Value: 0})
+			}
+			return
+		case lexer.F_GENSUB:
+			// Unlike sub/gsub, the target isn't modified, so it needn't be
+			// an lvalue
+			for _, arg := range e.Args {
+				c.expr(arg)
+			}
+			if len(e.Args) < 4 {
+				c.expr(&ast.FieldExpr{&ast.NumExpr{Value: 0}}) // default target is $0
+			}
+			c.add(CallBuiltin, Opcode(BuiltinGensub))
+			return
 		}
 
 		for _, arg := range e.Args {
 			c.expr(arg)
 		}
 		switch e.Func {
+		case lexer.F_AND, lexer.F_OR, lexer.F_XOR:
+			// These take two or more arguments, which are combined pairwise
+			// starting with the last two (fine as the operations are associative).
+			op := BuiltinAnd
+			switch e.Func {
+			case lexer.F_OR:
+				op = BuiltinOr
+			case lexer.F_XOR:
+				op = BuiltinXor
+			}
+			for range e.Args[1:] {
+				c.add(CallBuiltin, Opcode(op))
+			}
 		case lexer.F_ATAN2:
 			c.add(CallBuiltin, Opcode(BuiltinAtan2))
 		case lexer.F_CLOSE:
 			c.add(CallBuiltin, Opcode(BuiltinClose))
+		case lexer.F_COMPL:
+			c.add(CallBuiltin, Opcode(BuiltinCompl))
 		case lexer.F_COS:
 			c.add(CallBuiltin, Opcode(BuiltinCos))
 		case lexer.F_EXP:
@@ -766,10 +1077,19 @@ func (c *compiler) expr(expr ast.Expr) {
 			}
 		case lexer.F_LOG:
 			c.add(CallBuiltin, Opcode(BuiltinLog))
+		case lexer.F_LSHIFT:
+			c.add(CallBuiltin, Opcode(BuiltinLshift))
 		case lexer.F_MATCH:
 			c.add(CallBuiltin, Opcode(BuiltinMatch))
+		case lexer.F_MKTIME:
+			if len(e.Args) < 2 {
+				c.expr(&ast.NumExpr{Value: 0}) // default is local time, not UTC
+			}
+			c.add(CallBuiltin, Opcode(BuiltinMktime))
 		case lexer.F_RAND:
 			c.add(CallBuiltin, Opcode(BuiltinRand))
+		case lexer.F_RSHIFT:
+			c.add(CallBuiltin, Opcode(BuiltinRshift))
 		case lexer.F_SIN:
 			c.add(CallBuiltin, Opcode(BuiltinSin))
 		case lexer.F_SPRINTF:
@@ -782,6 +1102,19 @@ func (c *compiler) expr(expr ast.Expr) {
 			} else {
 				c.add(CallBuiltin, Opcode(BuiltinSrand))
 			}
+		case lexer.F_STRFTIME:
+			// Push defaults for missing arguments: gawk's default format,
+			// the current time, and local time rather than UTC.
+			if len(e.Args) < 1 {
+				c.expr(&ast.StrExpr{"%a %b %e %H:%M:%S %Z %Y"})
+			}
+			if len(e.Args) < 2 {
+				c.add(CallBuiltin, Opcode(BuiltinSystime))
+			}
+			if len(e.Args) < 3 {
+				c.expr(&ast.NumExpr{Value: 0})
+			}
+			c.add(CallBuiltin, Opcode(BuiltinStrftime))
 		case lexer.F_SUBSTR:
 			if len(e.Args) > 2 {
 				c.add(CallBuiltin, Opcode(BuiltinSubstrLength))
@@ -790,6 +1123,8 @@ func (c *compiler) expr(expr ast.Expr) {
 			}
 		case lexer.F_SYSTEM:
 			c.add(CallBuiltin, Opcode(BuiltinSystem))
+		case lexer.F_SYSTIME:
+			c.add(CallBuiltin, Opcode(BuiltinSystime))
 		case lexer.F_TOLOWER:
 			c.add(CallBuiltin, Opcode(BuiltinTolower))
 		case lexer.F_TOUPPER:
@@ -811,29 +1146,39 @@ func (c *compiler) expr(expr ast.Expr) {
 
 	case *ast.InExpr:
 		c.index(e.Index)
-		switch e.Array.Scope {
+		arrayScope, arrayIndex := c.arrayRef(e.Array)
+		switch arrayScope {
 		case ast.ScopeGlobal:
-			c.add(InGlobal, opcodeInt(e.Array.Index))
+			c.add(InGlobal, opcodeInt(arrayIndex))
 		default: // ScopeLocal
-			c.add(InLocal, opcodeInt(e.Array.Index))
+			c.add(InLocal, opcodeInt(arrayIndex))
 		}
 
 	case *ast.UserCallExpr:
 		if e.Native {
 			for _, arg := range e.Args {
+				if a, ok := arg.(*ast.ArrayExpr); ok {
+					// Array argument, pass the array itself as a value
+					arrayScope, arrayIndex := c.arrayRef(a)
+					c.add(ArrayValue, Opcode(arrayScope), opcodeInt(arrayIndex))
+					continue
+				}
 				c.expr(arg)
 			}
 			c.add(CallNative, opcodeInt(e.Index), opcodeInt(len(e.Args)))
-			for len(c.program.nativeFuncNames) <= e.Index {
-				c.program.nativeFuncNames = append(c.program.nativeFuncNames, "")
+			for len(c.program.nativeFuncs) <= e.Index {
+				c.program.nativeFuncs = append(c.program.nativeFuncs, ast.NativeFunc{})
 			}
-			c.program.nativeFuncNames[e.Index] = e.Name
+			c.program.nativeFuncs[e.Index] = c.resolved.NativeFuncs[e.Index]
 		} else {
 			f := c.program.Functions[e.Index]
 			var arrayOpcodes []Opcode
 			numScalarArgs := 0
 			for i, arg := range e.Args {
 				if f.Arrays[i] {
+					if _, isSubarray := arg.(*ast.ArrayExpr); isSubarray {
+						continue // done below
+					}
 					a := arg.(*ast.VarExpr)
 					arrayOpcodes = append(arrayOpcodes, Opcode(a.Scope), opcodeInt(a.Index))
 				} else {
@@ -844,6 +1189,14 @@ func (c *compiler) expr(expr ast.Expr) {
 			if numScalarArgs < f.NumScalars {
 				c.add(Nulls, opcodeInt(f.NumScalars-numScalarArgs))
 			}
+			// Fetch subarray arguments last, right before the call, so that
+			// their temporary arrays can't be overwritten by other calls.
+			for i, arg := range e.Args {
+				if a, isSubarray := arg.(*ast.ArrayExpr); isSubarray && f.Arrays[i] {
+					arrayScope, arrayIndex := c.arrayRef(a)
+					arrayOpcodes = append(arrayOpcodes, Opcode(arrayScope), opcodeInt(arrayIndex))
+				}
+			}
 			c.add(CallUser, opcodeInt(e.Index), opcodeInt(len(arrayOpcodes)/2))
 			c.add(arrayOpcodes...)
 		}
@@ -874,9 +1227,13 @@ func (c *compiler) expr(expr ast.Expr) {
 		case *ast.FieldExpr:
 			c.expr(target.Index)
 			c.add(GetlineField, redirect())
+		case *ast.NamedFieldExpr:
+			c.expr(target.Field)
+			c.add(GetlineFieldByName, redirect())
 		case *ast.IndexExpr:
 			c.index(target.Index)
-			c.add(GetlineArray, redirect(), Opcode(target.Array.Scope), opcodeInt(target.Array.Index))
+			arrayScope, arrayIndex := c.arrayRef(target.Array)
+			c.add(GetlineArray, redirect(), Opcode(arrayScope), opcodeInt(arrayIndex))
 		default:
 			c.add(Getline, redirect())
 		}
@@ -904,6 +1261,10 @@ func (c *compiler) concatOp(expr *ast.BinaryExpr) {
 	// values are appended right to left
 	// but need to pushed left to right
 
+	if c.optimize {
+		values = c.mergeConstants(values)
+	}
+
 	if len(values) == 2 {
 		c.expr(values[1])
 		c.expr(values[0])
@@ -918,17 +1279,30 @@ func (c *compiler) concatOp(expr *ast.BinaryExpr) {
 	c.add(ConcatMulti, opcodeInt(len(values)))
 }
 
-// Add (or reuse) a number constant and returns its index.
-func (c *compiler) numIndex(n float64) int {
-	if index, ok := c.indexes.nums[n]; ok {
+// Add (or reuse) a number constant and returns its index. If text isn't
+// "", it's the constant's source text (see exactText).
+func (c *compiler) numIndex(n float64, text string) int {
+	key := numConstant{n, text}
+	if index, ok := c.indexes.nums[key]; ok {
 		return index // reuse existing constant
 	}
 	index := len(c.program.Nums)
 	c.program.Nums = append(c.program.Nums, n)
-	c.indexes.nums[n] = index
+	c.program.NumTexts = append(c.program.NumTexts, text)
+	c.indexes.nums[key] = index
 	return index
 }
 
+// Return the source text of number constant e if it may have a different
+// value than its float64 value in the exact modes (as 9007199254740993 or
+// 0.10000000000000000001 do), or "" if not.
+func exactText(e *ast.NumExpr) string {
+	if e.Text == "" || e.Text == strconv.FormatFloat(e.Value, 'g', -1, 64) {
+		return ""
+	}
+	return e.Text
+}
+
 // Add (or reuse) a string constant and returns its index.
 func (c *compiler) strIndex(s string) int {
 	if index, ok := c.indexes.strs[s]; ok {
@@ -998,10 +1372,13 @@ func (c *compiler) binaryOp(op lexer.Token) {
 // Generate an array index, handling multi-indexes properly.
 func (c *compiler) index(index []ast.Expr) {
 	for _, expr := range index {
-		if e, ok := expr.(*ast.NumExpr); ok && e.Value == float64(int(e.Value)) {
+		if value := c.constant(expr); value != nil {
+			expr = value
+		}
+		if n, ok := foldInt(expr); ok {
 			// If index expression is integer constant, optimize to string "n"
 			// to avoid toString() at runtime.
-			s := strconv.Itoa(int(e.Value))
+			s := strconv.FormatInt(n, 10)
 			c.expr(&ast.StrExpr{Value: s})
 			continue
 		}
@@ -16,10 +16,9 @@ import (
 func (p *Program) Disassemble(writer io.Writer) error {
 	if p.Begin != nil {
 		d := &disassembler{
-			program:         p,
-			writer:          writer,
-			code:            p.Begin,
-			nativeFuncNames: p.nativeFuncNames,
+			program: p,
+			writer:  writer,
+			code:    p.Begin,
 		}
 		err := d.disassemble("BEGIN")
 		if err != nil {
@@ -33,10 +32,9 @@ func (p *Program) Disassemble(writer io.Writer) error {
 			// Nothing to do here.
 		case 1:
 			d := &disassembler{
-				program:         p,
-				writer:          writer,
-				code:            action.Pattern[0],
-				nativeFuncNames: p.nativeFuncNames,
+				program: p,
+				writer:  writer,
+				code:    action.Pattern[0],
 			}
 			err := d.disassemble("pattern")
 			if err != nil {
@@ -44,20 +42,18 @@ func (p *Program) Disassemble(writer io.Writer) error {
 			}
 		case 2:
 			d := &disassembler{
-				program:         p,
-				writer:          writer,
-				code:            action.Pattern[0],
-				nativeFuncNames: p.nativeFuncNames,
+				program: p,
+				writer:  writer,
+				code:    action.Pattern[0],
 			}
 			err := d.disassemble("start")
 			if err != nil {
 				return err
 			}
 			d = &disassembler{
-				program:         p,
-				writer:          writer,
-				code:            action.Pattern[1],
-				nativeFuncNames: p.nativeFuncNames,
+				program: p,
+				writer:  writer,
+				code:    action.Pattern[1],
 			}
 			err = d.disassemble("stop")
 			if err != nil {
@@ -66,10 +62,9 @@ func (p *Program) Disassemble(writer io.Writer) error {
 		}
 		if len(action.Body) > 0 {
 			d := &disassembler{
-				program:         p,
-				writer:          writer,
-				code:            action.Body,
-				nativeFuncNames: p.nativeFuncNames,
+				program: p,
+				writer:  writer,
+				code:    action.Body,
 			}
 			err := d.disassemble("{ body }")
 			if err != nil {
@@ -80,10 +75,9 @@ func (p *Program) Disassemble(writer io.Writer) error {
 
 	if p.End != nil {
 		d := &disassembler{
-			program:         p,
-			writer:          writer,
-			code:            p.End,
-			nativeFuncNames: p.nativeFuncNames,
+			program: p,
+			writer:  writer,
+			code:    p.End,
 		}
 		err := d.disassemble("END")
 		if err != nil {
@@ -93,11 +87,10 @@ func (p *Program) Disassemble(writer io.Writer) error {
 
 	for i, f := range p.Functions {
 		d := &disassembler{
-			program:         p,
-			writer:          writer,
-			code:            f.Body,
-			nativeFuncNames: p.nativeFuncNames,
-			funcIndex:       i,
+			program:   p,
+			writer:    writer,
+			code:      f.Body,
+			funcIndex: i,
 		}
 		err := d.disassemble("function " + f.Name)
 		if err != nil {
@@ -110,14 +103,13 @@ func (p *Program) Disassemble(writer io.Writer) error {
 
 // Disassembles a single block of opcodes.
 type disassembler struct {
-	program         *Program
-	writer          io.Writer
-	code            []Opcode
-	nativeFuncNames []string
-	funcIndex       int
-	ip              int
-	opAddr          int
-	err             error
+	program   *Program
+	writer    io.Writer
+	code      []Opcode
+	funcIndex int
+	ip        int
+	opAddr    int
+	err       error
 }
 
 func (d *disassembler) disassemble(prefix string) error {
@@ -179,6 +171,27 @@ func (d *disassembler) disassemble(prefix string) error {
 			arrayIndex := int(d.fetch())
 			d.writeOpf("InLocal %s", d.localArrayName(arrayIndex))
 
+		case IsArray:
+			arrayScope := ast.VarScope(d.fetch())
+			arrayIndex := int(d.fetch())
+			d.writeOpf("IsArray %s", d.arrayName(arrayScope, arrayIndex))
+
+		case ArrayValue:
+			arrayScope := ast.VarScope(d.fetch())
+			arrayIndex := int(d.fetch())
+			d.writeOpf("ArrayValue %s", d.arrayName(arrayScope, arrayIndex))
+
+		case SubArray:
+			arrayScope := ast.VarScope(d.fetch())
+			arrayIndex := int(d.fetch())
+			num := d.fetch()
+			tempIndex := d.fetch()
+			d.writeOpf("SubArray %s %d %s", d.arrayName(arrayScope, arrayIndex), num, d.program.arrayNames[tempIndex])
+
+		case AssignFieldByNameStr:
+			index := d.fetch()
+			d.writeOpf("AssignFieldByNameStr %q (%d)", d.program.Strs[index], index)
+
 		case AssignGlobal:
 			index := d.fetch()
 			d.writeOpf("AssignGlobal %s", d.program.scalarNames[index])
@@ -199,6 +212,11 @@ func (d *disassembler) disassemble(prefix string) error {
 			arrayIndex := int(d.fetch())
 			d.writeOpf("AssignArrayLocal %s", d.localArrayName(arrayIndex))
 
+		case CopyArray:
+			arrayScope := ast.VarScope(d.fetch())
+			arrayIndex := int(d.fetch())
+			d.writeOpf("CopyArray %s", d.arrayName(arrayScope, arrayIndex))
+
 		case Delete:
 			arrayScope := ast.VarScope(d.fetch())
 			arrayIndex := int(d.fetch())
@@ -213,6 +231,10 @@ func (d *disassembler) disassemble(prefix string) error {
 			amount := d.fetch()
 			d.writeOpf("IncrField %d", amount)
 
+		case IncrFieldByName:
+			amount := d.fetch()
+			d.writeOpf("IncrFieldByName %d", amount)
+
 		case IncrGlobal:
 			amount := d.fetch()
 			index := d.fetch()
@@ -242,6 +264,10 @@ func (d *disassembler) disassemble(prefix string) error {
 			operation := AugOp(d.fetch())
 			d.writeOpf("AugAssignField %s", operation)
 
+		case AugAssignFieldByName:
+			operation := AugOp(d.fetch())
+			d.writeOpf("AugAssignFieldByName %s", operation)
+
 		case AugAssignGlobal:
 			operation := AugOp(d.fetch())
 			index := d.fetch()
@@ -279,6 +305,16 @@ func (d *disassembler) disassemble(prefix string) error {
 			num := d.fetch()
 			d.writeOpf("ConcatMulti %d", num)
 
+		case FieldIntEqualsStr:
+			index := d.fetch()
+			strIndex := d.fetch()
+			d.writeOpf("FieldIntEqualsStr %d %q (%d)", index, d.program.Strs[strIndex], strIndex)
+
+		case FieldIntNotEqualsStr:
+			index := d.fetch()
+			strIndex := d.fetch()
+			d.writeOpf("FieldIntNotEqualsStr %d %q (%d)", index, d.program.Strs[strIndex], strIndex)
+
 		case Jump:
 			offset := d.fetch()
 			d.writeOpf("Jump 0x%04x", d.ip+int(offset))
@@ -315,6 +351,18 @@ func (d *disassembler) disassemble(prefix string) error {
 			offset := d.fetch()
 			d.writeOpf("JumpGreaterOrEqual 0x%04x", d.ip+int(offset))
 
+		case JumpFieldIntEqualsStr:
+			index := d.fetch()
+			strIndex := d.fetch()
+			offset := d.fetch()
+			d.writeOpf("JumpFieldIntEqualsStr %d %q (%d) 0x%04x", index, d.program.Strs[strIndex], strIndex, d.ip+int(offset))
+
+		case JumpFieldIntNotEqualsStr:
+			index := d.fetch()
+			strIndex := d.fetch()
+			offset := d.fetch()
+			d.writeOpf("JumpFieldIntNotEqualsStr %d %q (%d) 0x%04x", index, d.program.Strs[strIndex], strIndex, d.ip+int(offset))
+
 		case ForIn:
 			varScope := ast.VarScope(d.fetch())
 			varIndex := int(d.fetch())
@@ -337,10 +385,39 @@ func (d *disassembler) disassemble(prefix string) error {
 			arrayIndex := int(d.fetch())
 			d.writeOpf("CallSplitSep %s", d.arrayName(arrayScope, arrayIndex))
 
+		case CallSplitSepPat:
+			arrayScope := ast.VarScope(d.fetch())
+			arrayIndex := int(d.fetch())
+			d.writeOpf("CallSplitSepPat %s", d.arrayName(arrayScope, arrayIndex))
+
+		case CallMatchArray:
+			arrayScope := ast.VarScope(d.fetch())
+			arrayIndex := int(d.fetch())
+			d.writeOpf("CallMatchArray %s", d.arrayName(arrayScope, arrayIndex))
+
 		case CallSprintf:
 			numArgs := d.fetch()
 			d.writeOpf("CallSprintf %d", numArgs)
 
+		case CallPrintrow:
+			arrayScope := ast.VarScope(d.fetch())
+			arrayIndex := int(d.fetch())
+			d.writeOpf("CallPrintrow %s", d.arrayName(arrayScope, arrayIndex))
+
+		case CallPrintrowFields:
+			arrayScope := ast.VarScope(d.fetch())
+			arrayIndex := int(d.fetch())
+			fieldsScope := ast.VarScope(d.fetch())
+			fieldsIndex := int(d.fetch())
+			d.writeOpf("CallPrintrowFields %s %s", d.arrayName(arrayScope, arrayIndex), d.arrayName(fieldsScope, fieldsIndex))
+
+		case CallAsort, CallAsorti:
+			srcScope := ast.VarScope(d.fetch())
+			srcIndex := int(d.fetch())
+			destScope := ast.VarScope(d.fetch())
+			destIndex := int(d.fetch())
+			d.writeOpf("%s %s %s", op, d.arrayName(srcScope, srcIndex), d.arrayName(destScope, destIndex))
+
 		case CallUser:
 			funcIndex := d.fetch()
 			numArrayArgs := int(d.fetch())
@@ -355,7 +432,7 @@ func (d *disassembler) disassemble(prefix string) error {
 		case CallNative:
 			funcIndex := d.fetch()
 			numArgs := d.fetch()
-			d.writeOpf("CallNative %s %d", d.nativeFuncNames[funcIndex], numArgs)
+			d.writeOpf("CallNative %s %d", d.program.nativeFuncs[funcIndex].Name, numArgs)
 
 		case Nulls:
 			numNulls := d.fetch()
@@ -387,6 +464,10 @@ func (d *disassembler) disassemble(prefix string) error {
 			redirect := lexer.Token(d.fetch())
 			d.writeOpf("GetlineField %s", redirect)
 
+		case GetlineFieldByName:
+			redirect := lexer.Token(d.fetch())
+			d.writeOpf("GetlineFieldByName %s", redirect)
+
 		case GetlineGlobal:
 			redirect := lexer.Token(d.fetch())
 			index := d.fetch()
@@ -5,6 +5,8 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+
+	"github.com/nuvolaris/goawk/internal/ast"
 )
 
 func TestDisassembler(t *testing.T) {
@@ -23,12 +25,12 @@ func TestDisassembler(t *testing.T) {
 						NumArrays:  1,
 					},
 				},
-				Nums:            []float64{0},
-				Strs:            []string{""},
-				Regexes:         []*regexp.Regexp{regexp.MustCompile("")},
-				scalarNames:     []string{"s"},
-				arrayNames:      []string{"a"},
-				nativeFuncNames: []string{"n"},
+				Nums:        []float64{0},
+				Strs:        []string{""},
+				Regexes:     []*regexp.Regexp{regexp.MustCompile("")},
+				scalarNames: []string{"s"},
+				arrayNames:  []string{"a"},
+				nativeFuncs: []ast.NativeFunc{{Name: "n"}},
 			}
 			var buf bytes.Buffer
 			err := p.Disassemble(&buf)
@@ -0,0 +1,208 @@
+// Serialization of compiled programs.
+
+package compiler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nuvolaris/goawk/internal/ast"
+	"github.com/nuvolaris/goawk/lexer"
+)
+
+// Header and format version of a serialized program. The format version
+// must be incremented if programData changes. A fingerprint of the
+// opcodes and tokens follows the header, so that programs compiled by a
+// version of GoAWK with a different instruction set are rejected, and
+// then a SHA-256 checksum of the gob-encoded programData, so that
+// corrupted data is rejected.
+const (
+	marshalMagic   = "GoAWK compiled\x00"
+	marshalVersion = 6
+)
+
+var (
+	errNotCompiled  = errors.New("not a compiled GoAWK program")
+	errIncompatible = errors.New("compiled program is from an incompatible version of GoAWK")
+)
+
+// Everything in a Program, in a form that can be gob-encoded.
+type programData struct {
+	Begin         []Opcode
+	Actions       []Action
+	End           []Opcode
+	Functions     []Function
+	Nums          []float64
+	NumTexts      []string
+	Strs          []string
+	Regexes       []string
+	BeginLines    Lines
+	EndLines      Lines
+	TempArrays    int
+	ScalarChanges []VarChange
+	ArrayChanges  []VarChange
+	ActionsUseIO  bool
+	ScalarNames   []string
+	ArrayNames    []string
+	NativeFuncs   []ast.NativeFunc
+}
+
+// MarshalBinary encodes the compiled program, including its code, constants,
+// regexes, functions, and variable tables, so that it can be loaded with
+// UnmarshalBinary without parsing or compiling the source again.
+func (p *Program) MarshalBinary() ([]byte, error) {
+	data := programData{
+		Begin:         p.Begin,
+		Actions:       p.Actions,
+		End:           p.End,
+		Functions:     p.Functions,
+		Nums:          p.Nums,
+		NumTexts:      p.NumTexts,
+		Strs:          p.Strs,
+		Regexes:       make([]string, len(p.Regexes)),
+		BeginLines:    p.BeginLines,
+		EndLines:      p.EndLines,
+		TempArrays:    p.TempArrays,
+		ScalarChanges: p.ScalarChanges,
+		ArrayChanges:  p.ArrayChanges,
+		ActionsUseIO:  p.ActionsUseIO,
+		ScalarNames:   p.scalarNames,
+		ArrayNames:    p.arrayNames,
+		NativeFuncs:   p.nativeFuncs,
+	}
+	for i, re := range p.Regexes {
+		data.Regexes[i] = re.String()
+	}
+
+	var payload bytes.Buffer
+	err := gob.NewEncoder(&payload).Encode(&data)
+	if err != nil {
+		return nil, err
+	}
+	checksum := sha256.Sum256(payload.Bytes())
+
+	var buf bytes.Buffer
+	buf.WriteString(marshalMagic)
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(marshalVersion))
+	buf.Write(instructionSetFingerprint())
+	buf.Write(checksum[:])
+	buf.Write(payload.Bytes())
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a program encoded by MarshalBinary. It returns an
+// error if the data isn't a compiled program, if it was compiled by a
+// version of GoAWK with a different format or instruction set, or if it's
+// corrupt (including if its code has invalid opcodes or operands).
+func (p *Program) UnmarshalBinary(b []byte) error {
+	if !bytes.HasPrefix(b, []byte(marshalMagic)) {
+		return errNotCompiled
+	}
+	b = b[len(marshalMagic):]
+	fingerprint := instructionSetFingerprint()
+	if len(b) < 4+len(fingerprint) ||
+		binary.LittleEndian.Uint32(b) != marshalVersion ||
+		!bytes.Equal(b[4:4+len(fingerprint)], fingerprint) {
+		return errIncompatible
+	}
+	b = b[4+len(fingerprint):]
+	if len(b) < sha256.Size {
+		return errors.New("invalid compiled program: unexpected EOF")
+	}
+	checksum := sha256.Sum256(b[sha256.Size:])
+	if !bytes.Equal(b[:sha256.Size], checksum[:]) {
+		return errors.New("invalid compiled program: checksum mismatch")
+	}
+	b = b[sha256.Size:]
+
+	var data programData
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&data)
+	if err != nil {
+		return fmt.Errorf("invalid compiled program: %v", err)
+	}
+	regexes := make([]*regexp.Regexp, len(data.Regexes))
+	for i, source := range data.Regexes {
+		regexes[i], err = regexp.Compile(source)
+		if err != nil {
+			return fmt.Errorf("invalid compiled program: %v", err)
+		}
+	}
+
+	loaded := Program{
+		Begin:         data.Begin,
+		Actions:       data.Actions,
+		End:           data.End,
+		Functions:     data.Functions,
+		Nums:          data.Nums,
+		NumTexts:      data.NumTexts,
+		Strs:          data.Strs,
+		Regexes:       regexes,
+		BeginLines:    data.BeginLines,
+		EndLines:      data.EndLines,
+		TempArrays:    data.TempArrays,
+		ScalarChanges: data.ScalarChanges,
+		ArrayChanges:  data.ArrayChanges,
+		ActionsUseIO:  data.ActionsUseIO,
+		scalarNames:   data.ScalarNames,
+		arrayNames:    data.ArrayNames,
+		nativeFuncs:   data.NativeFuncs,
+	}
+	err = loaded.validate()
+	if err != nil {
+		return fmt.Errorf("invalid compiled program: %v", err)
+	}
+	*p = loaded
+	return nil
+}
+
+// VarIndexes returns the indexes of the program's global scalars and arrays
+// by name, as determined by the resolver when the program was compiled.
+func (p *Program) VarIndexes() (scalars, arrays map[string]int) {
+	scalars = make(map[string]int, len(p.scalarNames))
+	for index, name := range p.scalarNames {
+		scalars[name] = index
+	}
+	numArrays := len(p.arrayNames) - p.TempArrays
+	arrays = make(map[string]int, numArrays)
+	for index, name := range p.arrayNames[:numArrays] {
+		arrays[name] = index
+	}
+	return scalars, arrays
+}
+
+// NativeFuncs returns the native functions the program calls, by index,
+// as typed by the resolver when the program was compiled. Entries for
+// native functions that aren't called have an empty Name.
+func (p *Program) NativeFuncs() []ast.NativeFunc {
+	return p.nativeFuncs
+}
+
+// Return a hash of the names of all opcodes, operations, and tokens, which
+// changes if any are added, removed, or reordered.
+func instructionSetFingerprint() []byte {
+	var sb strings.Builder
+	for op := Opcode(0); op <= EndOpcode; op++ {
+		sb.WriteString(op.String())
+		sb.WriteByte('\n')
+	}
+	for op := AugOp(0); !strings.HasPrefix(op.String(), "AugOp("); op++ {
+		sb.WriteString(op.String())
+		sb.WriteByte('\n')
+	}
+	for op := BuiltinOp(0); !strings.HasPrefix(op.String(), "BuiltinOp("); op++ {
+		sb.WriteString(op.String())
+		sb.WriteByte('\n')
+	}
+	for tok := lexer.Token(0); tok <= lexer.LAST; tok++ {
+		sb.WriteString(tok.String())
+		sb.WriteByte('\n')
+	}
+	sum := sha256.Sum256([]byte(sb.String()))
+	return sum[:]
+}
@@ -25,84 +25,103 @@ func _() {
 	_ = x[ArrayLocal-14]
 	_ = x[InGlobal-15]
 	_ = x[InLocal-16]
-	_ = x[AssignField-17]
-	_ = x[AssignGlobal-18]
-	_ = x[AssignLocal-19]
-	_ = x[AssignSpecial-20]
-	_ = x[AssignArrayGlobal-21]
-	_ = x[AssignArrayLocal-22]
-	_ = x[Delete-23]
-	_ = x[DeleteAll-24]
-	_ = x[IncrField-25]
-	_ = x[IncrGlobal-26]
-	_ = x[IncrLocal-27]
-	_ = x[IncrSpecial-28]
-	_ = x[IncrArrayGlobal-29]
-	_ = x[IncrArrayLocal-30]
-	_ = x[AugAssignField-31]
-	_ = x[AugAssignGlobal-32]
-	_ = x[AugAssignLocal-33]
-	_ = x[AugAssignSpecial-34]
-	_ = x[AugAssignArrayGlobal-35]
-	_ = x[AugAssignArrayLocal-36]
-	_ = x[Regex-37]
-	_ = x[IndexMulti-38]
-	_ = x[ConcatMulti-39]
-	_ = x[Add-40]
-	_ = x[Subtract-41]
-	_ = x[Multiply-42]
-	_ = x[Divide-43]
-	_ = x[Power-44]
-	_ = x[Modulo-45]
-	_ = x[Equals-46]
-	_ = x[NotEquals-47]
-	_ = x[Less-48]
-	_ = x[Greater-49]
-	_ = x[LessOrEqual-50]
-	_ = x[GreaterOrEqual-51]
-	_ = x[Concat-52]
-	_ = x[Match-53]
-	_ = x[NotMatch-54]
-	_ = x[Not-55]
-	_ = x[UnaryMinus-56]
-	_ = x[UnaryPlus-57]
-	_ = x[Boolean-58]
-	_ = x[Jump-59]
-	_ = x[JumpFalse-60]
-	_ = x[JumpTrue-61]
-	_ = x[JumpEquals-62]
-	_ = x[JumpNotEquals-63]
-	_ = x[JumpLess-64]
-	_ = x[JumpGreater-65]
-	_ = x[JumpLessOrEqual-66]
-	_ = x[JumpGreaterOrEqual-67]
-	_ = x[Next-68]
-	_ = x[Exit-69]
-	_ = x[ForIn-70]
-	_ = x[BreakForIn-71]
-	_ = x[CallBuiltin-72]
-	_ = x[CallSplit-73]
-	_ = x[CallSplitSep-74]
-	_ = x[CallSprintf-75]
-	_ = x[CallUser-76]
-	_ = x[CallNative-77]
-	_ = x[Return-78]
-	_ = x[ReturnNull-79]
-	_ = x[Nulls-80]
-	_ = x[Print-81]
-	_ = x[Printf-82]
-	_ = x[Getline-83]
-	_ = x[GetlineField-84]
-	_ = x[GetlineGlobal-85]
-	_ = x[GetlineLocal-86]
-	_ = x[GetlineSpecial-87]
-	_ = x[GetlineArray-88]
-	_ = x[EndOpcode-89]
+	_ = x[IsArray-17]
+	_ = x[ArrayValue-18]
+	_ = x[SubArray-19]
+	_ = x[AssignField-20]
+	_ = x[AssignFieldByName-21]
+	_ = x[AssignFieldByNameStr-22]
+	_ = x[AssignGlobal-23]
+	_ = x[AssignLocal-24]
+	_ = x[AssignSpecial-25]
+	_ = x[AssignArrayGlobal-26]
+	_ = x[AssignArrayLocal-27]
+	_ = x[CopyArray-28]
+	_ = x[Delete-29]
+	_ = x[DeleteAll-30]
+	_ = x[IncrField-31]
+	_ = x[IncrFieldByName-32]
+	_ = x[IncrGlobal-33]
+	_ = x[IncrLocal-34]
+	_ = x[IncrSpecial-35]
+	_ = x[IncrArrayGlobal-36]
+	_ = x[IncrArrayLocal-37]
+	_ = x[AugAssignField-38]
+	_ = x[AugAssignFieldByName-39]
+	_ = x[AugAssignGlobal-40]
+	_ = x[AugAssignLocal-41]
+	_ = x[AugAssignSpecial-42]
+	_ = x[AugAssignArrayGlobal-43]
+	_ = x[AugAssignArrayLocal-44]
+	_ = x[Regex-45]
+	_ = x[IndexMulti-46]
+	_ = x[ConcatMulti-47]
+	_ = x[Add-48]
+	_ = x[Subtract-49]
+	_ = x[Multiply-50]
+	_ = x[Divide-51]
+	_ = x[Power-52]
+	_ = x[Modulo-53]
+	_ = x[Equals-54]
+	_ = x[NotEquals-55]
+	_ = x[Less-56]
+	_ = x[Greater-57]
+	_ = x[LessOrEqual-58]
+	_ = x[GreaterOrEqual-59]
+	_ = x[Concat-60]
+	_ = x[Match-61]
+	_ = x[NotMatch-62]
+	_ = x[FieldIntEqualsStr-63]
+	_ = x[FieldIntNotEqualsStr-64]
+	_ = x[Not-65]
+	_ = x[UnaryMinus-66]
+	_ = x[UnaryPlus-67]
+	_ = x[Boolean-68]
+	_ = x[Jump-69]
+	_ = x[JumpFalse-70]
+	_ = x[JumpTrue-71]
+	_ = x[JumpEquals-72]
+	_ = x[JumpNotEquals-73]
+	_ = x[JumpLess-74]
+	_ = x[JumpGreater-75]
+	_ = x[JumpLessOrEqual-76]
+	_ = x[JumpGreaterOrEqual-77]
+	_ = x[JumpFieldIntEqualsStr-78]
+	_ = x[JumpFieldIntNotEqualsStr-79]
+	_ = x[Next-80]
+	_ = x[Exit-81]
+	_ = x[ForIn-82]
+	_ = x[BreakForIn-83]
+	_ = x[CallBuiltin-84]
+	_ = x[CallSplit-85]
+	_ = x[CallSplitSep-86]
+	_ = x[CallSplitSepPat-87]
+	_ = x[CallMatchArray-88]
+	_ = x[CallSprintf-89]
+	_ = x[CallPrintrow-90]
+	_ = x[CallPrintrowFields-91]
+	_ = x[CallAsort-92]
+	_ = x[CallAsorti-93]
+	_ = x[CallUser-94]
+	_ = x[CallNative-95]
+	_ = x[Return-96]
+	_ = x[ReturnNull-97]
+	_ = x[Nulls-98]
+	_ = x[Print-99]
+	_ = x[Printf-100]
+	_ = x[Getline-101]
+	_ = x[GetlineField-102]
+	_ = x[GetlineFieldByName-103]
+	_ = x[GetlineGlobal-104]
+	_ = x[GetlineLocal-105]
+	_ = x[GetlineSpecial-106]
+	_ = x[GetlineArray-107]
+	_ = x[EndOpcode-108]
 }
 
-const _Opcode_name = "NopNumStrDupeDropSwapFieldFieldIntFieldByNameFieldByNameStrGlobalLocalSpecialArrayGlobalArrayLocalInGlobalInLocalAssignFieldAssignGlobalAssignLocalAssignSpecialAssignArrayGlobalAssignArrayLocalDeleteDeleteAllIncrFieldIncrGlobalIncrLocalIncrSpecialIncrArrayGlobalIncrArrayLocalAugAssignFieldAugAssignGlobalAugAssignLocalAugAssignSpecialAugAssignArrayGlobalAugAssignArrayLocalRegexIndexMultiConcatMultiAddSubtractMultiplyDividePowerModuloEqualsNotEqualsLessGreaterLessOrEqualGreaterOrEqualConcatMatchNotMatchNotUnaryMinusUnaryPlusBooleanJumpJumpFalseJumpTrueJumpEqualsJumpNotEqualsJumpLessJumpGreaterJumpLessOrEqualJumpGreaterOrEqualNextExitForInBreakForInCallBuiltinCallSplitCallSplitSepCallSprintfCallUserCallNativeReturnReturnNullNullsPrintPrintfGetlineGetlineFieldGetlineGlobalGetlineLocalGetlineSpecialGetlineArrayEndOpcode"
+const _Opcode_name = "NopNumStrDupeDropSwapFieldFieldIntFieldByNameFieldByNameStrGlobalLocalSpecialArrayGlobalArrayLocalInGlobalInLocalIsArrayArrayValueSubArrayAssignFieldAssignFieldByNameAssignFieldByNameStrAssignGlobalAssignLocalAssignSpecialAssignArrayGlobalAssignArrayLocalCopyArrayDeleteDeleteAllIncrFieldIncrFieldByNameIncrGlobalIncrLocalIncrSpecialIncrArrayGlobalIncrArrayLocalAugAssignFieldAugAssignFieldByNameAugAssignGlobalAugAssignLocalAugAssignSpecialAugAssignArrayGlobalAugAssignArrayLocalRegexIndexMultiConcatMultiAddSubtractMultiplyDividePowerModuloEqualsNotEqualsLessGreaterLessOrEqualGreaterOrEqualConcatMatchNotMatchFieldIntEqualsStrFieldIntNotEqualsStrNotUnaryMinusUnaryPlusBooleanJumpJumpFalseJumpTrueJumpEqualsJumpNotEqualsJumpLessJumpGreaterJumpLessOrEqualJumpGreaterOrEqualJumpFieldIntEqualsStrJumpFieldIntNotEqualsStrNextExitForInBreakForInCallBuiltinCallSplitCallSplitSepCallSplitSepPatCallMatchArrayCallSprintfCallPrintrowCallPrintrowFieldsCallAsortCallAsortiCallUserCallNativeReturnReturnNullNullsPrintPrintfGetlineGetlineFieldGetlineFieldByNameGetlineGlobalGetlineLocalGetlineSpecialGetlineArrayEndOpcode"
 
-var _Opcode_index = [...]uint16{0, 3, 6, 9, 13, 17, 21, 26, 34, 45, 59, 65, 70, 77, 88, 98, 106, 113, 124, 136, 147, 160, 177, 193, 199, 208, 217, 227, 236, 247, 262, 276, 290, 305, 319, 335, 355, 374, 379, 389, 400, 403, 411, 419, 425, 430, 436, 442, 451, 455, 462, 473, 487, 493, 498, 506, 509, 519, 528, 535, 539, 548, 556, 566, 579, 587, 598, 613, 631, 635, 639, 644, 654, 665, 674, 686, 697, 705, 715, 721, 731, 736, 741, 747, 754, 766, 779, 791, 805, 817, 826}
+var _Opcode_index = [...]uint16{0, 3, 6, 9, 13, 17, 21, 26, 34, 45, 59, 65, 70, 77, 88, 98, 106, 113, 120, 130, 138, 149, 166, 186, 198, 209, 222, 239, 255, 264, 270, 279, 288, 303, 313, 322, 333, 348, 362, 376, 396, 411, 425, 441, 461, 480, 485, 495, 506, 509, 517, 525, 531, 536, 542, 548, 557, 561, 568, 579, 593, 599, 604, 612, 629, 649, 652, 662, 671, 678, 682, 691, 699, 709, 722, 730, 741, 756, 774, 795, 819, 823, 827, 832, 842, 853, 862, 874, 889, 903, 914, 926, 944, 953, 963, 971, 981, 987, 997, 1002, 1007, 1013, 1020, 1032, 1050, 1063, 1075, 1089, 1101, 1110}
 
 func (i Opcode) String() string {
 	if i < 0 || i >= Opcode(len(_Opcode_index)-1) {
@@ -136,35 +155,45 @@ func _() {
 	// An "invalid array index" compiler error signifies that the constant values have changed.
 	// Re-run the stringer command to generate them again.
 	var x [1]struct{}
-	_ = x[BuiltinAtan2-0]
-	_ = x[BuiltinClose-1]
-	_ = x[BuiltinCos-2]
-	_ = x[BuiltinExp-3]
-	_ = x[BuiltinFflush-4]
-	_ = x[BuiltinFflushAll-5]
-	_ = x[BuiltinGsub-6]
-	_ = x[BuiltinIndex-7]
-	_ = x[BuiltinInt-8]
-	_ = x[BuiltinLength-9]
-	_ = x[BuiltinLengthArg-10]
-	_ = x[BuiltinLog-11]
-	_ = x[BuiltinMatch-12]
-	_ = x[BuiltinRand-13]
-	_ = x[BuiltinSin-14]
-	_ = x[BuiltinSqrt-15]
-	_ = x[BuiltinSrand-16]
-	_ = x[BuiltinSrandSeed-17]
-	_ = x[BuiltinSub-18]
-	_ = x[BuiltinSubstr-19]
-	_ = x[BuiltinSubstrLength-20]
-	_ = x[BuiltinSystem-21]
-	_ = x[BuiltinTolower-22]
-	_ = x[BuiltinToupper-23]
+	_ = x[BuiltinAnd-0]
+	_ = x[BuiltinAtan2-1]
+	_ = x[BuiltinClose-2]
+	_ = x[BuiltinCompl-3]
+	_ = x[BuiltinCos-4]
+	_ = x[BuiltinExp-5]
+	_ = x[BuiltinFflush-6]
+	_ = x[BuiltinFflushAll-7]
+	_ = x[BuiltinGensub-8]
+	_ = x[BuiltinGsub-9]
+	_ = x[BuiltinIndex-10]
+	_ = x[BuiltinInt-11]
+	_ = x[BuiltinLength-12]
+	_ = x[BuiltinLengthArg-13]
+	_ = x[BuiltinLog-14]
+	_ = x[BuiltinLshift-15]
+	_ = x[BuiltinMatch-16]
+	_ = x[BuiltinMktime-17]
+	_ = x[BuiltinOr-18]
+	_ = x[BuiltinRand-19]
+	_ = x[BuiltinRshift-20]
+	_ = x[BuiltinSin-21]
+	_ = x[BuiltinSqrt-22]
+	_ = x[BuiltinSrand-23]
+	_ = x[BuiltinSrandSeed-24]
+	_ = x[BuiltinStrftime-25]
+	_ = x[BuiltinSub-26]
+	_ = x[BuiltinSubstr-27]
+	_ = x[BuiltinSubstrLength-28]
+	_ = x[BuiltinSystem-29]
+	_ = x[BuiltinSystime-30]
+	_ = x[BuiltinTolower-31]
+	_ = x[BuiltinToupper-32]
+	_ = x[BuiltinXor-33]
 }
 
-const _BuiltinOp_name = "BuiltinAtan2BuiltinCloseBuiltinCosBuiltinExpBuiltinFflushBuiltinFflushAllBuiltinGsubBuiltinIndexBuiltinIntBuiltinLengthBuiltinLengthArgBuiltinLogBuiltinMatchBuiltinRandBuiltinSinBuiltinSqrtBuiltinSrandBuiltinSrandSeedBuiltinSubBuiltinSubstrBuiltinSubstrLengthBuiltinSystemBuiltinTolowerBuiltinToupper"
+const _BuiltinOp_name = "BuiltinAndBuiltinAtan2BuiltinCloseBuiltinComplBuiltinCosBuiltinExpBuiltinFflushBuiltinFflushAllBuiltinGensubBuiltinGsubBuiltinIndexBuiltinIntBuiltinLengthBuiltinLengthArgBuiltinLogBuiltinLshiftBuiltinMatchBuiltinMktimeBuiltinOrBuiltinRandBuiltinRshiftBuiltinSinBuiltinSqrtBuiltinSrandBuiltinSrandSeedBuiltinStrftimeBuiltinSubBuiltinSubstrBuiltinSubstrLengthBuiltinSystemBuiltinSystimeBuiltinTolowerBuiltinToupperBuiltinXor"
 
-var _BuiltinOp_index = [...]uint16{0, 12, 24, 34, 44, 57, 73, 84, 96, 106, 119, 135, 145, 157, 168, 178, 189, 201, 217, 227, 240, 259, 272, 286, 300}
+var _BuiltinOp_index = [...]uint16{0, 10, 22, 34, 46, 56, 66, 79, 95, 108, 119, 131, 141, 154, 170, 180, 193, 205, 218, 227, 238, 251, 261, 272, 284, 300, 315, 325, 338, 357, 370, 384, 398, 412, 422}
 
 func (i BuiltinOp) String() string {
 	if i < 0 || i >= BuiltinOp(len(_BuiltinOp_index)-1) {
@@ -35,14 +35,22 @@ const (
 	ArrayLocal     // arrayIndex
 	InGlobal       // arrayIndex
 	InLocal        // arrayIndex
+	IsArray        // arrayScope arrayIndex
+	ArrayValue     // arrayScope arrayIndex
+
+	// Fetch subarray a[x][y] and store it in the temporary array tempIndex
+	SubArray // arrayScope arrayIndex num tempIndex
 
 	// Assign a field, variable, or array item
 	AssignField
-	AssignGlobal      // index
-	AssignLocal       // index
-	AssignSpecial     // index
-	AssignArrayGlobal // arrayIndex
-	AssignArrayLocal  // arrayIndex
+	AssignFieldByName
+	AssignFieldByNameStr // strIndex
+	AssignGlobal         // index
+	AssignLocal          // index
+	AssignSpecial        // index
+	AssignArrayGlobal    // arrayIndex
+	AssignArrayLocal     // arrayIndex
+	CopyArray            // arrayScope arrayIndex
 
 	// Delete statement
 	Delete    // arrayScope arrayIndex
@@ -50,6 +58,7 @@ const (
 
 	// Post-increment and post-decrement
 	IncrField       // amount
+	IncrFieldByName // amount
 	IncrGlobal      // amount index
 	IncrLocal       // amount index
 	IncrSpecial     // amount index
@@ -58,6 +67,7 @@ const (
 
 	// Augmented assignment (also used for pre-increment and pre-decrement)
 	AugAssignField       // augOp
+	AugAssignFieldByName // augOp
 	AugAssignGlobal      // augOp index
 	AugAssignLocal       // augOp index
 	AugAssignSpecial     // augOp index
@@ -90,6 +100,10 @@ const (
 	Match
 	NotMatch
 
+	// Superinstructions for comparing field $index with a string constant
+	FieldIntEqualsStr    // index strIndex
+	FieldIntNotEqualsStr // index strIndex
+
 	// Unary operators
 	Not
 	UnaryMinus
@@ -106,16 +120,27 @@ const (
 	JumpGreater        // offset
 	JumpLessOrEqual    // offset
 	JumpGreaterOrEqual // offset
+
+	// Jump if field $index is (or isn't) equal to a string constant
+	JumpFieldIntEqualsStr    // index strIndex offset
+	JumpFieldIntNotEqualsStr // index strIndex offset
+
 	Next
 	Exit
 	ForIn // varScope varIndex arrayScope arrayIndex offset
 	BreakForIn
 
 	// Builtin functions
-	CallBuiltin  // builtinOp
-	CallSplit    // arrayScope arrayIndex
-	CallSplitSep // arrayScope arrayIndex
-	CallSprintf  // numArgs
+	CallBuiltin        // builtinOp
+	CallSplit          // arrayScope arrayIndex
+	CallSplitSep       // arrayScope arrayIndex
+	CallSplitSepPat    // arrayScope arrayIndex
+	CallMatchArray     // arrayScope arrayIndex
+	CallSprintf        // numArgs
+	CallPrintrow       // arrayScope arrayIndex
+	CallPrintrowFields // arrayScope arrayIndex fieldsScope fieldsIndex
+	CallAsort          // srcScope srcIndex destScope destIndex
+	CallAsorti         // srcScope srcIndex destScope destIndex
 
 	// User and native functions
 	CallUser   // funcIndex numArrayArgs [arrayScope1 arrayIndex1 ...]
@@ -125,14 +150,15 @@ const (
 	Nulls // numNulls
 
 	// Print, printf, and getline
-	Print          // numArgs redirect
-	Printf         // numArgs redirect
-	Getline        // redirect
-	GetlineField   // redirect
-	GetlineGlobal  // redirect index
-	GetlineLocal   // redirect index
-	GetlineSpecial // redirect index
-	GetlineArray   // redirect arrayScope arrayIndex
+	Print              // numArgs redirect
+	Printf             // numArgs redirect
+	Getline            // redirect
+	GetlineField       // redirect
+	GetlineFieldByName // redirect
+	GetlineGlobal      // redirect index
+	GetlineLocal       // redirect index
+	GetlineSpecial     // redirect index
+	GetlineArray       // redirect arrayScope arrayIndex
 
 	EndOpcode
 )
@@ -153,28 +179,38 @@ const (
 type BuiltinOp Opcode
 
 const (
-	BuiltinAtan2 BuiltinOp = iota
+	BuiltinAnd BuiltinOp = iota
+	BuiltinAtan2
 	BuiltinClose
+	BuiltinCompl
 	BuiltinCos
 	BuiltinExp
 	BuiltinFflush
 	BuiltinFflushAll
+	BuiltinGensub
 	BuiltinGsub
 	BuiltinIndex
 	BuiltinInt
 	BuiltinLength
 	BuiltinLengthArg
 	BuiltinLog
+	BuiltinLshift
 	BuiltinMatch
+	BuiltinMktime
+	BuiltinOr
 	BuiltinRand
+	BuiltinRshift
 	BuiltinSin
 	BuiltinSqrt
 	BuiltinSrand
 	BuiltinSrandSeed
+	BuiltinStrftime
 	BuiltinSub
 	BuiltinSubstr
 	BuiltinSubstrLength
 	BuiltinSystem
+	BuiltinSystime
 	BuiltinTolower
 	BuiltinToupper
+	BuiltinXor
 )
@@ -0,0 +1,349 @@
+// Optimizations done while compiling: constant folding, superinstructions,
+// and jump threading.
+
+package compiler
+
+import (
+	"strconv"
+
+	"github.com/nuvolaris/goawk/internal/ast"
+	"github.com/nuvolaris/goawk/lexer"
+)
+
+// Largest integer magnitude that constant folding operates on or produces.
+// Arithmetic on integers in this range is exact in float64, so the folded
+// result is the same as the result in integer mode and arbitrary-precision
+// mode. Other numbers aren't folded, as those modes treat them differently.
+const maxFoldInt = 1 << 53
+
+// If optimizing, return the constant (*ast.NumExpr or *ast.StrExpr) that
+// expr evaluates to, or nil if it's not a constant expression.
+func (c *compiler) constant(expr ast.Expr) ast.Expr {
+	if !c.optimize || expr == nil {
+		return nil
+	}
+	return foldConstant(expr)
+}
+
+// Evaluate expr if it's a constant expression, returning the resulting
+// *ast.NumExpr or *ast.StrExpr, or nil if it's not constant.
+func foldConstant(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.NumExpr, *ast.StrExpr:
+		return e
+
+	case *ast.UnaryExpr:
+		value := foldConstant(e.Value)
+		if value == nil {
+			return nil
+		}
+		switch e.Op {
+		case lexer.NOT:
+			return boolExpr(!isTruthy(value))
+		case lexer.SUB:
+			// Avoid folding -0, as a negative zero can be printed with %f
+			if n, ok := foldInt(value); ok && n != 0 {
+				return &ast.NumExpr{Value: float64(-n)}
+			}
+		default: // ADD
+			if _, ok := foldInt(value); ok {
+				return value
+			}
+		}
+
+	case *ast.BinaryExpr:
+		left := foldConstant(e.Left)
+		if left == nil {
+			return nil
+		}
+		switch e.Op {
+		case lexer.AND, lexer.OR:
+			// Right side is only evaluated if the left doesn't decide it
+			if isTruthy(left) != (e.Op == lexer.AND) {
+				return boolExpr(e.Op == lexer.OR)
+			}
+			right := foldConstant(e.Right)
+			if right == nil {
+				return nil
+			}
+			return boolExpr(isTruthy(right))
+		}
+		right := foldConstant(e.Right)
+		if right == nil {
+			return nil
+		}
+		switch e.Op {
+		case lexer.CONCAT:
+			l, lOk := constantString(left)
+			r, rOk := constantString(right)
+			if lOk && rOk {
+				return &ast.StrExpr{l + r}
+			}
+		case lexer.EQUALS, lexer.NOT_EQUALS, lexer.LESS, lexer.LTE, lexer.GREATER, lexer.GTE:
+			if cmp, ok := compareConstants(left, right); ok {
+				return boolExpr(comparisonResult(e.Op, cmp))
+			}
+		case lexer.ADD, lexer.SUB, lexer.MUL, lexer.DIV, lexer.MOD:
+			l, lOk := foldInt(left)
+			r, rOk := foldInt(right)
+			if lOk && rOk {
+				if n, ok := foldArithmetic(e.Op, l, r); ok {
+					return &ast.NumExpr{Value: float64(n)}
+				}
+			}
+		}
+
+	case *ast.CondExpr:
+		cond := foldConstant(e.Cond)
+		if cond == nil {
+			return nil
+		}
+		if isTruthy(cond) {
+			return foldConstant(e.True)
+		}
+		return foldConstant(e.False)
+	}
+	return nil
+}
+
+// Report whether the constant value is true: a number is true if it's
+// non-zero, and a string if it's non-empty (even "0").
+func isTruthy(value ast.Expr) bool {
+	switch v := value.(type) {
+	case *ast.NumExpr:
+		return v.Value != 0
+	default:
+		return v.(*ast.StrExpr).Value != ""
+	}
+}
+
+// Return a constant for the result of a boolean operation (1 or 0).
+func boolExpr(b bool) ast.Expr {
+	if b {
+		return &ast.NumExpr{Value: 1}
+	}
+	return &ast.NumExpr{Value: 0}
+}
+
+// Return the value of constant as an integer if it's a number constant in
+// the range that can be folded.
+func foldInt(value ast.Expr) (int64, bool) {
+	e, ok := value.(*ast.NumExpr)
+	if !ok || e.Value < -maxFoldInt || e.Value > maxFoldInt || e.Value != float64(int64(e.Value)) ||
+		exactText(e) != "" {
+		return 0, false
+	}
+	return int64(e.Value), true
+}
+
+// Return the string value of a constant. Only integer number constants are
+// converted, as other numbers are converted using CONVFMT, which can change
+// at runtime.
+func constantString(value ast.Expr) (string, bool) {
+	if e, ok := value.(*ast.StrExpr); ok {
+		return e.Value, true
+	}
+	if n, ok := foldInt(value); ok {
+		return strconv.FormatInt(n, 10), true
+	}
+	return "", false
+}
+
+// Compare two constants the way the interpreter does: numerically if they're
+// both numbers, otherwise as strings. Returns -1, 0, or 1, and false if they
+// can't be compared at compile time.
+func compareConstants(left, right ast.Expr) (int, bool) {
+	l, lIsNum := left.(*ast.NumExpr)
+	r, rIsNum := right.(*ast.NumExpr)
+	if lIsNum && rIsNum && exactText(l) == "" && exactText(r) == "" {
+		switch {
+		case l.Value < r.Value:
+			return -1, true
+		case l.Value > r.Value:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	ls, lOk := constantString(left)
+	rs, rOk := constantString(right)
+	if !lOk || !rOk {
+		return 0, false
+	}
+	switch {
+	case ls < rs:
+		return -1, true
+	case ls > rs:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+// Return the result of comparison operator op given the result of
+// comparing the operands (-1, 0, or 1).
+func comparisonResult(op lexer.Token, cmp int) bool {
+	switch op {
+	case lexer.EQUALS:
+		return cmp == 0
+	case lexer.NOT_EQUALS:
+		return cmp != 0
+	case lexer.LESS:
+		return cmp < 0
+	case lexer.LTE:
+		return cmp <= 0
+	case lexer.GREATER:
+		return cmp > 0
+	default: // GTE
+		return cmp >= 0
+	}
+}
+
+// Perform arithmetic operation op on integers l and r, returning false if
+// the result can't be folded: if it's not an integer, is out of range,
+// would be a negative zero, or is a division by zero (which is left as a
+// runtime error).
+func foldArithmetic(op lexer.Token, l, r int64) (int64, bool) {
+	var n int64
+	switch op {
+	case lexer.ADD:
+		n = l + r
+	case lexer.SUB:
+		n = l - r
+	case lexer.MUL:
+		if l != 0 && (r > maxFoldInt/abs(l) || r < -maxFoldInt/abs(l)) {
+			return 0, false
+		}
+		n = l * r
+		if n == 0 && (l < 0 || r < 0) {
+			return 0, false
+		}
+	case lexer.DIV:
+		if r == 0 || l%r != 0 {
+			return 0, false
+		}
+		n = l / r
+		if n == 0 && r < 0 {
+			return 0, false
+		}
+	default: // MOD
+		if r == 0 {
+			return 0, false
+		}
+		n = l % r
+		if n == 0 && l < 0 {
+			return 0, false
+		}
+	}
+	if n < -maxFoldInt || n > maxFoldInt {
+		return 0, false
+	}
+	return n, true
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Merge adjacent constants in a list of values to concatenate (which is in
+// right to left order), so that for example x "a" "b" is compiled as x "ab".
+func (c *compiler) mergeConstants(values []ast.Expr) []ast.Expr {
+	var merged []ast.Expr
+	for _, value := range values {
+		s, ok := constantString(c.constant(value))
+		if ok && len(merged) > 0 {
+			if right, ok := constantString(c.constant(merged[len(merged)-1])); ok {
+				merged[len(merged)-1] = &ast.StrExpr{s + right}
+				continue
+			}
+		}
+		merged = append(merged, value)
+	}
+	return merged
+}
+
+// Return the field index as an opcode if e is a field expression with a
+// constant integer index, like $1.
+func (c *compiler) fieldIntIndex(e *ast.FieldExpr) (Opcode, bool) {
+	index := e.Index
+	if value := c.constant(index); value != nil {
+		index = value
+	}
+	n, ok := index.(*ast.NumExpr)
+	if !ok || n.Value != float64(Opcode(n.Value)) {
+		return 0, false
+	}
+	return opcodeInt(int(n.Value)), true
+}
+
+// If optimizing and expr compares a constant field like $1 with a string
+// constant (in either order), return the field index and the string's
+// index, so the comparison can be done with a single superinstruction.
+func (c *compiler) fieldStrComparison(expr *ast.BinaryExpr) (index, strIndex Opcode, ok bool) {
+	if !c.optimize {
+		return 0, 0, false
+	}
+	field, isField := expr.Left.(*ast.FieldExpr)
+	other := expr.Right
+	if !isField {
+		field, isField = expr.Right.(*ast.FieldExpr)
+		other = expr.Left
+		if !isField {
+			return 0, 0, false
+		}
+	}
+	s, isStr := c.constant(other).(*ast.StrExpr)
+	if !isStr {
+		return 0, 0, false
+	}
+	index, ok = c.fieldIntIndex(field)
+	if !ok {
+		return 0, 0, false
+	}
+	return index, opcodeInt(c.strIndex(s.Value)), true
+}
+
+// Address of a jump instruction and of its offset argument (the last one).
+type jumpAddr struct {
+	op     int
+	offset int
+}
+
+// Jump threading: change jumps that land on an unconditional jump to jump
+// straight to its target instead. Jumps at the start of a statement, such
+// as a "break", are left alone so that debugger breakpoints still work.
+func (c *compiler) threadJumps() {
+	targets := make(map[int]int)
+	forInEnds := make(map[int]bool)
+	for _, j := range c.jumps {
+		target := j.offset + 1 + int(c.code[j.offset])
+		switch {
+		case c.code[j.op] == ForIn:
+			// The body of a for-in loop is executed separately, so jumps
+			// to the end of the body (by "continue") must stay there.
+			forInEnds[target] = true
+		case c.code[j.op] == Jump && c.lines.Index(j.op) < 0:
+			targets[j.op] = target
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	for _, j := range c.jumps {
+		if c.code[j.op] == ForIn {
+			continue
+		}
+		target := j.offset + 1 + int(c.code[j.offset])
+		for i := 0; i < len(targets) && !forInEnds[target]; i++ { // limit in case of a loop
+			next, ok := targets[target]
+			if !ok {
+				break
+			}
+			target = next
+		}
+		c.code[j.offset] = opcodeInt(target - (j.offset + 1))
+	}
+}
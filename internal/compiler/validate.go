@@ -0,0 +1,694 @@
+// Validation of loaded programs, so that a bad program is reported as an
+// error rather than crashing the virtual machine.
+
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nuvolaris/goawk/internal/ast"
+	"github.com/nuvolaris/goawk/lexer"
+)
+
+// Check that the program's tables are consistent, and that its code only
+// has valid opcodes with valid constant, variable, function, and jump
+// operands, and never pops more values off the stack than it has pushed.
+func (p *Program) validate() error {
+	numArrays := len(p.arrayNames) - p.TempArrays
+	if p.TempArrays < 0 || numArrays < 0 {
+		return fmt.Errorf("invalid number of temporary arrays %d", p.TempArrays)
+	}
+	if len(p.NumTexts) != len(p.Nums) {
+		return errors.New("number tables have different lengths")
+	}
+	if len(p.ScalarChanges) != len(p.scalarNames) || len(p.ArrayChanges) != numArrays {
+		return errors.New("variable tables have different lengths")
+	}
+	for _, changes := range [][]VarChange{p.ScalarChanges, p.ArrayChanges} {
+		for _, change := range changes {
+			if change > ChangeOther {
+				return fmt.Errorf("invalid variable change %d", change)
+			}
+		}
+	}
+	err := checkUnique("scalar", p.scalarNames)
+	if err != nil {
+		return err
+	}
+	err = checkUnique("array", p.arrayNames[:numArrays])
+	if err != nil {
+		return err
+	}
+	for _, f := range p.Functions {
+		numArrays := 0
+		for _, isArray := range f.Arrays {
+			if isArray {
+				numArrays++
+			}
+		}
+		if len(f.Arrays) != len(f.Params) || f.NumArrays != numArrays || f.NumScalars != len(f.Params)-numArrays {
+			return fmt.Errorf("function %q has invalid parameters", f.Name)
+		}
+	}
+
+	err = p.validateCode("BEGIN", p.Begin, nil, 0)
+	if err != nil {
+		return err
+	}
+	for _, action := range p.Actions {
+		if len(action.Pattern) > 2 {
+			return fmt.Errorf("pattern has %d parts", len(action.Pattern))
+		}
+		for _, pattern := range action.Pattern {
+			// Patterns leave their result on the stack.
+			err = p.validateCode("pattern", pattern, nil, 1)
+			if err != nil {
+				return err
+			}
+		}
+		err = p.validateCode("action", action.Body, nil, 0)
+		if err != nil {
+			return err
+		}
+	}
+	err = p.validateCode("END", p.End, nil, 0)
+	if err != nil {
+		return err
+	}
+	for i := range p.Functions {
+		f := &p.Functions[i]
+		err = p.validateCode("function "+f.Name, f.Body, f, 0)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Return an error if names has duplicates.
+func checkUnique(kind string, names []string) error {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			return fmt.Errorf("duplicate %s name %q", kind, name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// Validate a single block of code. f is the function it's in (or nil),
+// and results is the number of values it must leave on the stack.
+func (p *Program) validateCode(name string, code []Opcode, f *Function, results int) error {
+	v := &validator{program: p, function: f, code: code, loop: -1}
+	v.decode()
+	if v.err == nil {
+		v.checkStack(results)
+	}
+	if v.err != nil {
+		return fmt.Errorf("%s: %v", name, v.err)
+	}
+	return nil
+}
+
+// Validates a single block of code. The operands of each opcode are read
+// in the same way as the disassembler does.
+type validator struct {
+	program  *Program
+	function *Function
+	code     []Opcode
+	ip       int
+	err      error
+
+	instrs []instruction
+	in     *instruction // instruction being decoded
+	loops  []forInLoop
+	loop   int // innermost for-in loop being decoded, or -1
+}
+
+// A decoded instruction, with its effect on the stack and where execution
+// can go next.
+type instruction struct {
+	addr   int
+	end    int  // address of the next instruction
+	pops   int  // number of values it needs on the stack
+	pushes int  // number of values it leaves in their place
+	next   bool // whether execution can continue with the next instruction
+	target int  // address it may jump to, or -1
+	breaks bool // whether it breaks out of the for-in loop it's in
+	loop   int  // index of the innermost for-in loop it's in, or -1
+}
+
+// The body of a for-in loop, which the virtual machine executes as a
+// separate block of code, so jumps in the body must stay within it.
+type forInLoop struct {
+	start  int
+	end    int
+	parent int
+}
+
+// Decode the instructions and check their operands.
+func (v *validator) decode() {
+	for v.ip < len(v.code) && v.err == nil {
+		for v.loop >= 0 && v.ip >= v.loops[v.loop].end {
+			v.loop = v.loops[v.loop].parent
+		}
+		v.in = &instruction{addr: v.ip, next: true, target: -1, loop: v.loop}
+		op := v.fetch()
+
+		switch op {
+		case Nop:
+
+		case Num:
+			v.index("number", v.fetch(), len(v.program.Nums))
+			v.stack(0, 1)
+
+		case Str, FieldByNameStr:
+			v.index("string", v.fetch(), len(v.program.Strs))
+			v.stack(0, 1)
+
+		case AssignFieldByNameStr:
+			v.index("string", v.fetch(), len(v.program.Strs))
+			v.stack(1, 0)
+
+		case Regex:
+			v.index("regex", v.fetch(), len(v.program.Regexes))
+			v.stack(0, 1)
+
+		case Dupe:
+			v.stack(1, 2)
+
+		case Drop:
+			v.stack(1, 0)
+
+		case Swap:
+			v.stack(2, 2)
+
+		case Field, FieldByName, Not, UnaryMinus, UnaryPlus, Boolean:
+			v.stack(1, 1)
+
+		case FieldInt:
+			v.fetch()
+			v.stack(0, 1)
+
+		case Global:
+			v.global(v.fetch())
+			v.stack(0, 1)
+
+		case Local:
+			v.local(v.fetch())
+			v.stack(0, 1)
+
+		case Special:
+			v.special(v.fetch())
+			v.stack(0, 1)
+
+		case ArrayGlobal, InGlobal:
+			v.globalArray(v.fetch())
+			v.stack(1, 1)
+
+		case ArrayLocal, InLocal:
+			v.localArray(v.fetch())
+			v.stack(1, 1)
+
+		case IsArray, CallSplit, CallAsort, CallAsorti:
+			v.array()
+			if op == CallAsort || op == CallAsorti {
+				v.array()
+			}
+			v.stack(1, 1)
+
+		case ArrayValue, CallPrintrow, CallPrintrowFields:
+			v.array()
+			if op == CallPrintrowFields {
+				v.array()
+			}
+			v.stack(0, 1)
+
+		case SubArray:
+			v.array()
+			numSubscripts := v.count(v.fetch(), 0)
+			v.globalArray(v.fetch())
+			v.stack(numSubscripts, 0)
+
+		case AssignField, AssignFieldByName, AugAssignField, AugAssignFieldByName:
+			if op == AugAssignField || op == AugAssignFieldByName {
+				v.augOp(v.fetch())
+			}
+			v.stack(2, 0)
+
+		case AssignGlobal:
+			v.global(v.fetch())
+			v.stack(1, 0)
+
+		case AssignLocal:
+			v.local(v.fetch())
+			v.stack(1, 0)
+
+		case AssignSpecial:
+			v.special(v.fetch())
+			v.stack(1, 0)
+
+		case AssignArrayGlobal:
+			v.globalArray(v.fetch())
+			v.stack(2, 0)
+
+		case AssignArrayLocal:
+			v.localArray(v.fetch())
+			v.stack(2, 0)
+
+		case CopyArray, Delete:
+			v.array()
+			v.stack(1, 0)
+
+		case DeleteAll:
+			v.array()
+
+		case IncrField, IncrFieldByName:
+			v.fetch()
+			v.stack(1, 0)
+
+		case IncrGlobal:
+			v.fetch()
+			v.global(v.fetch())
+
+		case IncrLocal:
+			v.fetch()
+			v.local(v.fetch())
+
+		case IncrSpecial:
+			v.fetch()
+			v.special(v.fetch())
+
+		case IncrArrayGlobal:
+			v.fetch()
+			v.globalArray(v.fetch())
+			v.stack(1, 0)
+
+		case IncrArrayLocal:
+			v.fetch()
+			v.localArray(v.fetch())
+			v.stack(1, 0)
+
+		case AugAssignGlobal:
+			v.augOp(v.fetch())
+			v.global(v.fetch())
+			v.stack(1, 0)
+
+		case AugAssignLocal:
+			v.augOp(v.fetch())
+			v.local(v.fetch())
+			v.stack(1, 0)
+
+		case AugAssignSpecial:
+			v.augOp(v.fetch())
+			v.special(v.fetch())
+			v.stack(1, 0)
+
+		case AugAssignArrayGlobal:
+			v.augOp(v.fetch())
+			v.globalArray(v.fetch())
+			v.stack(2, 0)
+
+		case AugAssignArrayLocal:
+			v.augOp(v.fetch())
+			v.localArray(v.fetch())
+			v.stack(2, 0)
+
+		case IndexMulti, ConcatMulti:
+			v.stack(v.count(v.fetch(), 0), 1)
+
+		case Add, Subtract, Multiply, Divide, Power, Modulo, Equals, NotEquals,
+			Less, Greater, LessOrEqual, GreaterOrEqual, Concat, Match, NotMatch:
+			v.stack(2, 1)
+
+		case FieldIntEqualsStr, FieldIntNotEqualsStr:
+			v.fetch()
+			v.index("string", v.fetch(), len(v.program.Strs))
+			v.stack(0, 1)
+
+		case Jump:
+			v.jump(v.fetch())
+			v.in.next = false
+
+		case JumpFalse, JumpTrue:
+			v.jump(v.fetch())
+			v.stack(1, 0)
+
+		case JumpEquals, JumpNotEquals, JumpLess, JumpGreater, JumpLessOrEqual, JumpGreaterOrEqual:
+			v.jump(v.fetch())
+			v.stack(2, 0)
+
+		case JumpFieldIntEqualsStr, JumpFieldIntNotEqualsStr:
+			v.fetch()
+			v.index("string", v.fetch(), len(v.program.Strs))
+			v.jump(v.fetch())
+
+		case Next, ReturnNull:
+			v.in.next = false
+
+		case Exit, Return:
+			v.stack(1, 0)
+			v.in.next = false
+
+		case ForIn:
+			varScope := ast.VarScope(v.fetch())
+			varIndex := v.fetch()
+			switch varScope {
+			case ast.ScopeGlobal:
+				v.global(varIndex)
+			case ast.ScopeLocal:
+				v.local(varIndex)
+			case ast.ScopeSpecial:
+				v.special(varIndex)
+			default:
+				v.fail(v.in.addr, "invalid variable scope %d", varScope)
+			}
+			v.array()
+			offset := v.fetch()
+			if offset < 0 {
+				v.fail(v.in.addr, "invalid loop body length %d", offset)
+				break
+			}
+			v.jump(offset)
+			v.loops = append(v.loops, forInLoop{start: v.ip, end: v.ip + int(offset), parent: v.loop})
+			v.loop = len(v.loops) - 1
+
+		case BreakForIn:
+			v.in.breaks = true
+			v.in.next = false
+
+		case CallBuiltin:
+			builtinOp := BuiltinOp(v.fetch())
+			pops, pushes, ok := builtinStack(builtinOp)
+			if !ok {
+				v.fail(v.in.addr, "invalid builtin %d", builtinOp)
+			}
+			v.stack(pops, pushes)
+
+		case CallSplitSep, CallMatchArray:
+			v.array()
+			v.stack(2, 1)
+
+		case CallSplitSepPat:
+			v.array()
+			v.stack(3, 1)
+
+		case CallSprintf:
+			v.stack(v.count(v.fetch(), 1), 1) // format string and values
+
+		case CallUser:
+			funcIndex := v.fetch()
+			numArrayArgs := v.fetch()
+			if !v.index("function", funcIndex, len(v.program.Functions)) {
+				break
+			}
+			f := v.program.Functions[funcIndex]
+			if numArrayArgs < 0 || int(numArrayArgs) > f.NumArrays {
+				v.fail(v.in.addr, "invalid number of array arguments %d", numArrayArgs)
+				break
+			}
+			for i := 0; i < int(numArrayArgs); i++ {
+				v.array()
+			}
+			v.stack(f.NumScalars, 1)
+
+		case CallNative:
+			funcIndex := v.fetch()
+			numArgs := v.count(v.fetch(), 0)
+			if v.index("native function", funcIndex, len(v.program.nativeFuncs)) &&
+				v.program.nativeFuncs[funcIndex].Name == "" {
+				v.fail(v.in.addr, "native function %d has no name", funcIndex)
+			}
+			v.stack(numArgs, 1)
+
+		case Nulls:
+			v.stack(0, v.count(v.fetch(), 0))
+
+		case Print, Printf:
+			minArgs := Opcode(0)
+			if op == Printf {
+				minArgs = 1 // format string
+			}
+			numArgs := v.count(v.fetch(), minArgs)
+			switch lexer.Token(v.fetch()) {
+			case lexer.ILLEGAL:
+			case lexer.GREATER, lexer.APPEND, lexer.PIPE:
+				numArgs++ // destination
+			default:
+				v.fail(v.in.addr, "invalid output redirect")
+			}
+			v.stack(numArgs, 0)
+
+		case Getline, GetlineField:
+			v.stack(v.inputRedirect(v.fetch()), 1)
+
+		case GetlineFieldByName:
+			v.stack(v.inputRedirect(v.fetch())+1, 1)
+
+		case GetlineGlobal:
+			pops := v.inputRedirect(v.fetch())
+			v.global(v.fetch())
+			v.stack(pops, 1)
+
+		case GetlineLocal:
+			pops := v.inputRedirect(v.fetch())
+			v.local(v.fetch())
+			v.stack(pops, 1)
+
+		case GetlineSpecial:
+			pops := v.inputRedirect(v.fetch())
+			v.special(v.fetch())
+			v.stack(pops, 1)
+
+		case GetlineArray:
+			pops := v.inputRedirect(v.fetch())
+			v.array()
+			v.stack(pops+1, 1)
+
+		default:
+			v.fail(v.in.addr, "invalid opcode %d", op)
+		}
+
+		v.in.end = v.ip
+		v.instrs = append(v.instrs, *v.in)
+	}
+}
+
+// Return the number of values a builtin function pops off the stack and
+// pushes, or false if it's not a valid builtin.
+func builtinStack(op BuiltinOp) (pops, pushes int, ok bool) {
+	switch op {
+	case BuiltinFflushAll, BuiltinLength, BuiltinRand, BuiltinSrand, BuiltinSystime:
+		return 0, 1, true
+	case BuiltinClose, BuiltinCompl, BuiltinCos, BuiltinExp, BuiltinFflush, BuiltinInt,
+		BuiltinLengthArg, BuiltinLog, BuiltinSin, BuiltinSqrt, BuiltinSrandSeed,
+		BuiltinSystem, BuiltinTolower, BuiltinToupper:
+		return 1, 1, true
+	case BuiltinAnd, BuiltinAtan2, BuiltinIndex, BuiltinLshift, BuiltinMatch,
+		BuiltinMktime, BuiltinOr, BuiltinRshift, BuiltinSubstr, BuiltinXor:
+		return 2, 1, true
+	case BuiltinSub, BuiltinGsub:
+		return 3, 2, true // number of replacements and new target value
+	case BuiltinStrftime, BuiltinSubstrLength:
+		return 3, 1, true
+	case BuiltinGensub:
+		return 4, 1, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// Check that no path through the code pops more values than have been
+// pushed, by finding the smallest stack depth at the start of each
+// instruction. Also check that at least results values are left at the
+// end.
+func (v *validator) checkStack(results int) {
+	instrAt := make([]int, len(v.code)+1)
+	for i := range instrAt {
+		instrAt[i] = -1
+	}
+	for i, in := range v.instrs {
+		instrAt[in.addr] = i
+	}
+	for _, in := range v.instrs {
+		if in.target >= 0 && in.target < len(v.code) && instrAt[in.target] < 0 {
+			v.fail(in.addr, "jump to invalid address 0x%04x", in.target)
+			return
+		}
+	}
+
+	depths := make([]int, len(v.code)+1)
+	for i := range depths {
+		depths[i] = -1
+	}
+	var work []int
+	var flow func(loop, addr, depth int)
+	flow = func(loop, addr, depth int) {
+		if depths[addr] >= 0 && depths[addr] <= depth {
+			return
+		}
+		depths[addr] = depth
+		if addr < len(v.code) {
+			work = append(work, addr)
+		}
+		// Reaching the end of a for-in loop's body starts its next
+		// iteration.
+		for ; loop >= 0; loop = v.loops[loop].parent {
+			if addr == v.loops[loop].end {
+				flow(v.loops[loop].parent, v.loops[loop].start, depth)
+			}
+		}
+	}
+	flow(-1, 0, 0)
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+		in := v.instrs[instrAt[addr]]
+		depth := depths[addr]
+		if depth < in.pops {
+			v.fail(in.addr, "stack underflow")
+			return
+		}
+		depth += in.pushes - in.pops
+		if in.next {
+			flow(in.loop, in.end, depth)
+		}
+		if in.target >= 0 {
+			flow(in.loop, in.target, depth)
+		}
+		if in.breaks && in.loop >= 0 {
+			loop := v.loops[in.loop]
+			flow(loop.parent, loop.end, depth)
+		}
+	}
+	if depths[len(v.code)] >= 0 && depths[len(v.code)] < results {
+		v.fail(len(v.code), "stack underflow at end")
+	}
+}
+
+// Fetch the next operand, failing if there are no more.
+func (v *validator) fetch() Opcode {
+	if v.ip >= len(v.code) {
+		v.fail(v.in.addr, "missing operand")
+		return 0
+	}
+	op := v.code[v.ip]
+	v.ip++
+	return op
+}
+
+// Record the first error, along with the address of the instruction.
+func (v *validator) fail(addr int, format string, args ...interface{}) {
+	if v.err == nil {
+		v.err = fmt.Errorf("%04x: %s", addr, fmt.Sprintf(format, args...))
+	}
+}
+
+// Set the stack effect of the instruction being decoded.
+func (v *validator) stack(pops, pushes int) {
+	v.in.pops = pops
+	v.in.pushes = pushes
+}
+
+// Check that index is a valid index into a table of length n, and report
+// whether it is.
+func (v *validator) index(kind string, index Opcode, n int) bool {
+	if index < 0 || int(index) >= n {
+		v.fail(v.in.addr, "invalid %s index %d", kind, index)
+		return false
+	}
+	return true
+}
+
+// Check that a count is at least min, and return it.
+func (v *validator) count(n, min Opcode) int {
+	if n < min {
+		v.fail(v.in.addr, "invalid count %d", n)
+		return 0
+	}
+	return int(n)
+}
+
+func (v *validator) global(index Opcode) {
+	v.index("global", index, len(v.program.scalarNames))
+}
+
+func (v *validator) local(index Opcode) {
+	if v.function == nil {
+		v.fail(v.in.addr, "local variable outside function")
+		return
+	}
+	v.index("local", index, v.function.NumScalars)
+}
+
+func (v *validator) special(index Opcode) {
+	if index <= ast.V_ILLEGAL || index > ast.V_LAST {
+		v.fail(v.in.addr, "invalid special variable index %d", index)
+	}
+}
+
+// Global arrays include the temporary arrays used for subarrays.
+func (v *validator) globalArray(index Opcode) {
+	v.index("global array", index, len(v.program.arrayNames))
+}
+
+func (v *validator) localArray(index Opcode) {
+	if v.function == nil {
+		v.fail(v.in.addr, "local array outside function")
+		return
+	}
+	v.index("local array", index, v.function.NumArrays)
+}
+
+// Fetch and check an arrayScope arrayIndex pair of operands.
+func (v *validator) array() {
+	scope := ast.VarScope(v.fetch())
+	index := v.fetch()
+	switch scope {
+	case ast.ScopeGlobal:
+		v.globalArray(index)
+	case ast.ScopeLocal:
+		v.localArray(index)
+	default:
+		v.fail(v.in.addr, "invalid array scope %d", scope)
+	}
+}
+
+func (v *validator) augOp(op Opcode) {
+	if op < 0 || strings.HasPrefix(AugOp(op).String(), "AugOp(") {
+		v.fail(v.in.addr, "invalid augmented assignment operation %d", op)
+	}
+}
+
+// Check a getline redirect, and return the number of values it pops (the
+// command or filename).
+func (v *validator) inputRedirect(redirect Opcode) int {
+	switch lexer.Token(redirect) {
+	case lexer.ILLEGAL:
+		return 0
+	case lexer.PIPE, lexer.LESS:
+		return 1
+	default:
+		v.fail(v.in.addr, "invalid input redirect")
+		return 0
+	}
+}
+
+// Record a jump by offset (relative to the address after the instruction),
+// failing if it's outside the code (or the for-in loop body the jump is
+// in). Whether it jumps to the start of an instruction is checked later.
+func (v *validator) jump(offset Opcode) {
+	start, end := 0, len(v.code)
+	if v.loop >= 0 {
+		start, end = v.loops[v.loop].start, v.loops[v.loop].end
+	}
+	target := v.ip + int(offset)
+	if target < start || target > end {
+		v.fail(v.in.addr, "jump to invalid address 0x%04x", target)
+		return
+	}
+	v.in.target = target
+}
@@ -0,0 +1,62 @@
+package compiler
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/nuvolaris/goawk/internal/ast"
+	"github.com/nuvolaris/goawk/lexer"
+)
+
+func TestValidate(t *testing.T) {
+	global := Opcode(ast.ScopeGlobal)
+	tests := []struct {
+		name    string
+		begin   []Opcode
+		pattern []Opcode
+		err     string
+	}{
+		{"valid", []Opcode{Num, 0, Global, 0, Add, Drop}, []Opcode{Global, 0}, ""},
+		{"opcode", []Opcode{-1}, nil, "BEGIN: 0000: invalid opcode -1"},
+		{"operand", []Opcode{Num}, nil, "BEGIN: 0000: missing operand"},
+		{"number", []Opcode{Num, 1, Drop}, nil, "BEGIN: 0000: invalid number index 1"},
+		{"global", []Opcode{Global, -1, Drop}, nil, "BEGIN: 0000: invalid global index -1"},
+		{"local", []Opcode{Local, 0, Drop}, nil, "BEGIN: 0000: local variable outside function"},
+		{"array", []Opcode{DeleteAll, global, 1}, nil, "BEGIN: 0000: invalid global array index 1"},
+		{"function", []Opcode{CallUser, 1, 0, Drop}, nil, "BEGIN: 0000: invalid function index 1"},
+		{"count", []Opcode{Printf, 0, Opcode(lexer.ILLEGAL)}, nil, "BEGIN: 0000: invalid count 0"},
+		{"jump", []Opcode{Jump, 5}, nil, "BEGIN: 0000: jump to invalid address 0x0007"},
+		{"jump operand", []Opcode{Jump, 1, Num, 0, Drop}, nil, "BEGIN: 0000: jump to invalid address 0x0003"},
+		{"loop jump", []Opcode{ForIn, global, 0, global, 0, 2, Jump, 1, Nop}, nil, "BEGIN: 0006: jump to invalid address 0x0009"},
+		{"underflow", []Opcode{Num, 0, Add, Drop}, nil, "BEGIN: 0002: stack underflow"},
+		{"pattern", nil, []Opcode{Nop}, "pattern: 0001: stack underflow at end"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := Program{
+				Begin:         test.begin,
+				Actions:       []Action{{Pattern: [][]Opcode{test.pattern}}},
+				Functions:     []Function{{Name: "f", Params: []string{"x"}, Arrays: []bool{false}, NumScalars: 1}},
+				Nums:          []float64{0},
+				NumTexts:      []string{""},
+				Strs:          []string{""},
+				Regexes:       []*regexp.Regexp{regexp.MustCompile("")},
+				ScalarChanges: []VarChange{ChangeNone},
+				ArrayChanges:  []VarChange{ChangeNone},
+				scalarNames:   []string{"s"},
+				arrayNames:    []string{"a"},
+				nativeFuncs:   []ast.NativeFunc{{Name: "n"}},
+			}
+			err := p.validate()
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.err {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
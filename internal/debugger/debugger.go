@@ -0,0 +1,301 @@
+// Package debugger implements the interactive command-line debugger used by
+// "goawk -debug".
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nuvolaris/goawk/internal/parseutil"
+	"github.com/nuvolaris/goawk/interp"
+)
+
+const help = `Debugger commands:
+  break [file:]line   set breakpoint (abbreviation b)
+  clear [file:]line   clear breakpoint
+  info break          list breakpoints
+  step                stop at next statement, entering functions (s)
+  next                stop at next statement, skipping over functions (n)
+  finish              stop when current function returns
+  continue            run until next breakpoint (c)
+  print expr          print variable, array, array element, or $field (p)
+  locals              print local variables of current function
+  globals             print global variables
+  backtrace           print call stack (bt)
+  list                show source around current line (l)
+  quit                stop program and exit (q)
+  help                show this help message
+An empty line repeats the previous command.
+`
+
+// REPL is an interactive debugger that reads commands from an input reader.
+type REPL struct {
+	in         *bufio.Scanner
+	out        io.Writer
+	fileReader *parseutil.FileReader
+	lines      []string
+	debugger   *interp.Debugger
+	lastCmd    string
+}
+
+// New creates a debugger REPL for the given program source, reading
+// commands from in and writing output to out.
+func New(in io.Reader, out io.Writer, fileReader *parseutil.FileReader) *REPL {
+	r := &REPL{
+		in:         bufio.NewScanner(in),
+		out:        out,
+		fileReader: fileReader,
+		lines:      strings.Split(string(fileReader.Source()), "\n"),
+	}
+	r.debugger = interp.NewDebugger(r.stop)
+	return r
+}
+
+// Debugger returns the debugger to set in interp.Config.
+func (r *REPL) Debugger() *interp.Debugger {
+	return r.debugger
+}
+
+// Called by the interpreter when execution stops at a statement.
+func (r *REPL) stop(s *interp.DebugState) interp.DebugCmd {
+	r.showPos(s)
+	for {
+		fmt.Fprint(r.out, "(debug) ")
+		if !r.in.Scan() {
+			fmt.Fprintln(r.out)
+			return interp.DebugQuit
+		}
+		line := strings.TrimSpace(r.in.Text())
+		if line == "" {
+			line = r.lastCmd
+		}
+		r.lastCmd = line
+		cmd, arg := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		switch cmd {
+		case "":
+		case "b", "break":
+			r.setBreakpoint(s, arg)
+		case "clear":
+			r.clearBreakpoint(s, arg)
+		case "info":
+			if arg != "break" {
+				r.printf("usage: info break")
+				break
+			}
+			r.showBreakpoints()
+		case "s", "step":
+			return interp.DebugStep
+		case "n", "next":
+			return interp.DebugNext
+		case "finish":
+			if s.Depth() == 0 {
+				r.printf("not in a function")
+				break
+			}
+			return interp.DebugFinish
+		case "c", "continue":
+			return interp.DebugContinue
+		case "p", "print":
+			r.print(s, arg)
+		case "locals":
+			for _, name := range s.Locals() {
+				r.printVar(s, name)
+			}
+		case "globals":
+			for _, name := range s.Globals() {
+				r.printVar(s, name)
+			}
+		case "bt", "backtrace":
+			for i, frame := range s.Stack() {
+				r.printf("#%d  %s at %s", i, frame.Block, r.posString(frame.Pos.Line))
+			}
+		case "l", "list":
+			r.list(s.Pos().Line)
+		case "q", "quit":
+			return interp.DebugQuit
+		case "h", "help":
+			fmt.Fprint(r.out, help)
+		default:
+			r.printf("unknown command %q (type \"help\" for help)", cmd)
+		}
+	}
+}
+
+func (r *REPL) printf(format string, args ...interface{}) {
+	fmt.Fprintf(r.out, format+"\n", args...)
+}
+
+func (r *REPL) showPos(s *interp.DebugState) {
+	line := s.Pos().Line
+	_, fileLine := r.fileReader.FileLine(line)
+	r.printf("%s at %s:", s.Block(), r.posString(line))
+	r.printf("%5d  %s", fileLine, r.sourceLine(line))
+}
+
+func (r *REPL) posString(line int) string {
+	path, fileLine := r.fileReader.FileLine(line)
+	return path + ":" + strconv.Itoa(fileLine)
+}
+
+func (r *REPL) sourceLine(line int) string {
+	if line < 1 || line > len(r.lines) {
+		return ""
+	}
+	return strings.Replace(r.lines[line-1], "\t", "    ", -1)
+}
+
+// Show the source lines around the given line (in the same file).
+func (r *REPL) list(line int) {
+	path, _ := r.fileReader.FileLine(line)
+	for i := line - 5; i <= line+5; i++ {
+		iPath, fileLine := r.fileReader.FileLine(i)
+		if iPath != path {
+			continue
+		}
+		marker := " "
+		if i == line {
+			marker = ">"
+		}
+		r.printf("%s%4d  %s", marker, fileLine, r.sourceLine(i))
+	}
+}
+
+// Parse a breakpoint location of the form "line" or "file:line", where line
+// is relative to the current file when no file is given.
+func (r *REPL) parseLocation(s *interp.DebugState, arg string) (int, bool) {
+	path, _ := r.fileReader.FileLine(s.Pos().Line)
+	lineStr := arg
+	if i := strings.LastIndexByte(arg, ':'); i >= 0 {
+		path, lineStr = arg[:i], arg[i+1:]
+	}
+	fileLine, err := strconv.Atoi(lineStr)
+	if err != nil {
+		r.printf("invalid location %q", arg)
+		return 0, false
+	}
+	line := r.fileReader.Line(path, fileLine)
+	if line == 0 {
+		r.printf("no line %d in %s", fileLine, path)
+		return 0, false
+	}
+	return line, true
+}
+
+func (r *REPL) setBreakpoint(s *interp.DebugState, arg string) {
+	line, ok := r.parseLocation(s, arg)
+	if !ok {
+		return
+	}
+	r.debugger.SetBreakpoint(line)
+	r.printf("breakpoint set at %s", r.posString(line))
+}
+
+func (r *REPL) clearBreakpoint(s *interp.DebugState, arg string) {
+	line, ok := r.parseLocation(s, arg)
+	if !ok {
+		return
+	}
+	if !r.debugger.ClearBreakpoint(line) {
+		r.printf("no breakpoint at %s", r.posString(line))
+		return
+	}
+	r.printf("breakpoint cleared at %s", r.posString(line))
+}
+
+func (r *REPL) showBreakpoints() {
+	lines := r.debugger.Breakpoints()
+	if len(lines) == 0 {
+		r.printf("no breakpoints")
+		return
+	}
+	for _, line := range lines {
+		r.printf("%s  %s", r.posString(line), strings.TrimSpace(r.sourceLine(line)))
+	}
+}
+
+// Print a variable, array, array element (name[key]), or field ($n).
+func (r *REPL) print(s *interp.DebugState, arg string) {
+	switch {
+	case arg == "":
+		r.printf("usage: print name | name[key] | $n")
+	case arg[0] == '$':
+		index, err := strconv.Atoi(arg[1:])
+		if err != nil || index < 0 {
+			r.printf("invalid field %q", arg)
+			return
+		}
+		r.printf("%s = %s", arg, formatValue(s.Field(index)))
+	case strings.HasSuffix(arg, "]") && strings.Contains(arg, "["):
+		i := strings.IndexByte(arg, '[')
+		name, key := arg[:i], unquote(arg[i+1:len(arg)-1])
+		array := s.Array(name)
+		if array == nil {
+			r.printf("no array %q", name)
+			return
+		}
+		v, ok := array[key]
+		if !ok {
+			r.printf("%s[%s] not in array", name, formatValue(key))
+			return
+		}
+		r.printf("%s[%s] = %s", name, formatValue(key), formatValue(v))
+	default:
+		r.printVar(s, arg)
+	}
+}
+
+func (r *REPL) printVar(s *interp.DebugState, name string) {
+	if v, ok := s.Var(name); ok {
+		r.printf("%s = %s", name, formatValue(v))
+		return
+	}
+	array := s.Array(name)
+	if array == nil {
+		r.printf("no variable %q", name)
+		return
+	}
+	keys := make([]string, 0, len(array))
+	for k := range array {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	r.printf("%s = array (%d elements)", name, len(keys))
+	for _, k := range keys {
+		r.printf("  %s[%s] = %s", name, formatValue(k), formatValue(array[k]))
+	}
+}
+
+// Unquote a string key like "foo", or return it as is if it's not quoted.
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s
+}
+
+// Format a value from interp.DebugState for display: numbers as AWK would
+// print them, strings quoted, and subarrays with their number of elements.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e16 {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return strconv.FormatFloat(v, 'g', 6, 64)
+	case string:
+		return strconv.Quote(v)
+	case map[string]interface{}:
+		return fmt.Sprintf("array (%d elements)", len(v))
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,122 @@
+package debugger_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nuvolaris/goawk/internal/debugger"
+	"github.com/nuvolaris/goawk/internal/parseutil"
+	"github.com/nuvolaris/goawk/interp"
+	"github.com/nuvolaris/goawk/parser"
+)
+
+func TestREPL(t *testing.T) {
+	fileReader := &parseutil.FileReader{}
+	err := fileReader.AddFile("main.awk", strings.NewReader(`BEGIN {
+	x = 1
+	a["k"] = "v"
+	print f(x)
+	x = 3
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fileReader.AddFile("lib.awk", strings.NewReader(`function f(n) {
+	return n + 1
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := parser.ParseProgram(fileReader.Source(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commands := `
+b lib.awk:2
+b 9
+info break
+clear 3
+c
+p n
+bt
+l
+finish
+p x
+p a
+p a["k"]
+p a["x"]
+p $0
+bogus
+c
+`[1:]
+	var out bytes.Buffer
+	repl := debugger.New(strings.NewReader(commands), &out, fileReader)
+	var output bytes.Buffer
+	_, err = interp.ExecProgram(prog, &interp.Config{
+		Output:   &output,
+		Debugger: repl.Debugger(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "2\n" {
+		t.Fatalf("expected output %q, got %q", "2\n", output.String())
+	}
+
+	expected := `
+BEGIN at main.awk:2:
+    2      x = 1
+(debug) breakpoint set at lib.awk:2
+(debug) no line 9 in main.awk
+(debug) lib.awk:2  return n + 1
+(debug) no breakpoint at main.awk:3
+(debug) function f at lib.awk:2:
+    2      return n + 1
+(debug) n = 1
+(debug) #0  function f at lib.awk:2
+#1  BEGIN at main.awk:4
+(debug)     1  function f(n) {
+>   2      return n + 1
+    3  }
+(debug) BEGIN at main.awk:5:
+    5      x = 3
+(debug) x = 1
+(debug) a = array (1 elements)
+  a["k"] = "v"
+(debug) a["k"] = "v"
+(debug) a["x"] not in array
+(debug) $0 = ""
+(debug) unknown command "bogus" (type "help" for help)
+(debug) `[1:]
+	if out.String() != expected {
+		t.Fatalf("expected output:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestREPLQuit(t *testing.T) {
+	fileReader := &parseutil.FileReader{}
+	err := fileReader.AddFile("<cmdline>", strings.NewReader(`BEGIN { print "a"; print "b" }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := parser.ParseProgram(fileReader.Source(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out, output bytes.Buffer
+	repl := debugger.New(strings.NewReader("n\nq\n"), &out, fileReader)
+	_, err = interp.ExecProgram(prog, &interp.Config{
+		Output:   &output,
+		Debugger: repl.Debugger(),
+	})
+	if err != interp.ErrDebugQuit {
+		t.Fatalf("expected ErrDebugQuit, got %v", err)
+	}
+	if output.String() != "a\n" {
+		t.Fatalf("expected output %q, got %q", "a\n", output.String())
+	}
+}
@@ -49,6 +49,20 @@ func (fr *FileReader) FileLine(line int) (path string, fileLine int) {
 	return "", 0
 }
 
+// Line resolves a local line number in the source file identified by path
+// to the overall line number in the concatenated source code (the inverse of
+// FileLine). It returns 0 if there's no such file or line.
+func (fr *FileReader) Line(path string, fileLine int) int {
+	startLine := 1
+	for _, f := range fr.files {
+		if f.path == path && fileLine >= 1 && fileLine <= f.lines {
+			return startLine + fileLine - 1
+		}
+		startLine += f.lines
+	}
+	return 0
+}
+
 // Source returns the concatenated source code from all files added.
 func (fr *FileReader) Source() []byte {
 	return fr.source.Bytes()
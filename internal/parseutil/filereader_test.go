@@ -126,6 +126,14 @@ print x
 				t.Errorf("expected fileLine: %v, got: %v", tst.fileLine, fileLine)
 			}
 
+			// test reverse mapping
+			if tst.path != "" {
+				line := fr.Line(tst.path, tst.fileLine)
+				if line != tst.line {
+					t.Errorf("expected line: %v, got: %v", tst.line, line)
+				}
+			}
+
 			// test result source
 			source := string(fr.Source())
 			for _, file := range tst.files {
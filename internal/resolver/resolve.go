@@ -22,9 +22,12 @@ type resolver struct {
 	arrayRefs []arrayRef                     // all array references
 
 	// Function tracking
-	functions   map[string]int // map of function name to index
-	userCalls   []userCall     // record calls so we can resolve them later
-	nativeFuncs map[string]interface{}
+	functions    map[string]int  // map of function name to index
+	awkFuncs     map[string]bool // names of all AWK-defined functions
+	userCalls    []userCall      // record calls so we can resolve them later
+	isArrayCalls []isArrayCall   // isarray(name) calls, resolved once types are known
+	nativeFuncs  map[string]interface{}
+	arrayResults map[*ast.UserCallExpr]bool // native calls whose array result is assigned
 
 	// Configuration and debugging
 	debugTypes  bool      // show variable types for debugging
@@ -48,9 +51,12 @@ func Resolve(prog *ast.Program, config *Config) *ast.ResolvedProgram {
 
 	resolvedProg := &ast.ResolvedProgram{Program: *prog}
 
+	for _, f := range prog.Functions {
+		r.awkFuncs[f.Name] = true
+	}
 	ast.Walk(r, prog)
 
-	r.resolveUserCalls(prog)
+	resolvedProg.NativeFuncs = r.resolveUserCalls(prog)
 	r.resolveVars(resolvedProg)
 
 	return resolvedProg
@@ -83,17 +89,56 @@ func (r *resolver) Visit(node ast.Node) ast.Visitor {
 
 	case *ast.ArrayExpr:
 		r.recordArrayRef(n)
+		for _, index := range n.Subscripts {
+			ast.WalkExprList(r, index)
+		}
+
+	case *ast.CallExpr:
+		if n.Func != F_ISARRAY {
+			return r
+		}
+		// The argument to isarray() may be a scalar or an array, so don't
+		// determine its type from this reference
+		arg := n.Args[0]
+		ast.Walk(r, arg)
+		if _, ok := arg.(*ast.VarExpr); ok {
+			r.processUserCallArg("", arg, 0)
+			r.isArrayCalls = append(r.isArrayCalls, isArrayCall{n, r.funcName})
+		}
 
 	case *ast.UserCallExpr:
 		name := n.Name
 		if r.locals[name] {
 			panic(ast.PosErrorf(n.Pos, "can't call local variable %q as function", name))
 		}
+		nativeType := r.nativeFuncType(name)
 		for i, arg := range n.Args {
+			if nativeType != nil && isArrayParam(nativeType, i) {
+				n.Args[i] = arrayArg(n, arg)
+				ast.Walk(r, n.Args[i])
+				continue
+			}
 			ast.Walk(r, arg)
 			r.processUserCallArg(name, arg, i)
 		}
 		r.userCalls = append(r.userCalls, userCall{n, n.Pos, r.funcName})
+
+	case *ast.AssignExpr:
+		// The result of a native function that returns a map can be
+		// assigned to an array, or to an element (making it a subarray)
+		if call, ok := n.Right.(*ast.UserCallExpr); ok {
+			typ := r.nativeFuncType(call.Name)
+			if typ != nil && typ.NumOut() > 0 && isArrayType(typ.Out(0)) {
+				switch left := n.Left.(type) {
+				case *ast.VarExpr:
+					n.Left = ast.ArrayRef(left.Name, left.Pos)
+					r.arrayResults[call] = true
+				case *ast.IndexExpr:
+					r.arrayResults[call] = true
+				}
+			}
+		}
+		return r
 	default:
 		return r
 	}
@@ -169,10 +214,12 @@ func newResolver(config *Config) *resolver {
 	r.varTypes = make(map[string]map[string]typeInfo)
 	r.varTypes[""] = make(map[string]typeInfo) // globals
 	r.functions = make(map[string]int)
+	r.awkFuncs = make(map[string]bool)
+	r.arrayResults = make(map[*ast.UserCallExpr]bool)
 	initialPos := Position{1, 1}
-	r.recordArrayRef(ast.ArrayRef("ARGV", initialPos))    // interpreter relies on ARGV being present
-	r.recordArrayRef(ast.ArrayRef("ENVIRON", initialPos)) // and other built-in arrays
-	r.recordArrayRef(ast.ArrayRef("FIELDS", initialPos))
+	for _, name := range ast.SpecialArrays {
+		r.recordArrayRef(ast.ArrayRef(name, initialPos))
+	}
 	return r
 }
 
@@ -183,10 +230,16 @@ type userCall struct {
 	inFunc string
 }
 
+type isArrayCall struct {
+	call   *ast.CallExpr
+	inFunc string
+}
+
 // After parsing, resolve all user calls to their indexes. Also
 // ensures functions called have actually been defined, and that
-// they're not being called with too many arguments.
-func (r *resolver) resolveUserCalls(prog *ast.Program) {
+// they're not being called with too many arguments. Returns the
+// native functions called, by index.
+func (r *resolver) resolveUserCalls(prog *ast.Program) []ast.NativeFunc {
 	// Number the native funcs (order by name to get consistent order)
 	nativeNames := make([]string, 0, len(r.nativeFuncs))
 	for name := range r.nativeFuncs {
@@ -197,6 +250,7 @@ func (r *resolver) resolveUserCalls(prog *ast.Program) {
 	for i, name := range nativeNames {
 		nativeIndexes[name] = i
 	}
+	var nativeFuncs []ast.NativeFunc
 
 	for _, c := range r.userCalls {
 		// AWK-defined functions take precedence over native Go funcs
@@ -207,11 +261,18 @@ func (r *resolver) resolveUserCalls(prog *ast.Program) {
 				panic(ast.PosErrorf(c.pos, "undefined function %q", c.call.Name))
 			}
 			typ := reflect.TypeOf(f)
-			if !typ.IsVariadic() && len(c.call.Args) > typ.NumIn() {
+			if !typ.IsVariadic() && len(c.call.Args) > typ.NumIn()-contextParams(typ) {
 				panic(ast.PosErrorf(c.pos, "%q called with more arguments than declared", c.call.Name))
 			}
+			if typ.NumOut() > 0 && isArrayType(typ.Out(0)) && !r.arrayResults[c.call] {
+				panic(ast.PosErrorf(c.pos, "can't use array returned by %q as scalar", c.call.Name))
+			}
 			c.call.Native = true
 			c.call.Index = nativeIndexes[c.call.Name]
+			if len(nativeFuncs) == 0 {
+				nativeFuncs = make([]ast.NativeFunc, len(nativeNames))
+			}
+			nativeFuncs[c.call.Index] = nativeFuncInfo(c.call.Name, typ)
 			continue
 		}
 		function := prog.Functions[index]
@@ -220,6 +281,82 @@ func (r *resolver) resolveUserCalls(prog *ast.Program) {
 		}
 		c.call.Index = index
 	}
+	return nativeFuncs
+}
+
+// Return how the native function with the given name and type is typed.
+func nativeFuncInfo(name string, typ reflect.Type) ast.NativeFunc {
+	info := ast.NativeFunc{
+		Name:        name,
+		Arrays:      make([]bool, typ.NumIn()-contextParams(typ)),
+		Variadic:    typ.IsVariadic(),
+		ArrayResult: typ.NumOut() > 0 && isArrayType(typ.Out(0)),
+	}
+	for i := range info.Arrays {
+		info.Arrays[i] = isArrayParam(typ, i)
+	}
+	return info
+}
+
+// Return the Go type of the native function with the given name, or nil if
+// there's no such function (or an AWK function takes precedence).
+func (r *resolver) nativeFuncType(name string) reflect.Type {
+	f, ok := r.nativeFuncs[name]
+	if !ok || r.awkFuncs[name] {
+		return nil
+	}
+	typ := reflect.TypeOf(f)
+	if typ.Kind() != reflect.Func {
+		return nil // checked by the interpreter
+	}
+	return typ
+}
+
+// Return true if typ is one of the Go map types used for AWK arrays in
+// native functions.
+func isArrayType(typ reflect.Type) bool {
+	return typ == reflect.TypeOf(map[string]string(nil)) ||
+		typ == reflect.TypeOf(map[string]interface{}(nil))
+}
+
+// Return the number of leading parameters of native function type typ that
+// aren't passed from AWK: 1 if the first is an *interp.CallContext, else 0.
+// The type is checked by name as the interp package imports this one.
+func contextParams(typ reflect.Type) int {
+	if typ.NumIn() == 0 {
+		return 0
+	}
+	param := typ.In(0)
+	if param.Kind() == reflect.Ptr && param.Elem().Name() == "CallContext" &&
+		param.Elem().PkgPath() == "github.com/nuvolaris/goawk/interp" {
+		return 1
+	}
+	return 0
+}
+
+// Return true if the parameter of native function type typ that receives
+// the i'th AWK argument is an array.
+func isArrayParam(typ reflect.Type, i int) bool {
+	i += contextParams(typ)
+	if i >= typ.NumIn() || typ.IsVariadic() && i >= typ.NumIn()-1 {
+		return false
+	}
+	return isArrayType(typ.In(i))
+}
+
+// Convert an argument to a native function's array parameter to an array
+// reference: either an array name or a subarray like a[k].
+func arrayArg(call *ast.UserCallExpr, arg ast.Expr) *ast.ArrayExpr {
+	switch arg := arg.(type) {
+	case *ast.VarExpr:
+		return ast.ArrayRef(arg.Name, arg.Pos)
+	case *ast.IndexExpr:
+		ref := arg.Array
+		ref.Subscripts = append(ref.Subscripts, arg.Index)
+		return ref
+	default:
+		panic(ast.PosErrorf(call.Pos, "can't pass scalar %s as array param", arg))
+	}
 }
 
 // For arguments that are variable references, we don't know the
@@ -454,6 +591,13 @@ func (r *resolver) resolveVars(prog *ast.ResolvedProgram) {
 		// Check AWK function calls
 		function := prog.Functions[c.call.Index]
 		for i, arg := range c.call.Args {
+			if indexExpr, ok := arg.(*ast.IndexExpr); ok && function.Arrays[i] {
+				// Pass subarray a[x] as an array rather than its value
+				ref := indexExpr.Array
+				ref.Subscripts = append(ref.Subscripts, indexExpr.Index)
+				c.call.Args[i] = ref
+				continue
+			}
 			varExpr, ok := arg.(*ast.VarExpr)
 			if !ok {
 				if function.Arrays[i] {
@@ -472,6 +616,20 @@ func (r *resolver) resolveVars(prog *ast.ResolvedProgram) {
 		}
 	}
 
+	// Now that types are known, change the argument of each isarray(name)
+	// call to an array reference if name is an array.
+	for _, c := range r.isArrayCalls {
+		varExpr := c.call.Args[0].(*ast.VarExpr)
+		funcName := r.getVarFuncName(prog, varExpr.Name, c.inFunc)
+		info := r.varTypes[funcName][varExpr.Name]
+		if info.typ == typeArray {
+			ref := ast.ArrayRef(varExpr.Name, varExpr.Pos)
+			ref.Scope = varExpr.Scope
+			r.arrayRefs = append(r.arrayRefs, arrayRef{funcName, ref})
+			c.call.Args[0] = ref
+		}
+	}
+
 	if r.debugTypes {
 		r.printVarTypes(prog)
 	}
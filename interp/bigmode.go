@@ -0,0 +1,338 @@
+// Arithmetic for arbitrary-precision mode (Config.Precision or -M).
+
+package interp
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// In arbitrary-precision mode, numbers are backed by big.Float values.
+// Integer results of addition, subtraction, multiplication, exact division,
+// modulo, and exponentiation with a non-negative integer exponent are exact
+// no matter how large they get, like gawk's -M mode. Other results are
+// rounded to PREC bits of precision using ROUNDMODE.
+//
+// NaN and infinity can't be represented by a big.Float, so if either
+// operand is one of those, these fall back to float64 arithmetic. Other
+// builtin functions like sin() and log() also use float64.
+
+const (
+	defaultPrecision = 53      // same as float64
+	maxExactExponent = 1 << 16 // larger integer powers are rounded to PREC
+	maxScaledBits    = 1 << 20 // limit on size of exact intermediate results
+)
+
+// Return a new big.Float with the current PREC and ROUNDMODE.
+func (p *interp) newBig() *big.Float {
+	return new(big.Float).SetPrec(p.precision).SetMode(p.roundMode)
+}
+
+// Convert v to a big.Float, returning false if it's NaN or infinity.
+func (p *interp) toBig(v value) (*big.Float, bool) {
+	switch v.typ {
+	case typeBig:
+		return v.bigVal(), true
+	case typeInt:
+		return new(big.Float).SetInt64(v.intVal()), true
+	case typeStr, typeNumStr:
+		return p.parseBigPrefix(v.s)
+	default: // typeNum, typeNull
+		return p.floatToBig(v.n)
+	}
+}
+
+// Convert float64 to a big.Float. Numbers that aren't integers are
+// converted via their shortest decimal representation, so that a
+// number like 0.1 in the source code is 0.1 to PREC bits of precision.
+func (p *interp) floatToBig(f float64) (*big.Float, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, false
+	}
+	if f == math.Trunc(f) {
+		return new(big.Float).SetFloat64(f), true
+	}
+	x, _, err := p.newBig().Parse(strconv.FormatFloat(f, 'g', -1, 64), 10)
+	return x, err == nil
+}
+
+// Like parseFloatPrefix, but parse the number at the start of s as a
+// big.Float. Integers are parsed exactly; decimals are rounded to PREC
+// bits. Hexadecimal numbers, NaN, and infinity are parsed as float64.
+func (p *interp) parseBigPrefix(s string) (*big.Float, bool) {
+	i := 0
+	for i < len(s) && asciiSpace[s[i]] != 0 {
+		i++
+	}
+	start := i
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	if i+3 <= len(s) && (hasNaNPrefix(s[i:]) || hasInfPrefix(s[i:])) || i+2 < len(s) && hasHexPrefix(s[i:]) {
+		return p.floatToBig(parseFloatPrefix(s))
+	}
+	end := scanDecimal(s, i)
+	if end < 0 {
+		return new(big.Float), true
+	}
+	text := s[start:end]
+	if strings.IndexAny(text, ".eE") < 0 {
+		n, ok := new(big.Int).SetString(text, 10)
+		if ok {
+			return new(big.Float).SetInt(n), true
+		}
+	}
+	x, _, err := p.newBig().Parse(text, 10)
+	return x, err == nil
+}
+
+// Return the exact integer value of x as a big.Int, or nil if x isn't an
+// integer.
+func bigInteger(x *big.Float) *big.Int {
+	if !x.IsInt() {
+		return nil
+	}
+	n, _ := x.Int(nil)
+	return n
+}
+
+// Create a new value from the big.Int n.
+func bigIntNum(n *big.Int) value {
+	return bigNum(new(big.Float).SetInt(n))
+}
+
+func (p *interp) bigAdd(l, r value) value {
+	x, xOk := p.toBig(l)
+	y, yOk := p.toBig(r)
+	if !xOk || !yOk {
+		return num(l.num() + r.num())
+	}
+	if a, b := bigInteger(x), bigInteger(y); a != nil && b != nil {
+		return bigIntNum(a.Add(a, b))
+	}
+	return bigNum(p.newBig().Add(x, y))
+}
+
+func (p *interp) bigSub(l, r value) value {
+	x, xOk := p.toBig(l)
+	y, yOk := p.toBig(r)
+	if !xOk || !yOk {
+		return num(l.num() - r.num())
+	}
+	if a, b := bigInteger(x), bigInteger(y); a != nil && b != nil {
+		return bigIntNum(a.Sub(a, b))
+	}
+	return bigNum(p.newBig().Sub(x, y))
+}
+
+func (p *interp) bigMul(l, r value) value {
+	x, xOk := p.toBig(l)
+	y, yOk := p.toBig(r)
+	if !xOk || !yOk {
+		return num(l.num() * r.num())
+	}
+	if a, b := bigInteger(x), bigInteger(y); a != nil && b != nil {
+		return bigIntNum(a.Mul(a, b))
+	}
+	return bigNum(p.newBig().Mul(x, y))
+}
+
+// Division stays exact only if both operands are integers and r divides l
+// evenly. The caller must check for division by zero.
+func (p *interp) bigDiv(l, r value) value {
+	x, xOk := p.toBig(l)
+	y, yOk := p.toBig(r)
+	if !xOk || !yOk || y.Sign() == 0 {
+		return num(l.num() / r.num())
+	}
+	if a, b := bigInteger(x), bigInteger(y); a != nil && b != nil {
+		q, m := new(big.Int).QuoRem(a, b, new(big.Int))
+		if m.Sign() == 0 {
+			return bigIntNum(q)
+		}
+	}
+	return bigNum(p.newBig().Quo(x, y))
+}
+
+// Like math.Mod, the result has the sign of l. The caller must check for
+// division by zero.
+func (p *interp) bigMod(l, r value) value {
+	x, xOk := p.toBig(l)
+	y, yOk := p.toBig(r)
+	if !xOk || !yOk || y.Sign() == 0 {
+		return num(math.Mod(l.num(), r.num()))
+	}
+	if a, b := bigInteger(x), bigInteger(y); a != nil && b != nil {
+		return bigIntNum(a.Rem(a, b))
+	}
+	// Scale both to integers by the same power of two, so that the
+	// remainder can be computed exactly.
+	e := x.MantExp(nil) - int(x.MinPrec())
+	if ey := y.MantExp(nil) - int(y.MinPrec()); ey < e {
+		e = ey
+	}
+	if x.MantExp(nil)-e > maxScaledBits || y.MantExp(nil)-e > maxScaledBits {
+		return num(math.Mod(l.num(), r.num()))
+	}
+	a, _ := new(big.Float).SetMantExp(x, -e).Int(nil)
+	b, _ := new(big.Float).SetMantExp(y, -e).Int(nil)
+	rem := new(big.Float).SetInt(a.Rem(a, b))
+	return bigNum(p.newBig().SetMantExp(rem, e))
+}
+
+// Raise l to the power r. If r is a non-negative integer and l is an
+// integer, the result is exact (unless r is very large); other integer
+// powers are computed by repeated multiplication at PREC bits, and
+// non-integer powers use float64.
+func (p *interp) bigPow(l, r value) value {
+	x, xOk := p.toBig(l)
+	y, yOk := p.toBig(r)
+	if !xOk || !yOk {
+		return num(math.Pow(l.num(), r.num()))
+	}
+	n, acc := y.Int64()
+	if acc != big.Exact {
+		return num(math.Pow(l.num(), r.num()))
+	}
+	if a := bigInteger(x); a != nil && n >= 0 && n <= maxExactExponent {
+		return bigIntNum(a.Exp(a, big.NewInt(n), nil))
+	}
+	if x.Sign() == 0 && n < 0 {
+		return num(math.Pow(0, float64(n))) // infinity
+	}
+	neg := n < 0
+	if neg {
+		n = -n
+	}
+	result := p.newBig().SetInt64(1)
+	square := p.newBig().Set(x)
+	for n > 0 {
+		if n&1 != 0 {
+			result.Mul(result, square)
+		}
+		square.Mul(square, square)
+		n >>= 1
+	}
+	if neg {
+		result.Quo(p.newBig().SetInt64(1), result)
+	}
+	return bigNum(result)
+}
+
+func (p *interp) bigNeg(v value) value {
+	x, ok := p.toBig(v)
+	if !ok {
+		return num(-v.num())
+	}
+	return bigNum(new(big.Float).Neg(x))
+}
+
+// Convert v to a number (unary plus).
+func (p *interp) bigPlus(v value) value {
+	x, ok := p.toBig(v)
+	if !ok {
+		return num(v.num())
+	}
+	return bigNum(x)
+}
+
+// Truncate v toward zero, for the int() function.
+func (p *interp) bigInt(v value) value {
+	x, ok := p.toBig(v)
+	if !ok {
+		return num(float64(int(v.num())))
+	}
+	n, _ := x.Int(nil)
+	return bigIntNum(n)
+}
+
+func (p *interp) bigCompare(l, r value) int {
+	x, xOk := p.toBig(l)
+	y, yOk := p.toBig(r)
+	if !xOk || !yOk {
+		return 0
+	}
+	return x.Cmp(y)
+}
+
+// Return the value to pass to fmt.Sprintf for a printf argument of the
+// given type ('d', 'u', or 'f', as returned by parseFmtTypes), or false if
+// v should be converted as usual. Integers too large for an int are passed
+// as a *big.Int and other numbers as a *big.Float, which format themselves
+// with all their digits.
+func (p *interp) bigFormatArg(t byte, v value) (interface{}, bool) {
+	x, ok := p.toBig(v)
+	if !ok {
+		return nil, false
+	}
+	switch t {
+	case 'd', 'u':
+		n, _ := x.Int(nil)
+		if n.IsInt64() && (t == 'd' || n.Sign() >= 0) {
+			return int(n.Int64()), true
+		}
+		return n, true
+	case 'f':
+		return x, true
+	default:
+		return nil, false
+	}
+}
+
+// Parse a value for PREC, which is either a number of bits or one of the
+// IEEE 754 format names that gawk supports.
+func parsePrecision(s string) (uint, error) {
+	switch s {
+	case "half":
+		return 11, nil
+	case "single":
+		return 24, nil
+	case "double":
+		return 53, nil
+	case "quad":
+		return 113, nil
+	case "oct":
+		return 237, nil
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+	if err != nil || n < 1 || n > big.MaxPrec {
+		return 0, newError("invalid PREC %q: must be a number of bits or a format name like \"quad\"", s)
+	}
+	return uint(n), nil
+}
+
+// Parse a value for ROUNDMODE, using gawk's single-letter names.
+func parseRoundMode(s string) (big.RoundingMode, error) {
+	switch strings.ToUpper(s) {
+	case "N":
+		return big.ToNearestEven, nil
+	case "Z":
+		return big.ToZero, nil
+	case "U":
+		return big.ToPositiveInf, nil
+	case "D":
+		return big.ToNegativeInf, nil
+	case "A":
+		return big.AwayFromZero, nil
+	default:
+		return 0, newError("invalid ROUNDMODE %q: must be N, Z, U, D, or A", s)
+	}
+}
+
+// Return the ROUNDMODE name for mode.
+func roundModeString(mode big.RoundingMode) string {
+	switch mode {
+	case big.ToZero:
+		return "Z"
+	case big.ToPositiveInf:
+		return "U"
+	case big.ToNegativeInf:
+		return "D"
+	case big.AwayFromZero:
+		return "A"
+	default:
+		return "N"
+	}
+}
@@ -0,0 +1,75 @@
+// Interpreter access for native functions that take a *CallContext.
+
+package interp
+
+import (
+	"context"
+	"io"
+	"reflect"
+
+	"github.com/nuvolaris/goawk/internal/ast"
+)
+
+// CallContext gives a native Go function access to the interpreter that's
+// calling it. To receive one, a function in Config.Funcs declares
+// *CallContext as its first parameter, for example:
+//
+//	func(c *interp.CallContext, s string) string
+//
+// The first AWK argument is then passed to the second Go parameter, and so
+// on. A CallContext is only valid for the duration of the call.
+type CallContext struct {
+	p *interp
+}
+
+var callContextType = reflect.TypeOf((*CallContext)(nil))
+
+// Context returns the context passed to Interpreter.ExecuteContext, or
+// context.Background() if the program wasn't started with a context.
+// Long-running functions should stop and return an error when it's done.
+func (c *CallContext) Context() context.Context {
+	if c.p.ctx == nil {
+		return context.Background()
+	}
+	return c.p.ctx
+}
+
+// Var returns the value of the named global or special variable, such as
+// NR or FILENAME. Numbers are returned as type float64 (int64 in integer
+// mode, or *big.Float in -M mode), strings (including "numeric strings") as
+// type string. If there's no such variable, Var returns false.
+func (c *CallContext) Var(name string) (interface{}, bool) {
+	p := c.p
+	if index := ast.SpecialVarIndex(name); index > 0 {
+		return p.valueToInterface(p.getSpecial(index)), true
+	}
+	if index, ok := p.program.Scalars[name]; ok {
+		return p.valueToInterface(p.globals[index]), true
+	}
+	return nil, false
+}
+
+// SetVar sets the named global or special variable to value, which is
+// treated as a "numeric string", as with Config.Vars. Variables that aren't
+// used in the program are ignored. An error is returned if the value isn't
+// valid for a special variable, such as a negative NF.
+func (c *CallContext) SetVar(name, value string) error {
+	return c.p.setVarByName(name, value)
+}
+
+// Record returns the current input record, $0.
+func (c *CallContext) Record() string {
+	return c.p.line
+}
+
+// Field returns the value of the given field, equivalent to $index.
+func (c *CallContext) Field(index int) string {
+	return c.p.toString(c.p.getField(index))
+}
+
+// Output returns the writer used by print and printf without a redirect.
+// Writing to it (rather than directly to os.Stdout, for example) ensures
+// the function's output is ordered correctly with the program's output.
+func (c *CallContext) Output() io.Writer {
+	return c.p.output
+}
@@ -0,0 +1,321 @@
+// Source-level debugging support: breakpoints, stepping, and inspection of
+// program state while it's stopped.
+
+package interp
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/nuvolaris/goawk/internal/ast"
+	"github.com/nuvolaris/goawk/internal/compiler"
+	"github.com/nuvolaris/goawk/lexer"
+)
+
+// DebugCmd tells the interpreter how to continue after the debugger's Stop
+// function returns.
+type DebugCmd int
+
+const (
+	// DebugContinue runs until the next breakpoint is reached.
+	DebugContinue DebugCmd = iota
+
+	// DebugStep stops at the next statement, stepping into function calls.
+	DebugStep
+
+	// DebugNext stops at the next statement in the current function (or a
+	// caller), stepping over function calls.
+	DebugNext
+
+	// DebugFinish stops at the next statement after the current function
+	// returns.
+	DebugFinish
+
+	// DebugQuit stops executing the program; Execute returns ErrDebugQuit.
+	DebugQuit
+)
+
+// ErrDebugQuit is returned by Execute when the debugger's Stop function
+// returns DebugQuit.
+var ErrDebugQuit = errors.New("debugger quit")
+
+// Debugger controls execution of a program for source-level debugging. Set
+// Config.Debugger to enable it. Debugging slows down execution, so only
+// enable it when needed.
+type Debugger struct {
+	// Stop is called whenever execution stops before a statement, either
+	// because a breakpoint was reached or because of a DebugStep, DebugNext,
+	// or DebugFinish command. The DebugState can be used to inspect (but
+	// only during the call) the state of the program. Stop returns how to
+	// continue execution.
+	Stop func(s *DebugState) DebugCmd
+
+	breakpoints map[int]bool
+	cmd         DebugCmd
+	cmdDepth    int
+}
+
+// NewDebugger returns a new debugger which will call stop before the first
+// statement of the program is executed (and after that as per the DebugCmd
+// it returns).
+func NewDebugger(stop func(s *DebugState) DebugCmd) *Debugger {
+	return &Debugger{
+		Stop:        stop,
+		breakpoints: make(map[int]bool),
+		cmd:         DebugStep,
+	}
+}
+
+// SetBreakpoint sets a breakpoint on the given source line. Execution stops
+// whenever it reaches the first statement that starts on that line.
+func (d *Debugger) SetBreakpoint(line int) {
+	if d.breakpoints == nil {
+		d.breakpoints = make(map[int]bool)
+	}
+	d.breakpoints[line] = true
+}
+
+// ClearBreakpoint removes the breakpoint on the given source line, and
+// reports whether there was one.
+func (d *Debugger) ClearBreakpoint(line int) bool {
+	if !d.breakpoints[line] {
+		return false
+	}
+	delete(d.breakpoints, line)
+	return true
+}
+
+// Breakpoints returns the source lines that have breakpoints set, in order.
+func (d *Debugger) Breakpoints() []int {
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Called before each instruction when debugging. Decide whether we've
+// reached a statement where we should stop, and if so call Stop.
+func (d *Debugger) hook(p *interp, ip int) error {
+	if len(p.debugFrames) == 0 {
+		return nil // executing a pattern, not a statement
+	}
+	frame := &p.debugFrames[len(p.debugFrames)-1]
+	i := frame.lines.Index(frame.base + ip)
+	if i < 0 {
+		return nil
+	}
+	pos := frame.lines[i].Pos
+	frame.pos = pos
+
+	stop := false
+	switch d.cmd {
+	case DebugStep:
+		stop = true
+	case DebugNext:
+		stop = p.callDepth <= d.cmdDepth
+	case DebugFinish:
+		stop = p.callDepth < d.cmdDepth
+	}
+	if !stop && d.breakpoints[pos.Line] {
+		// Only stop at the first statement on a line, so that a line like
+		// "x = 1; y = 2" only stops once.
+		stop = i == 0 || frame.lines[i-1].Pos.Line != pos.Line
+	}
+	if !stop {
+		return nil
+	}
+
+	d.cmd = d.Stop(&DebugState{p: p})
+	d.cmdDepth = p.callDepth
+	if d.cmd == DebugQuit {
+		return ErrDebugQuit
+	}
+	return nil
+}
+
+// Stack frame information for the debugger.
+type debugFrame struct {
+	name     string
+	action   int                // action number (from 1) if in an action
+	function *compiler.Function // nil if not in a function
+	lines    compiler.Lines
+	base     int // address of code[0] in the block (nonzero in for-in loops)
+	pos      lexer.Position
+}
+
+func (f *debugFrame) block() string {
+	switch {
+	case f.function != nil:
+		return "function " + f.function.Name
+	case f.action > 0:
+		return f.name + " " + strconv.Itoa(f.action)
+	default:
+		return f.name
+	}
+}
+
+// Execute a block of code, pushing a debugger stack frame if debugging.
+func (p *interp) executeBlock(name string, action int, function *compiler.Function, lines compiler.Lines, code []compiler.Opcode) error {
+	if p.debugger == nil {
+		return p.execute(code)
+	}
+	p.debugFrames = append(p.debugFrames, debugFrame{
+		name:     name,
+		action:   action,
+		function: function,
+		lines:    lines,
+	})
+	err := p.execute(code)
+	p.debugFrames = p.debugFrames[:len(p.debugFrames)-1]
+	return err
+}
+
+// DebugState allows a debugger to inspect the program state when execution
+// is stopped. It's only valid during the call to Debugger.Stop.
+type DebugState struct {
+	p *interp
+}
+
+// Pos returns the source position of the statement about to be executed.
+func (s *DebugState) Pos() lexer.Position {
+	return s.frame().pos
+}
+
+// Block returns a description of the block currently executing, for example
+// "BEGIN", "action 2", or "function f".
+func (s *DebugState) Block() string {
+	return s.frame().block()
+}
+
+// Depth returns the function call depth (0 when not in a function).
+func (s *DebugState) Depth() int {
+	return s.p.callDepth
+}
+
+// DebugFrame is one frame of the call stack returned by DebugState.Stack.
+type DebugFrame struct {
+	Block string
+	Pos   lexer.Position
+}
+
+// Stack returns the call stack, innermost frame first.
+func (s *DebugState) Stack() []DebugFrame {
+	frames := make([]DebugFrame, len(s.p.debugFrames))
+	for i, f := range s.p.debugFrames {
+		frames[len(frames)-1-i] = DebugFrame{f.block(), f.pos}
+	}
+	return frames
+}
+
+func (s *DebugState) frame() *debugFrame {
+	return &s.p.debugFrames[len(s.p.debugFrames)-1]
+}
+
+// Var returns the value of the named scalar variable: a local if the
+// current function has a scalar parameter of that name, otherwise a global
+// or special variable. Numbers are returned as type float64, strings
+// (including "numeric strings") as type string. If there's no such variable,
+// Var returns false.
+func (s *DebugState) Var(name string) (interface{}, bool) {
+	p := s.p
+	if f := s.frame().function; f != nil {
+		scalarIndex := 0
+		for i, param := range f.Params {
+			if f.Arrays[i] {
+				continue
+			}
+			if param == name {
+				return p.valueToInterface(p.frame[scalarIndex]), true
+			}
+			scalarIndex++
+		}
+	}
+	if index := ast.SpecialVarIndex(name); index > 0 {
+		return p.valueToInterface(p.getSpecial(index)), true
+	}
+	if index, ok := p.program.Scalars[name]; ok {
+		return p.valueToInterface(p.globals[index]), true
+	}
+	return nil, false
+}
+
+// Array returns the items in the named array (local array parameter or
+// global array) in the same form as Interpreter.Array. If there's no such
+// array, return nil.
+func (s *DebugState) Array(name string) map[string]interface{} {
+	p := s.p
+	if f := s.frame().function; f != nil {
+		arrayIndex := 0
+		for i, param := range f.Params {
+			if !f.Arrays[i] {
+				continue
+			}
+			if param == name {
+				return p.arrayToMap(p.localArray(arrayIndex))
+			}
+			arrayIndex++
+		}
+	}
+	if index, ok := p.program.Arrays[name]; ok {
+		return p.arrayToMap(p.arrays[index])
+	}
+	return nil
+}
+
+// Field returns the value of the given field, equivalent to $index.
+func (s *DebugState) Field(index int) string {
+	return s.p.toString(s.p.getField(index))
+}
+
+// Locals returns the names of the current function's parameters (including
+// array parameters), or nil if not in a function.
+func (s *DebugState) Locals() []string {
+	f := s.frame().function
+	if f == nil {
+		return nil
+	}
+	return f.Params
+}
+
+// Globals returns the sorted names of the program's global scalars and
+// arrays (excluding special variables).
+func (s *DebugState) Globals() []string {
+	var names []string
+	for name := range s.p.program.Scalars {
+		names = append(names, name)
+	}
+	for name := range s.p.program.Arrays {
+		if isSpecialArray(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Execute the body of a for-in loop, which starts at address offset in the
+// current block.
+func (p *interp) executeLoop(code []compiler.Opcode, offset int) error {
+	if p.debugger == nil {
+		return p.execute(code)
+	}
+	frame := &p.debugFrames[len(p.debugFrames)-1]
+	frame.base += offset
+	err := p.execute(code)
+	frame = &p.debugFrames[len(p.debugFrames)-1]
+	frame.base -= offset
+	return err
+}
+
+func isSpecialArray(name string) bool {
+	for _, special := range ast.SpecialArrays {
+		if name == special {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+// Tests for the source-level debugger API.
+
+package interp_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nuvolaris/goawk/interp"
+	"github.com/nuvolaris/goawk/parser"
+)
+
+const debugSource = `function f(a, k,   i) {
+	i = a[k] * 2
+	return i
+}
+BEGIN {
+	x = 1; y = 2
+	arr["one"] = 1; arr["two"] = 2
+	for (k in arr) {
+		z += f(arr, k)
+	}
+}
+{
+	n++
+}
+END {
+	print z, n
+}
+`
+
+// Run debugSource with given breakpoints, returning "block:line" for each
+// stop. The commands are returned from Stop in order (then DebugContinue).
+func runDebugger(t *testing.T, breakpoints []int, cmds []interp.DebugCmd, inspect func(s *interp.DebugState)) ([]string, error) {
+	prog, err := parser.ParseProgram([]byte(debugSource), nil)
+	if err != nil {
+		t.Fatalf("error parsing: %v", err)
+	}
+	var stops []string
+	d := interp.NewDebugger(func(s *interp.DebugState) interp.DebugCmd {
+		stops = append(stops, fmt.Sprintf("%s:%d", s.Block(), s.Pos().Line))
+		if inspect != nil {
+			inspect(s)
+		}
+		if len(cmds) == 0 {
+			return interp.DebugContinue
+		}
+		cmd := cmds[0]
+		cmds = cmds[1:]
+		return cmd
+	})
+	for _, line := range breakpoints {
+		d.SetBreakpoint(line)
+	}
+	var output bytes.Buffer
+	_, err = interp.ExecProgram(prog, &interp.Config{
+		Stdin:    strings.NewReader("a\nb\n"),
+		Output:   &output,
+		Debugger: d,
+	})
+	if err == nil && output.String() != "6 2\n" {
+		t.Fatalf("expected output %q, got %q", "6 2\n", output.String())
+	}
+	return stops, err
+}
+
+func TestDebuggerStepping(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakpoints []int
+		cmds        []interp.DebugCmd
+		stops       string
+	}{
+		{"continue", nil, nil, "BEGIN:6"},
+		{"step", nil, []interp.DebugCmd{interp.DebugStep, interp.DebugStep, interp.DebugStep, interp.DebugStep, interp.DebugStep, interp.DebugStep},
+			"BEGIN:6 BEGIN:6 BEGIN:7 BEGIN:7 BEGIN:8 BEGIN:9 function f:2"},
+		{"next", nil, []interp.DebugCmd{interp.DebugNext, interp.DebugNext, interp.DebugNext, interp.DebugNext, interp.DebugNext, interp.DebugNext},
+			"BEGIN:6 BEGIN:6 BEGIN:7 BEGIN:7 BEGIN:8 BEGIN:9 BEGIN:9"},
+		{"finish", []int{2}, []interp.DebugCmd{interp.DebugContinue, interp.DebugFinish},
+			"BEGIN:6 function f:2 BEGIN:9 function f:2"},
+		{"breakpoints", []int{9, 13, 16}, nil,
+			"BEGIN:6 BEGIN:9 BEGIN:9 action 1:13 action 1:13 END:16"},
+		{"breakpoint once per line", []int{6}, []interp.DebugCmd{interp.DebugContinue}, "BEGIN:6"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stops, err := runDebugger(t, test.breakpoints, test.cmds, nil)
+			if err != nil {
+				t.Fatalf("error executing: %v", err)
+			}
+			if strings.Join(stops, " ") != test.stops {
+				t.Fatalf("expected stops %q, got %q", test.stops, strings.Join(stops, " "))
+			}
+		})
+	}
+}
+
+func TestDebuggerInspect(t *testing.T) {
+	var results []string
+	inspect := func(s *interp.DebugState) {
+		if s.Block() != "function f" {
+			return
+		}
+		k, _ := s.Var("k")
+		i, _ := s.Var("i")
+		x, _ := s.Var("x")
+		nr, _ := s.Var("NR")
+		_, ok := s.Var("nosuch")
+		results = append(results, fmt.Sprintf("k=%v i=%v x=%v NR=%v nosuch=%v a=%v arr=%v locals=%v globals=%v depth=%d",
+			k, i, x, nr, ok, len(s.Array("a")), len(s.Array("arr")), s.Locals(), s.Globals(), s.Depth()))
+		var frames []string
+		for _, frame := range s.Stack() {
+			frames = append(frames, fmt.Sprintf("%s:%d", frame.Block, frame.Pos.Line))
+		}
+		results = append(results, strings.Join(frames, " "))
+	}
+	_, err := runDebugger(t, []int{2}, []interp.DebugCmd{interp.DebugContinue}, inspect)
+	if err != nil {
+		t.Fatalf("error executing: %v", err)
+	}
+	expected := []string{
+		"k=one i= x=1 NR=0 nosuch=false a=2 arr=2 locals=[a k i] globals=[arr k n x y z] depth=1",
+		"function f:2 BEGIN:9",
+	}
+	if len(results) < 2 || !strings.HasPrefix(results[0], "k=") {
+		t.Fatalf("expected inspection results, got %q", results)
+	}
+	// Iteration order of "for (k in arr)" is undefined, so k may be "two".
+	results[0] = strings.Replace(results[0], "k=two", "k=one", 1)
+	for i, exp := range expected {
+		if results[i] != exp {
+			t.Fatalf("expected %q, got %q", exp, results[i])
+		}
+	}
+
+	var fields []string
+	inspectFields := func(s *interp.DebugState) {
+		fields = append(fields, s.Field(0)+"/"+s.Field(1))
+	}
+	_, err = runDebugger(t, []int{13}, []interp.DebugCmd{interp.DebugContinue}, inspectFields)
+	if err != nil {
+		t.Fatalf("error executing: %v", err)
+	}
+	if strings.Join(fields, " ") != "/ a/a b/b" {
+		t.Fatalf("expected fields %q, got %q", "/ a/a b/b", strings.Join(fields, " "))
+	}
+}
+
+func TestDebuggerQuit(t *testing.T) {
+	stops, err := runDebugger(t, nil, []interp.DebugCmd{interp.DebugStep, interp.DebugQuit}, nil)
+	if err != interp.ErrDebugQuit {
+		t.Fatalf("expected ErrDebugQuit, got %v", err)
+	}
+	if strings.Join(stops, " ") != "BEGIN:6 BEGIN:6" {
+		t.Fatalf("expected stops %q, got %q", "BEGIN:6 BEGIN:6", strings.Join(stops, " "))
+	}
+}
+
+func TestDebuggerBreakpoints(t *testing.T) {
+	d := interp.NewDebugger(nil)
+	d.SetBreakpoint(10)
+	d.SetBreakpoint(3)
+	if fmt.Sprint(d.Breakpoints()) != "[3 10]" {
+		t.Fatalf("expected [3 10], got %v", d.Breakpoints())
+	}
+	if !d.ClearBreakpoint(10) {
+		t.Fatalf("expected ClearBreakpoint(10) to return true")
+	}
+	if d.ClearBreakpoint(10) {
+		t.Fatalf("expected second ClearBreakpoint(10) to return false")
+	}
+	if fmt.Sprint(d.Breakpoints()) != "[3]" {
+		t.Fatalf("expected [3], got %v", d.Breakpoints())
+	}
+}
@@ -0,0 +1,170 @@
+// Arithmetic for the exact number modes: integer mode (Config.IntegerMode)
+// and arbitrary-precision mode (Config.Precision).
+
+package interp
+
+import (
+	"strconv"
+
+	"github.com/nuvolaris/goawk/internal/compiler"
+)
+
+// How numbers are represented and operated on.
+type numberMode uint8
+
+const (
+	floatMode numberMode = iota // normal float64 numbers
+	intMode                     // exact int64 integers, see intmode.go
+	bigMode                     // arbitrary precision, see bigmode.go
+)
+
+// Set up the number constants for the number mode (and PREC and ROUNDMODE
+// in arbitrary-precision mode). In the exact modes, constants that have
+// source text are converted from it, so that a literal like
+// 9007199254740993 isn't rounded to a float64 first.
+func (p *interp) setNums() {
+	compiled := p.program.Compiled
+	nums := make([]value, len(compiled.Nums))
+	for i, n := range compiled.Nums {
+		nums[i] = num(n)
+		text := compiled.NumTexts[i]
+		if text == "" {
+			continue
+		}
+		switch p.numMode {
+		case intMode:
+			if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+				nums[i] = integer(n)
+			}
+		case bigMode:
+			if x, ok := p.parseBigPrefix(text); ok {
+				nums[i] = bigNum(x)
+			}
+		}
+	}
+	p.nums = nums
+}
+
+// The VM calls these instead of doing float64 arithmetic when p.numMode
+// isn't floatMode.
+
+func (p *interp) exactAdd(l, r value) value {
+	if p.numMode == bigMode {
+		return p.bigAdd(l, r)
+	}
+	return intAdd(l, r)
+}
+
+func (p *interp) exactSub(l, r value) value {
+	if p.numMode == bigMode {
+		return p.bigSub(l, r)
+	}
+	return intSub(l, r)
+}
+
+func (p *interp) exactMul(l, r value) value {
+	if p.numMode == bigMode {
+		return p.bigMul(l, r)
+	}
+	return intMul(l, r)
+}
+
+// The caller must check for division by zero.
+func (p *interp) exactDiv(l, r value) value {
+	if p.numMode == bigMode {
+		return p.bigDiv(l, r)
+	}
+	return intDiv(l, r)
+}
+
+// The caller must check for division by zero.
+func (p *interp) exactMod(l, r value) value {
+	if p.numMode == bigMode {
+		return p.bigMod(l, r)
+	}
+	return intMod(l, r)
+}
+
+func (p *interp) exactPow(l, r value) value {
+	if p.numMode == bigMode {
+		return p.bigPow(l, r)
+	}
+	return intPow(l, r)
+}
+
+func (p *interp) exactNeg(v value) value {
+	if p.numMode == bigMode {
+		return p.bigNeg(v)
+	}
+	return intNeg(v)
+}
+
+// Convert v to a number without losing precision.
+func (p *interp) exactNum(v value) value {
+	if p.numMode == bigMode {
+		return p.bigPlus(v)
+	}
+	return intNum(v)
+}
+
+// Guts of the int() function: truncate v toward zero.
+func (p *interp) exactInt(v value) value {
+	if p.numMode == bigMode {
+		return p.bigInt(v)
+	}
+	if i, ok := v.int(); ok {
+		return integer(i)
+	}
+	return num(float64(int(v.num())))
+}
+
+// Compare numbers l and r exactly, returning -1, 0, or 1. This is used by
+// the comparison opcodes when the float64 values of l and r are equal, to
+// tell apart numbers that round to the same float64.
+func (p *interp) compareExact(l, r value) int {
+	if p.numMode == bigMode {
+		return p.bigCompare(l, r)
+	}
+	return compareInts(l, r)
+}
+
+// Perform augmented assignment operation in one of the exact modes.
+func (p *interp) exactAugAssignOp(op compiler.AugOp, l, r value) (value, error) {
+	switch op {
+	case compiler.AugOpAdd:
+		return p.exactAdd(l, r), nil
+	case compiler.AugOpSub:
+		return p.exactSub(l, r), nil
+	case compiler.AugOpMul:
+		return p.exactMul(l, r), nil
+	case compiler.AugOpDiv:
+		if r.num() == 0.0 {
+			return null(), newError("division by zero")
+		}
+		return p.exactDiv(l, r), nil
+	case compiler.AugOpPow:
+		return p.exactPow(l, r), nil
+	default: // AugOpMod
+		if r.num() == 0.0 {
+			return null(), newError("division by zero in mod")
+		}
+		return p.exactMod(l, r), nil
+	}
+}
+
+// Convert v to an int64, truncating toward zero. In the exact modes,
+// integers are converted without going through float64.
+func (p *interp) toInt64(v value) int64 {
+	switch p.numMode {
+	case intMode:
+		if i, ok := v.int(); ok {
+			return i
+		}
+	case bigMode:
+		if f, ok := p.toBig(v); ok {
+			i, _ := f.Int64()
+			return i
+		}
+	}
+	return int64(v.num())
+}
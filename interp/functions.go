@@ -6,10 +6,13 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
+	"math/bits"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/nuvolaris/goawk/internal/ast"
@@ -20,58 +23,99 @@ import (
 // its return value (or null value if it doesn't return anything).
 func (p *interp) callNative(index int, args []value) (value, error) {
 	f := p.nativeFuncs[index]
-	minIn := len(f.in) // Minimum number of args we should pass
+	values := make([]reflect.Value, 0, 7) // up to 7 args won't require heap allocation
+	in := f.in
+	if f.hasContext {
+		// First parameter is the *CallContext, not passed from AWK
+		values = append(values, reflect.ValueOf(&CallContext{p}))
+		in = in[1:]
+	}
+	offset := len(values)
+
+	minIn := len(in) // Minimum number of args we should pass
 	var variadicType reflect.Type
 	if f.isVariadic {
-		variadicType = f.in[len(f.in)-1].Elem()
+		variadicType = in[len(in)-1].Elem()
 		minIn--
 	}
 
 	// Build list of args to pass to function
-	values := make([]reflect.Value, 0, 7) // up to 7 args won't require heap allocation
 	for i, a := range args {
 		var argType reflect.Type
-		if !f.isVariadic || i < len(f.in)-1 {
-			argType = f.in[i]
+		if !f.isVariadic || i < len(in)-1 {
+			argType = in[i]
 		} else {
 			// Final arg(s) when calling a variadic are all of this type
 			argType = variadicType
 		}
-		values = append(values, p.toNative(a, argType))
+		native, err := p.toNative(a, argType)
+		if err != nil {
+			return null(), err
+		}
+		values = append(values, native)
 	}
 	// Use zero value for any unspecified args
 	for i := len(args); i < minIn; i++ {
-		values = append(values, reflect.Zero(f.in[i]))
+		values = append(values, reflect.Zero(in[i]))
 	}
 
-	// Call Go function, determine return value
+	// Call Go function, then copy any changes it made to array arguments
+	// back to the AWK arrays
 	outs := f.value.Call(values)
+	for i, a := range args {
+		if a.typ == typeArray {
+			p.updateArrayFromNative(p.subarrayMap(a), values[offset+i])
+		}
+	}
+
+	// Determine return value
 	switch len(outs) {
 	case 0:
 		// No return value, return null value to AWK
 		return null(), nil
 	case 1:
 		// Single return value
-		return fromNative(outs[0]), nil
+		return p.fromNative(outs[0]), nil
 	case 2:
 		// Two-valued return of (scalar, error)
 		if !outs[1].IsNil() {
 			return null(), outs[1].Interface().(error)
 		}
-		return fromNative(outs[0]), nil
+		return p.fromNative(outs[0]), nil
 	default:
 		// Should never happen (checked at parse time)
 		panic(fmt.Sprintf("unexpected number of return values: %d", len(outs)))
 	}
 }
 
-// Convert from an AWK value to a native Go value
-func (p *interp) toNative(v value, typ reflect.Type) reflect.Value {
+// Convert from an AWK value to a native Go value. It's an error to pass
+// a scalar to a map parameter, which can happen if the program was parsed
+// with a different signature in ParserConfig.Funcs.
+func (p *interp) toNative(v value, typ reflect.Type) (reflect.Value, error) {
+	if typ.Kind() == reflect.Map {
+		if v.typ != typeArray {
+			return reflect.Value{}, newError("can't pass scalar as array argument of type %s", typ)
+		}
+		array := p.subarrayMap(v)
+		if typ == mapStringStringType {
+			m := make(map[string]string, len(array))
+			for k, elem := range array {
+				m[k] = p.toString(elem)
+			}
+			return reflect.ValueOf(m), nil
+		}
+		return reflect.ValueOf(p.arrayToMap(array)), nil
+	}
+	return p.scalarToNative(v, typ), nil
+}
+
+// Convert from an AWK scalar value to a native Go value
+func (p *interp) scalarToNative(v value, typ reflect.Type) reflect.Value {
 	switch typ.Kind() {
 	case reflect.Bool:
 		return reflect.ValueOf(v.boolean())
 	case reflect.Int:
-		return reflect.ValueOf(int(v.num()))
+		return reflect.ValueOf(int(p.toInt64(v)))
 	case reflect.Int8:
 		return reflect.ValueOf(int8(v.num()))
 	case reflect.Int16:
@@ -79,7 +123,7 @@ func (p *interp) toNative(v value, typ reflect.Type) reflect.Value {
 	case reflect.Int32:
 		return reflect.ValueOf(int32(v.num()))
 	case reflect.Int64:
-		return reflect.ValueOf(int64(v.num()))
+		return reflect.ValueOf(p.toInt64(v))
 	case reflect.Uint:
 		return reflect.ValueOf(uint(v.num()))
 	case reflect.Uint8:
@@ -109,7 +153,7 @@ func (p *interp) toNative(v value, typ reflect.Type) reflect.Value {
 }
 
 // Convert from a native Go value to an AWK value
-func fromNative(v reflect.Value) value {
+func (p *interp) fromNative(v reflect.Value) value {
 	switch v.Kind() {
 	case reflect.Bool:
 		return boolean(v.Bool())
@@ -127,15 +171,98 @@ func fromNative(v reflect.Value) value {
 		}
 		// Shouldn't happen: prevented by checkNativeFunc
 		panic(fmt.Sprintf("unexpected return slice: %s", v.Type().Elem().Kind()))
+	case reflect.Map:
+		return p.subarray(p.arrayFromNative(v))
 	default:
 		// Shouldn't happen: prevented by checkNativeFunc
 		panic(fmt.Sprintf("unexpected return type: %s", v.Kind()))
 	}
 }
 
+// Return a new array with the elements of the Go map m, which is a
+// map[string]string or map[string]interface{}. Strings from a
+// map[string]string are "numeric strings", like the result of split().
+func (p *interp) arrayFromNative(m reflect.Value) map[string]value {
+	array := make(map[string]value, m.Len())
+	switch m := m.Interface().(type) {
+	case map[string]string:
+		for k, s := range m {
+			array[k] = numStr(s)
+		}
+	case map[string]interface{}:
+		for k, v := range m {
+			array[k] = p.fromInterface(v)
+		}
+	}
+	return array
+}
+
+// Update array to match the Go map m it was passed to a native function
+// as, converting elements like arrayFromNative. Only elements whose Go
+// value was changed are set, so the others keep their AWK values (such as
+// exact numbers, numeric strings, and subarrays).
+func (p *interp) updateArrayFromNative(array map[string]value, m reflect.Value) {
+	switch m := m.Interface().(type) {
+	case map[string]string:
+		for k, elem := range array {
+			s, ok := m[k]
+			if !ok {
+				delete(array, k)
+			} else if s != p.toString(elem) {
+				array[k] = numStr(s)
+			}
+		}
+		for k, s := range m {
+			if _, ok := array[k]; !ok {
+				array[k] = numStr(s)
+			}
+		}
+	case map[string]interface{}:
+		for k, elem := range array {
+			v, ok := m[k]
+			if !ok {
+				delete(array, k)
+			} else if !reflect.DeepEqual(v, p.valueToInterface(elem)) {
+				array[k] = p.fromInterface(v)
+			}
+		}
+		for k, v := range m {
+			if _, ok := array[k]; !ok {
+				array[k] = p.fromInterface(v)
+			}
+		}
+	}
+}
+
+// Convert a value from a native map[string]interface{} to an AWK value.
+// Nested maps and slices become subarrays (slices are indexed from 1), and
+// other unsupported types are converted to strings.
+func (p *interp) fromInterface(v interface{}) value {
+	switch v := v.(type) {
+	case nil:
+		return null()
+	case NumStr:
+		return numStr(string(v))
+	case map[string]string, map[string]interface{}:
+		return p.fromNative(reflect.ValueOf(v))
+	case []interface{}:
+		array := make(map[string]value, len(v))
+		for i, elem := range v {
+			array[strconv.Itoa(i+1)] = p.fromInterface(elem)
+		}
+		return p.subarray(array)
+	}
+	rv := reflect.ValueOf(v)
+	if validNativeType(rv.Type()) {
+		return p.fromNative(rv)
+	}
+	return str(fmt.Sprint(v))
+}
+
 // Used for caching native function type information on init
 type nativeFunc struct {
 	isVariadic bool
+	hasContext bool // first parameter is *CallContext
 	in         []reflect.Type
 	value      reflect.Value
 }
@@ -156,6 +283,12 @@ func (p *interp) initNativeFuncs(funcs map[string]interface{}) error {
 		names = append(names, name)
 	}
 	sort.Strings(names)
+	for i, called := range p.program.Compiled.NativeFuncs() {
+		if called.Name != "" && (i >= len(names) || names[i] != called.Name ||
+			!sameNativeSignature(called, reflect.TypeOf(funcs[called.Name]))) {
+			return newError("native function %q not in Config.Funcs or parsed with different Funcs", called.Name)
+		}
+	}
 	p.nativeFuncs = make([]nativeFunc, len(names))
 	for i, name := range names {
 		f := funcs[name]
@@ -166,6 +299,7 @@ func (p *interp) initNativeFuncs(funcs map[string]interface{}) error {
 		}
 		p.nativeFuncs[i] = nativeFunc{
 			isVariadic: typ.IsVariadic(),
+			hasContext: len(in) > 0 && in[0] == callContextType,
 			in:         in,
 			value:      reflect.ValueOf(f),
 		}
@@ -173,6 +307,25 @@ func (p *interp) initNativeFuncs(funcs map[string]interface{}) error {
 	return nil
 }
 
+// Report whether native function type typ matches the signature f was
+// parsed with: the same number of parameters passed from AWK, maps for the
+// same parameters, and a map result only if f was parsed with one.
+func sameNativeSignature(f ast.NativeFunc, typ reflect.Type) bool {
+	first := 0
+	if typ.NumIn() > 0 && typ.In(0) == callContextType {
+		first = 1
+	}
+	if typ.NumIn()-first != len(f.Arrays) || typ.IsVariadic() != f.Variadic {
+		return false
+	}
+	for i, isArray := range f.Arrays {
+		if (typ.In(first+i).Kind() == reflect.Map) != isArray {
+			return false
+		}
+	}
+	return (typ.NumOut() > 0 && typ.Out(0).Kind() == reflect.Map) == f.ArrayResult
+}
+
 // Got this trick from the Go stdlib text/template source
 var errorType = reflect.TypeOf((*error)(nil)).Elem()
 
@@ -190,10 +343,13 @@ func checkNativeFunc(name string, f interface{}) error {
 	}
 	for i := 0; i < typ.NumIn(); i++ {
 		param := typ.In(i)
+		if i == 0 && param == callContextType {
+			continue
+		}
 		if typ.IsVariadic() && i == typ.NumIn()-1 {
 			param = param.Elem()
 		}
-		if !validNativeType(param) {
+		if !validNativeType(param) && (!isArrayType(param) || typ.IsVariadic() && i == typ.NumIn()-1) {
 			return newError("native function %q param %d is not int or string", name, i)
 		}
 	}
@@ -202,13 +358,13 @@ func checkNativeFunc(name string, f interface{}) error {
 	case 0:
 		// No return value is fine
 	case 1:
-		// Single scalar return value is fine
-		if !validNativeType(typ.Out(0)) {
+		// Single scalar or array return value is fine
+		if !validNativeType(typ.Out(0)) && !isArrayType(typ.Out(0)) {
 			return newError("native function %q return value is not int or string", name)
 		}
 	case 2:
-		// Returning (scalar, error) is handled too
-		if !validNativeType(typ.Out(0)) {
+		// Returning (scalar, error) or (array, error) is handled too
+		if !validNativeType(typ.Out(0)) && !isArrayType(typ.Out(0)) {
 			return newError("native function %q first return value is not int or string", name)
 		}
 		if typ.Out(1) != errorType {
@@ -241,10 +397,28 @@ func validNativeType(typ reflect.Type) bool {
 	}
 }
 
+var (
+	mapStringStringType    = reflect.TypeOf(map[string]string(nil))
+	mapStringInterfaceType = reflect.TypeOf(map[string]interface{}(nil))
+)
+
+// Return true if typ is a Go map type used to pass arrays to and from
+// native functions.
+func isArrayType(typ reflect.Type) bool {
+	return typ == mapStringStringType || typ == mapStringInterfaceType
+}
+
 // Guts of the split() function
-func (p *interp) split(s string, scope ast.VarScope, index int, fs string) (int, error) {
+func (p *interp) split(s string, scope ast.VarScope, index int, fs, fpat string) (int, error) {
 	var parts []string
-	if fs == " " {
+	if fpat != "" {
+		// Non-empty field pattern means split by content, like FPAT
+		re, err := p.compileRegex(fpat)
+		if err != nil {
+			return 0, err
+		}
+		parts = splitFieldPat(s, re)
+	} else if fs == " " {
 		parts = strings.Fields(s)
 	} else if s == "" {
 		// Leave parts 0 length on empty string
@@ -257,14 +431,95 @@ func (p *interp) split(s string, scope ast.VarScope, index int, fs string) (int,
 		}
 		parts = re.Split(s, -1)
 	}
-	array := make(map[string]value, len(parts))
+	// Clear and fill the array in place, as it may be a subarray
+	array := p.array(scope, index)
+	for k := range array {
+		delete(array, k)
+	}
 	for i, part := range parts {
 		array[strconv.Itoa(i+1)] = numStr(part)
 	}
-	p.arrays[p.arrayIndex(scope, index)] = array
 	return len(array), nil
 }
 
+// Guts of the 3-argument match() function: set RSTART and RLENGTH as usual,
+// and fill the array with the matched text and capture groups, as well as
+// their start positions and lengths: array[n], array[n, "start"], and
+// array[n, "length"]. Groups that didn't participate in the match aren't set.
+func (p *interp) matchArray(s, regex string, scope ast.VarScope, index int) (int, error) {
+	re, err := p.compileRegex(regex)
+	if err != nil {
+		return 0, err
+	}
+	array := p.array(scope, index)
+	for k := range array {
+		delete(array, k)
+	}
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		p.matchStart = 0
+		p.matchLength = -1
+	} else {
+		p.matchStart = loc[0] + 1
+		p.matchLength = loc[1] - loc[0]
+		for i := 0; i < len(loc); i += 2 {
+			if loc[i] < 0 {
+				continue
+			}
+			n := strconv.Itoa(i / 2)
+			array[n] = numStr(s[loc[i]:loc[i+1]])
+			array[n+p.subscriptSep+"start"] = num(float64(loc[i] + 1))
+			array[n+p.subscriptSep+"length"] = num(float64(loc[i+1] - loc[i]))
+		}
+	}
+	return p.matchStart, nil
+}
+
+// Guts of the gensub() function: replace all matches of regex in "in" if
+// global is true, otherwise only the n'th match. In repl, "&" and "\\0" are
+// replaced with the matched text and "\\1" through "\\9" with the text of
+// the corresponding capture group; a backslash before any other character
+// produces that character.
+func (p *interp) gensub(regex, repl, in string, global bool, n int) (string, error) {
+	re, err := p.compileRegex(regex)
+	if err != nil {
+		return "", err
+	}
+	matches := re.FindAllStringSubmatchIndex(in, -1)
+	if !global {
+		if n > len(matches) {
+			return in, nil
+		}
+		matches = matches[n-1 : n]
+	}
+	out := make([]byte, 0, len(in)+64)
+	last := 0
+	for _, match := range matches {
+		out = append(out, in[last:match[0]]...)
+		for i := 0; i < len(repl); i++ {
+			switch c := repl[i]; {
+			case c == '&':
+				out = append(out, in[match[0]:match[1]]...)
+			case c == '\\' && i+1 < len(repl):
+				i++
+				if d := repl[i]; d >= '0' && d <= '9' {
+					group := int(d - '0')
+					if 2*group+1 < len(match) && match[2*group] >= 0 {
+						out = append(out, in[match[2*group]:match[2*group+1]]...)
+					}
+				} else {
+					out = append(out, d)
+				}
+			default:
+				out = append(out, c)
+			}
+		}
+		last = match[1]
+	}
+	out = append(out, in[last:]...)
+	return string(out), nil
+}
+
 // Guts of the sub() and gsub() functions
 func (p *interp) sub(regex, repl, in string, global bool) (out string, num int, err error) {
 	re, err := p.compileRegex(regex)
@@ -380,16 +635,26 @@ func (p *interp) sprintf(format string, args []value) (string, error) {
 	converted := make([]interface{}, 0, 7) // up to 7 args won't require heap allocation
 	for i, t := range types {
 		a := args[i]
+		if p.numMode == bigMode {
+			if v, ok := p.bigFormatArg(t, a); ok {
+				converted = append(converted, v)
+				continue
+			}
+		}
 		var v interface{}
 		switch t {
 		case 's':
 			v = p.toString(a)
 		case 'd':
-			v = int(a.num())
+			v = int(p.toInt64(a))
 		case 'f':
 			v = a.num()
 		case 'u':
-			v = uint(a.num())
+			if i, ok := a.int(); ok && p.numMode == intMode {
+				v = uint(i)
+			} else {
+				v = uint(a.num())
+			}
 		case 'c':
 			var c []byte
 			n, isStr := a.isTrueStr()
@@ -411,3 +676,210 @@ func (p *interp) sprintf(format string, args []value) (string, error) {
 	}
 	return fmt.Sprintf(format, converted...), nil
 }
+
+// Guts of the mktime() function: convert a date specification in the form
+// "YYYY MM DD HH MM SS [DST]" to seconds since the epoch, or return -1 if
+// the specification is invalid. Out of range values are normalized, so
+// "2022 1 32 0 0 0" is February 1. The DST field is accepted but ignored, as
+// Go determines daylight saving time from the time zone.
+func (p *interp) mktime(spec string, utc bool) int64 {
+	fields := strings.Fields(spec)
+	if len(fields) < 6 || len(fields) > 7 {
+		return -1
+	}
+	var nums [6]int
+	for i := range nums {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return -1
+		}
+		nums[i] = n
+	}
+	loc := time.UTC
+	if !utc {
+		loc = p.now().Location()
+	}
+	t := time.Date(nums[0], time.Month(nums[1]), nums[2], nums[3], nums[4], nums[5], 0, loc)
+	return t.Unix()
+}
+
+// Guts of the strftime() function: format timestamp (seconds since the
+// epoch) according to format, in UTC or local time.
+func (p *interp) strftime(format string, timestamp float64, utc bool) string {
+	t := time.Unix(int64(timestamp), 0)
+	if utc {
+		t = t.UTC()
+	} else {
+		t = t.In(p.now().Location())
+	}
+	return strftime(format, t)
+}
+
+var (
+	shortDayNames   = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+	shortMonthNames = []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+)
+
+// Format t according to the C strftime format, using the "C" locale.
+// Unknown conversions are output as is.
+func strftime(format string, t time.Time) string {
+	buf := make([]byte, 0, 64)
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 >= len(format) {
+			buf = append(buf, format[i])
+			continue
+		}
+		i++
+		switch c := format[i]; c {
+		case 'a':
+			buf = append(buf, shortDayNames[t.Weekday()]...)
+		case 'A':
+			buf = append(buf, t.Weekday().String()...)
+		case 'b', 'h':
+			buf = append(buf, shortMonthNames[t.Month()-1]...)
+		case 'B':
+			buf = append(buf, t.Month().String()...)
+		case 'c':
+			buf = append(buf, strftime("%a %b %e %H:%M:%S %Y", t)...)
+		case 'C':
+			buf = appendInt(buf, t.Year()/100, 2, '0')
+		case 'd':
+			buf = appendInt(buf, t.Day(), 2, '0')
+		case 'D', 'x':
+			buf = append(buf, strftime("%m/%d/%y", t)...)
+		case 'e':
+			buf = appendInt(buf, t.Day(), 2, ' ')
+		case 'F':
+			buf = append(buf, strftime("%Y-%m-%d", t)...)
+		case 'g':
+			year, _ := t.ISOWeek()
+			buf = appendInt(buf, year%100, 2, '0')
+		case 'G':
+			year, _ := t.ISOWeek()
+			buf = appendInt(buf, year, 4, '0')
+		case 'H':
+			buf = appendInt(buf, t.Hour(), 2, '0')
+		case 'I':
+			buf = appendInt(buf, (t.Hour()+11)%12+1, 2, '0')
+		case 'j':
+			buf = appendInt(buf, t.YearDay(), 3, '0')
+		case 'k':
+			buf = appendInt(buf, t.Hour(), 2, ' ')
+		case 'l':
+			buf = appendInt(buf, (t.Hour()+11)%12+1, 2, ' ')
+		case 'm':
+			buf = appendInt(buf, int(t.Month()), 2, '0')
+		case 'M':
+			buf = appendInt(buf, t.Minute(), 2, '0')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'p':
+			if t.Hour() < 12 {
+				buf = append(buf, "AM"...)
+			} else {
+				buf = append(buf, "PM"...)
+			}
+		case 'r':
+			buf = append(buf, strftime("%I:%M:%S %p", t)...)
+		case 'R':
+			buf = append(buf, strftime("%H:%M", t)...)
+		case 's':
+			buf = strconv.AppendInt(buf, t.Unix(), 10)
+		case 'S':
+			buf = appendInt(buf, t.Second(), 2, '0')
+		case 't':
+			buf = append(buf, '\t')
+		case 'T', 'X':
+			buf = append(buf, strftime("%H:%M:%S", t)...)
+		case 'u':
+			buf = appendInt(buf, (int(t.Weekday())+6)%7+1, 1, '0')
+		case 'U':
+			buf = appendInt(buf, (t.YearDay()+6-int(t.Weekday()))/7, 2, '0')
+		case 'V':
+			_, week := t.ISOWeek()
+			buf = appendInt(buf, week, 2, '0')
+		case 'w':
+			buf = appendInt(buf, int(t.Weekday()), 1, '0')
+		case 'W':
+			buf = appendInt(buf, (t.YearDay()+6-(int(t.Weekday())+6)%7)/7, 2, '0')
+		case 'y':
+			buf = appendInt(buf, t.Year()%100, 2, '0')
+		case 'Y':
+			buf = strconv.AppendInt(buf, int64(t.Year()), 10)
+		case 'z':
+			buf = append(buf, t.Format("-0700")...)
+		case 'Z':
+			buf = append(buf, t.Format("MST")...)
+		case '%':
+			buf = append(buf, '%')
+		default:
+			buf = append(buf, '%', c)
+		}
+	}
+	return string(buf)
+}
+
+// Append n to buf, padded on the left to width with pad.
+func appendInt(buf []byte, n, width int, pad byte) []byte {
+	s := strconv.Itoa(n)
+	for i := len(s); i < width; i++ {
+		buf = append(buf, pad)
+	}
+	return append(buf, s...)
+}
+
+// Convert an argument of the bitwise function name to an unsigned integer,
+// as gawk does: non-integer values are truncated toward zero, and values
+// too large to fit are clamped. Negative values are an error. In the exact
+// modes, integers are converted without going through float64.
+func (p *interp) bitwiseArg(name string, v value) (uint64, error) {
+	if p.numMode != floatMode {
+		if i, ok := v.int(); ok {
+			if i < 0 {
+				return 0, newError("%s: negative value %d is not allowed", name, i)
+			}
+			return uint64(i), nil
+		}
+	}
+	n := v.num()
+	switch {
+	case math.IsNaN(n) || n < 0:
+		return 0, newError("%s: negative value %s is not allowed", name, strconv.FormatFloat(n, 'g', -1, 64))
+	case n >= 1<<64:
+		return math.MaxUint64, nil
+	default:
+		return uint64(n), nil
+	}
+}
+
+// Convert both arguments of a two-argument bitwise function.
+func (p *interp) bitwiseArgs(name string, l, r value) (uint64, uint64, error) {
+	x, err := p.bitwiseArg(name, l)
+	if err != nil {
+		return 0, 0, err
+	}
+	y, err := p.bitwiseArg(name, r)
+	if err != nil {
+		return 0, 0, err
+	}
+	return x, y, nil
+}
+
+// Convert the result of a bitwise function to a number. Like gawk, keep
+// only as many bits as a float64 can represent exactly (53), starting from
+// the lowest one that's set, so that compl(0) is 2^53-1 and large shifted
+// values stay exact. In the exact modes, results that fit in an int64 are
+// kept exact.
+func (p *interp) bitwiseResult(n uint64) value {
+	if n <= math.MaxInt64 {
+		switch p.numMode {
+		case intMode:
+			return integer(int64(n))
+		case bigMode:
+			return bigNum(p.newBig().SetUint64(n))
+		}
+	}
+	const fractionBits = 53
+	shift := bits.TrailingZeros64(n | 1<<(64-fractionBits))
+	return num(float64(n & ((1<<fractionBits - 1) << uint(shift))))
+}
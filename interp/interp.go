@@ -19,6 +19,7 @@ import (
 	"io"
 	"io/ioutil"
 	"math"
+	"math/big"
 	"math/rand"
 	"os"
 	"os/exec"
@@ -26,6 +27,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/nuvolaris/goawk/internal/ast"
@@ -99,6 +101,12 @@ type interp struct {
 	callDepth   int
 	nativeFuncs []nativeFunc
 
+	// Maps of subarray values (see subarray)
+	subarrays          []map[string]value
+	freeSubarrays      []int
+	newSubarrays       int
+	collectSubarraysAt int
+
 	// File, line, and field handling
 	filename        value
 	line            string
@@ -119,6 +127,10 @@ type interp struct {
 	outputFormat     string
 	fieldSep         string
 	fieldSepRegex    *regexp.Regexp
+	fieldWidthsStr   string
+	fieldWidths      []fieldWidth
+	fieldPat         string
+	fieldPatRegex    *regexp.Regexp
 	recordSep        string
 	recordSepRegex   *regexp.Regexp
 	recordTerminator string
@@ -131,11 +143,13 @@ type interp struct {
 	csvInputConfig   CSVInputConfig
 	outputMode       IOMode
 	csvOutputConfig  CSVOutputConfig
+	precision        uint
+	roundMode        big.RoundingMode
 
 	// Parsed program, compiled functions and constants
 	program   *parser.Program
 	functions []compiler.Function
-	nums      []float64
+	nums      []value // number constants, converted for the number mode
 	strs      []string
 	regexes   []*regexp.Regexp
 
@@ -145,13 +159,30 @@ type interp struct {
 	ctxDone  <-chan struct{}
 	ctxOps   int
 
+	// Debugger support (see Config.Debugger)
+	debugger    *Debugger
+	debugFrames []debugFrame
+
+	// Streaming support (for Interpreter.Start, Feed, and Finish)
+	streaming    bool
+	streamExited bool
+	inRange      []bool
+
+	// Parallel mode support (see Config.Parallel)
+	parallel       int
+	parallelMerge  func(name string, initial interface{}, values []interface{}) (interface{}, bool)
+	parallelWorker bool
+
 	// Misc pieces of state
+	numMode          numberMode
 	random           *rand.Rand
 	randSeed         float64
+	now              func() time.Time
 	exitStatus       int
 	regexCache       map[string]*regexp.Regexp
 	formatCache      map[string]cachedFormat
 	csvJoinFieldsBuf bytes.Buffer
+	jsonlBuf         []byte
 }
 
 // Various const configuration. Could make these part of Config if
@@ -213,6 +244,26 @@ type Config struct {
 	// bool, integer and floating point types (excluding complex),
 	// and string types (string or []byte).
 	//
+	// Non-variadic parameters may also be of type map[string]string or
+	// map[string]interface{}, in which case the AWK argument must be an
+	// array (or a subarray like a[k]). Any changes the function makes
+	// to the map are copied back to the AWK array when it returns. In
+	// a map[string]interface{}, numbers are float64 (int64 in integer
+	// mode, or *big.Float in -M mode), strings are string, and
+	// subarrays are nested map[string]interface{} values.
+	//
+	// Similarly, a function may return one of these map types, and the
+	// result must be assigned to an array, as in "arr = f(x)", or to an
+	// array element, which makes it a subarray. Strings in a returned
+	// map[string]string are treated like the result of split(). In a
+	// returned map[string]interface{}, nested maps and []interface{}
+	// slices become subarrays (slices are indexed from 1).
+	//
+	// If a function's first parameter is of type *CallContext, it's
+	// not counted as an AWK argument; instead, the interpreter passes
+	// a CallContext that gives access to the execution context,
+	// variables, the current record, and the output writer.
+	//
 	// It's not an error to call a Go function from AWK with fewer
 	// arguments than it has parameters in Go. In this case, the zero
 	// value will be used for any additional parameters. However, it
@@ -246,6 +297,48 @@ type Config struct {
 	// non-nil empty slice, []string{}.
 	Environ []string
 
+	// Function that returns the current time, used by the systime() and
+	// strftime() builtins. The location of the returned time is used as
+	// the local time zone by strftime() and mktime(). If nil (the default),
+	// time.Now is used.
+	Now func() time.Time
+
+	// Set to true to enable integer mode, in which integral numbers are
+	// exact 64-bit integers rather than float64 values, so that large
+	// integers such as IDs and byte counts above 2^53 aren't corrupted.
+	// Numeric strings (such as input fields) that are decimal integers
+	// and fit in an int64 are converted exactly, and addition,
+	// subtraction, multiplication, modulo, exponentiation with a
+	// non-negative exponent, and division that has no remainder give
+	// integer results. If an operand isn't an integer, or the result
+	// would overflow, the usual float64 arithmetic is used instead.
+	// Comparisons, int(), printf's %d, and string conversion are exact
+	// for integers, and integers are passed to native functions and
+	// returned from Interpreter.Array and CallContext.Var as int64.
+	//
+	// Numeric literals in the program source are float64 values, so
+	// literals above 2^53 lose precision; use a string instead, for
+	// example "9007199254740993"+0.
+	IntegerMode bool
+
+	// If nonzero, enable arbitrary-precision mode (like gawk's -M) and set
+	// the initial value of PREC to this many bits of precision. Numbers
+	// are backed by math/big values: integers are exact no matter how
+	// large they get, and other results (such as division with a
+	// remainder) are rounded to PREC bits using ROUNDMODE. Numeric strings
+	// and literals such as 0.1 are converted from decimal at that
+	// precision, and printf's %d, %f, %e, and %g conversions, as well as
+	// CONVFMT and OFMT, format numbers with all their digits. Functions
+	// like sqrt() and sin() still use float64. This takes precedence over
+	// IntegerMode.
+	//
+	// PREC may be set to a number of bits or to one of the names "half",
+	// "single", "double", "quad", or "oct". ROUNDMODE may be set to "N"
+	// (round to nearest, ties to even; the default), "Z" (toward zero),
+	// "U" (toward +infinity), "D" (toward -infinity), or "A" (away from
+	// zero). Numbers are converted to big.Float values when returned to Go.
+	Precision int
+
 	// Mode for parsing input fields and record: default is to use normal FS
 	// and RS behaviour. If set to CSVMode or TSVMode, FS and RS are ignored,
 	// and input records are parsed as comma-separated values or tab-separated
@@ -253,8 +346,14 @@ type Config struct {
 	// "encoding/csv" package, but FieldsPerRecord is not supported,
 	// LazyQuotes is always on, and TrimLeadingSpace is always off.
 	//
-	// You can also enable CSV or TSV input mode by setting INPUTMODE to "csv"
-	// or "tsv" in Vars or in the BEGIN block (those override this setting).
+	// If set to JSONLMode, each input line is parsed as a JSON object. Its
+	// values are available as $1 through $NF in document order, and by key
+	// using @"key" (nested keys use dotted paths such as @"user.id"). The
+	// FIELDS array is updated with the keys of each record.
+	//
+	// You can also enable CSV, TSV, or JSONL input mode by setting INPUTMODE
+	// to "csv", "tsv", or "jsonl" in Vars or in the BEGIN block (those
+	// override this setting).
 	//
 	// For further documentation about GoAWK's CSV support, see the full docs
 	// in "../docs/csv.md".
@@ -268,6 +367,9 @@ type Config struct {
 	// comment character, and enable header row parsing:
 	//
 	//     BEGIN { INPUTMODE="csv separator=| comment=# header" }
+	//
+	// To specify the field names explicitly instead of reading them from a
+	// header row, use "header=name1,name2,...".
 	CSVInput CSVInputConfig
 
 	// Mode for print output: default is to use normal OFS and ORS
@@ -276,9 +378,15 @@ type Config struct {
 	// respectively. Output is written as per RFC 4180 and the "encoding/csv"
 	// package.
 	//
-	// You can also enable CSV or TSV output mode by setting OUTPUTMODE to
-	// "csv" or "tsv" in Vars or in the BEGIN block (those override this
-	// setting).
+	// If set to JSONLMode, the "print" statement with one or more arguments
+	// outputs a JSON object per record. The object's keys are taken from
+	// OFIELDS if it's not empty, otherwise from FIELDS (or the field number
+	// if there's no name). Numbers and numeric strings are output as JSON
+	// numbers, other values as JSON strings.
+	//
+	// You can also enable CSV, TSV, or JSONL output mode by setting
+	// OUTPUTMODE to "csv", "tsv", or "jsonl" in Vars or in the BEGIN block
+	// (those override this setting).
 	OutputMode IOMode
 
 	// Additional options if OutputMode is CSVMode or TSVMode. The zero value
@@ -289,6 +397,55 @@ type Config struct {
 	//
 	//     BEGIN { OUTPUTMODE="csv separator=|" }
 	CSVOutput CSVOutputConfig
+
+	// If non-nil, enable source-level debugging: the debugger's Stop
+	// function is called before executing statements as per its breakpoints
+	// and step commands.
+	Debugger *Debugger
+
+	// If greater than 1, process input records in parallel using this many
+	// worker goroutines (this has no effect if Debugger is set). BEGIN runs
+	// as usual, then the input is split into chunks: when records are
+	// newline-separated, each input file is split at newlines into chunks
+	// of a few hundred kilobytes, otherwise (for example, with a different
+	// RS or in CSV mode) each input file is a chunk. Each worker runs the
+	// pattern-actions on the chunks it's given using its own copy of the
+	// global variables as they were after BEGIN. Output from print and
+	// printf is written in input order.
+	//
+	// After the input has been processed, global variables are merged
+	// back together before END runs. A variable changed by only one
+	// worker takes that worker's value. A variable the pattern-actions
+	// only add to, like "n++" or "sum[$1] += $2", is summed (the initial
+	// value plus the sum of each worker's change, treating numeric
+	// strings as numbers), one they only append to, like "s = s $1", is
+	// appended, and arrays are merged element by element in the same way.
+	// So counts and totals give the same result as serial execution. If
+	// the pattern-actions change a variable in other ways, like "max =
+	// $2" or "last = $0", the input is processed serially unless
+	// ParallelMerge is set. Special variables and arrays aren't merged,
+	// except that NR, FNR, FILENAME, and $0 are set as of the last record.
+	//
+	// Because chunks are processed independently, NR is the record number
+	// across all input only when records are newline-separated, and the
+	// order in which the workers' strings are appended isn't defined. If
+	// the program calls exit, later chunks are skipped, but their workers'
+	// variables may already have changed. If an action has a range
+	// pattern, the pattern-actions use getline or redirect output (other
+	// than to "/dev/stderr"), or Args contains var=value assignments, the
+	// input is processed serially.
+	Parallel int
+
+	// If non-nil in parallel mode, ParallelMerge is called to merge the
+	// value of each global variable changed by at least one worker, with
+	// the variable's name, its value before the workers started, and the
+	// value from each worker. Scalars are passed as for
+	// Interpreter.GetVar, and arrays as for Interpreter.Array. The
+	// function returns the merged value (a map[string]interface{} for
+	// arrays), or false to use the default merge. For variables changed
+	// in other ways than adding or appending, the default merge requires
+	// the workers' values to be the same, and returns an error if not.
+	ParallelMerge func(name string, initial interface{}, values []interface{}) (interface{}, bool)
 }
 
 // IOMode specifies the input parsing or print output mode.
@@ -304,6 +461,10 @@ const (
 
 	// TSVMode uses tab-separated value mode for input or output.
 	TSVMode IOMode = 2
+
+	// JSONLMode uses JSON Lines mode (one JSON object per line) for input
+	// or output.
+	JSONLMode IOMode = 3
 )
 
 // CSVInputConfig holds additional configuration for when InputMode is CSVMode
@@ -321,6 +482,12 @@ type CSVInputConfig struct {
 	// is, a list of field names), and enable the @"field" syntax to get a
 	// field by name as well as the FIELDS special array.
 	Header bool
+
+	// If non-empty, a comma-separated list of field names to use instead of
+	// parsing a header row, for example "name,age". The first row of each
+	// input file is treated as a normal record, and @"field" and FIELDS are
+	// available as if Header were true. This takes precedence over Header.
+	HeaderNames string
 }
 
 // CSVOutputConfig holds additional configuration for when OutputMode is
@@ -352,16 +519,19 @@ func newInterp(program *parser.Program) *interp {
 	p := &interp{
 		program:   program,
 		functions: program.Compiled.Functions,
-		nums:      program.Compiled.Nums,
 		strs:      program.Compiled.Strs,
 		regexes:   program.Compiled.Regexes,
 	}
 
+	p.setNums()
+	p.collectSubarraysAt = minCollectSubarrays
+
 	// Allocate memory for variables and virtual machine stack
 	p.globals = make([]value, len(program.Scalars))
 	p.stack = make([]value, initialStackSize)
-	p.arrays = make([]map[string]value, len(program.Arrays), len(program.Arrays)+initialStackSize)
-	for i := 0; i < len(program.Arrays); i++ {
+	numArrays := len(program.Arrays) + program.Compiled.TempArrays
+	p.arrays = make([]map[string]value, numArrays, numArrays+initialStackSize)
+	for i := 0; i < numArrays; i++ {
 		p.arrays[i] = make(map[string]value)
 	}
 
@@ -378,6 +548,8 @@ func newInterp(program *parser.Program) *interp {
 	p.outputFieldSep = " "
 	p.outputRecordSep = "\n"
 	p.subscriptSep = "\x1c"
+	p.precision = defaultPrecision
+	p.roundMode = big.ToNearestEven
 
 	p.inputStreams = make(map[string]io.ReadCloser)
 	p.outputStreams = make(map[string]io.WriteCloser)
@@ -414,6 +586,10 @@ func (p *interp) setExecuteConfig(config *Config) error {
 		if p.csvInputConfig != (CSVInputConfig{}) {
 			return newError("input mode configuration not valid in default input mode")
 		}
+	case JSONLMode:
+		if p.csvInputConfig != (CSVInputConfig{}) {
+			return newError("input mode configuration not valid in JSONL input mode")
+		}
 	}
 	p.outputMode = config.OutputMode
 	p.csvOutputConfig = config.CSVOutput
@@ -430,7 +606,32 @@ func (p *interp) setExecuteConfig(config *Config) error {
 		if p.csvOutputConfig != (CSVOutputConfig{}) {
 			return newError("output mode configuration not valid in default output mode")
 		}
+	case JSONLMode:
+		if p.csvOutputConfig != (CSVOutputConfig{}) {
+			return newError("output mode configuration not valid in JSONL output mode")
+		}
+	}
+
+	// Set up number mode (Vars can override PREC and ROUNDMODE)
+	p.numMode = floatMode
+	if config.IntegerMode {
+		p.numMode = intMode
+	}
+	if config.Precision < 0 || config.Precision > big.MaxPrec {
+		return newError("invalid precision %d", config.Precision)
+	}
+	if config.Precision > 0 {
+		p.numMode = bigMode
+		p.precision = uint(config.Precision)
 	}
+	p.setNums()
+
+	// Set up parallel mode
+	if config.Parallel < 0 {
+		return newError("invalid number of parallel workers %d", config.Parallel)
+	}
+	p.parallel = config.Parallel
+	p.parallelMerge = config.ParallelMerge
 
 	// Set up ARGV and other variables from config
 	argvIndex := p.program.Arrays["ARGV"]
@@ -440,6 +641,8 @@ func (p *interp) setExecuteConfig(config *Config) error {
 		p.setArrayValue(ast.ScopeGlobal, argvIndex, strconv.Itoa(i+1), numStr(arg))
 	}
 	p.noArgVars = config.NoArgVars
+	p.debugger = config.Debugger
+	p.debugFrames = p.debugFrames[:0]
 	p.filenameIndex = 1
 	p.hadFiles = false
 	for i := 0; i < len(config.Vars); i += 2 {
@@ -454,6 +657,9 @@ func (p *interp) setExecuteConfig(config *Config) error {
 	if err != nil {
 		return err
 	}
+	if p.csvInputConfig.HeaderNames != "" {
+		p.setFieldNames(strings.Split(p.csvInputConfig.HeaderNames, ","))
+	}
 	err = validateCSVOutputConfig(p.outputMode, p.csvOutputConfig)
 	if err != nil {
 		return err
@@ -481,6 +687,12 @@ func (p *interp) setExecuteConfig(config *Config) error {
 		p.shellCommand = defaultShellCommand
 	}
 
+	// Set up clock for systime() and friends
+	p.now = config.Now
+	if p.now == nil {
+		p.now = time.Now
+	}
+
 	// Set up I/O structures
 	p.noExec = config.NoExec
 	p.noFileWrites = config.NoFileWrites
@@ -517,6 +729,9 @@ func validateCSVInputConfig(mode IOMode, config CSVInputConfig) error {
 		config.Comment != 0 && !validCSVSeparator(config.Comment) {
 		return errCSVSeparator
 	}
+	if config.HeaderNames != "" && !validHeaderNames(config.HeaderNames) {
+		return newError("invalid CSV/TSV header names %q", config.HeaderNames)
+	}
 	return nil
 }
 
@@ -530,6 +745,16 @@ func validateCSVOutputConfig(mode IOMode, config CSVOutputConfig) error {
 	return nil
 }
 
+// Report whether names is a comma-separated list of non-empty field names.
+func validHeaderNames(names string) bool {
+	for _, name := range strings.Split(names, ",") {
+		if name == "" {
+			return false
+		}
+	}
+	return true
+}
+
 func validCSVSeparator(r rune) bool {
 	return r != 0 && r != '"' && r != '\r' && r != '\n' && utf8.ValidRune(r) && r != utf8.RuneError
 }
@@ -538,7 +763,7 @@ func (p *interp) executeAll() (int, error) {
 	defer p.closeAll()
 
 	// Execute the program: BEGIN, then pattern/actions, then END
-	err := p.execute(p.program.Compiled.Begin)
+	err := p.executeBlock("BEGIN", 0, nil, p.program.Compiled.BeginLines, p.program.Compiled.Begin)
 	if err != nil && err != errExit {
 		if p.checkCtx {
 			ctxErr := p.checkContextNow()
@@ -552,7 +777,11 @@ func (p *interp) executeAll() (int, error) {
 		return p.exitStatus, nil // only BEGIN specified, don't process input
 	}
 	if err != errExit {
-		err = p.execActions(p.program.Compiled.Actions)
+		if p.parallel > 1 {
+			err = p.execActionsParallel(p.program.Compiled.Actions)
+		} else {
+			err = p.execActions(p.program.Compiled.Actions)
+		}
 		if err != nil && err != errExit {
 			if p.checkCtx {
 				ctxErr := p.checkContextNow()
@@ -563,7 +792,7 @@ func (p *interp) executeAll() (int, error) {
 			return 0, err
 		}
 	}
-	err = p.execute(p.program.Compiled.End)
+	err = p.executeBlock("END", 0, nil, p.program.Compiled.EndLines, p.program.Compiled.End)
 	if err != nil && err != errExit {
 		if p.checkCtx {
 			ctxErr := p.checkContextNow()
@@ -597,8 +826,7 @@ func Exec(source, fieldSep string, input io.Reader, output io.Writer) error {
 
 // Execute pattern-action blocks (may be multiple)
 func (p *interp) execActions(actions []compiler.Action) error {
-	var inRange []bool
-lineLoop:
+	p.inRange = nil
 	for {
 		// Read and setup next line of input
 		line, err := p.nextLine()
@@ -611,64 +839,72 @@ lineLoop:
 		p.setLine(line, false)
 		p.reparseCSV = false
 
-		// Execute all the pattern-action blocks for each line
-		for i, action := range actions {
-			// First determine whether the pattern matches
-			matched := false
-			switch len(action.Pattern) {
-			case 0:
-				// No pattern is equivalent to pattern evaluating to true
-				matched = true
-			case 1:
-				// Single boolean pattern
+		err = p.execRecord(actions)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Execute all the pattern-action blocks for the current record
+func (p *interp) execRecord(actions []compiler.Action) error {
+	for i, action := range actions {
+		// First determine whether the pattern matches
+		matched := false
+		switch len(action.Pattern) {
+		case 0:
+			// No pattern is equivalent to pattern evaluating to true
+			matched = true
+		case 1:
+			// Single boolean pattern
+			err := p.execute(action.Pattern[0])
+			if err != nil {
+				return err
+			}
+			matched = p.pop().boolean()
+		case 2:
+			// Range pattern (matches between start and stop lines)
+			if p.inRange == nil {
+				p.inRange = make([]bool, len(actions))
+			}
+			if !p.inRange[i] {
 				err := p.execute(action.Pattern[0])
 				if err != nil {
 					return err
 				}
-				matched = p.pop().boolean()
-			case 2:
-				// Range pattern (matches between start and stop lines)
-				if inRange == nil {
-					inRange = make([]bool, len(actions))
-				}
-				if !inRange[i] {
-					err := p.execute(action.Pattern[0])
-					if err != nil {
-						return err
-					}
-					inRange[i] = p.pop().boolean()
-				}
-				matched = inRange[i]
-				if inRange[i] {
-					err := p.execute(action.Pattern[1])
-					if err != nil {
-						return err
-					}
-					inRange[i] = !p.pop().boolean()
-				}
+				p.inRange[i] = p.pop().boolean()
 			}
-			if !matched {
-				continue
-			}
-
-			// No action is equivalent to { print $0 }
-			if len(action.Body) == 0 {
-				err := p.printLine(p.output, p.line)
+			matched = p.inRange[i]
+			if p.inRange[i] {
+				err := p.execute(action.Pattern[1])
 				if err != nil {
 					return err
 				}
-				continue
+				p.inRange[i] = !p.pop().boolean()
 			}
+		}
+		if !matched {
+			continue
+		}
 
-			// Execute the body statements
-			err := p.execute(action.Body)
-			if err == errNext {
-				// "next" statement skips straight to next line
-				continue lineLoop
-			}
+		// No action is equivalent to { print $0 }
+		if len(action.Body) == 0 {
+			err := p.printLine(p.output, p.line)
 			if err != nil {
 				return err
 			}
+			continue
+		}
+
+		// Execute the body statements
+		err := p.executeBlock("action", i+1, nil, action.BodyLines, action.Body)
+		if err == errNext {
+			// "next" statement skips straight to next line
+			return nil
+		}
+		if err != nil {
+			return err
 		}
 	}
 	return nil
@@ -696,6 +932,10 @@ func (p *interp) getSpecial(index int) value {
 		return p.filename
 	case ast.V_FS:
 		return str(p.fieldSep)
+	case ast.V_FIELDWIDTHS:
+		return str(p.fieldWidthsStr)
+	case ast.V_FPAT:
+		return str(p.fieldPat)
 	case ast.V_OFMT:
 		return str(p.outputFormat)
 	case ast.V_OFS:
@@ -708,6 +948,10 @@ func (p *interp) getSpecial(index int) value {
 		return str(p.recordTerminator)
 	case ast.V_SUBSEP:
 		return str(p.subscriptSep)
+	case ast.V_PREC:
+		return num(float64(p.precision))
+	case ast.V_ROUNDMODE:
+		return str(roundModeString(p.roundMode))
 	case ast.V_INPUTMODE:
 		return str(inputModeString(p.inputMode, p.csvInputConfig))
 	case ast.V_OUTPUTMODE:
@@ -778,6 +1022,35 @@ func (p *interp) setSpecial(index int, v value) error {
 			}
 			p.fieldSepRegex = re
 		}
+		// Assigning FS switches back to splitting by FS
+		p.fieldWidthsStr = ""
+		p.fieldWidths = nil
+		p.fieldPat = ""
+		p.fieldPatRegex = nil
+	case ast.V_FIELDWIDTHS:
+		s := p.toString(v)
+		widths, err := parseFieldWidths(s)
+		if err != nil {
+			return err
+		}
+		p.fieldWidthsStr = s
+		p.fieldWidths = widths
+		if widths != nil {
+			p.fieldPat = ""
+			p.fieldPatRegex = nil
+		}
+	case ast.V_FPAT:
+		p.fieldPat = p.toString(v)
+		p.fieldPatRegex = nil // empty FPAT turns off splitting by content
+		if p.fieldPat != "" {
+			re, err := regexp.Compile(compiler.AddRegexFlags(p.fieldPat))
+			if err != nil {
+				return newError("invalid regex %q: %s", p.fieldPat, err)
+			}
+			p.fieldPatRegex = re
+			p.fieldWidthsStr = ""
+			p.fieldWidths = nil
+		}
 	case ast.V_OFMT:
 		p.outputFormat = p.toString(v)
 	case ast.V_OFS:
@@ -804,16 +1077,42 @@ func (p *interp) setSpecial(index int, v value) error {
 		p.recordTerminator = p.toString(v)
 	case ast.V_SUBSEP:
 		p.subscriptSep = p.toString(v)
+	case ast.V_PREC:
+		precision, err := parsePrecision(p.toString(v))
+		if err != nil {
+			return err
+		}
+		p.precision = precision
+		if p.numMode == bigMode {
+			p.setNums()
+		}
+	case ast.V_ROUNDMODE:
+		mode, err := parseRoundMode(p.toString(v))
+		if err != nil {
+			return err
+		}
+		p.roundMode = mode
+		if p.numMode == bigMode {
+			p.setNums()
+		}
 	case ast.V_INPUTMODE:
-		var err error
-		p.inputMode, p.csvInputConfig, err = parseInputMode(p.toString(v))
+		mode, csvConfig, err := parseInputMode(p.toString(v))
 		if err != nil {
 			return err
 		}
-		err = validateCSVInputConfig(p.inputMode, p.csvInputConfig)
+		err = validateCSVInputConfig(mode, csvConfig)
 		if err != nil {
 			return err
 		}
+		if mode != p.inputMode || csvConfig != p.csvInputConfig {
+			// Field names from the old mode's header or JSONL records
+			// don't apply to the new mode
+			p.setFieldNames(nil)
+		}
+		p.inputMode, p.csvInputConfig = mode, csvConfig
+		if p.csvInputConfig.HeaderNames != "" {
+			p.setFieldNames(strings.Split(p.csvInputConfig.HeaderNames, ","))
+		}
 	case ast.V_OUTPUTMODE:
 		var err error
 		p.outputMode, p.csvOutputConfig, err = parseOutputMode(p.toString(v))
@@ -885,21 +1184,44 @@ func (p *interp) getField(index int) value {
 
 // Get the value of a field by name (for CSV/TSV mode), as in @"name".
 func (p *interp) getFieldByName(name string) (value, error) {
+	index, err := p.fieldIndexByName(name)
+	if err != nil {
+		return null(), err
+	}
+	if index == 0 {
+		return str(""), nil
+	}
+	return p.getField(index), nil
+}
+
+// Sets a single field by name, equivalent to "@name = value"
+func (p *interp) setFieldByName(name string, value string) error {
+	index, err := p.fieldIndexByName(name)
+	if err != nil {
+		return err
+	}
+	if index == 0 {
+		return newError("field %q not found in header", name)
+	}
+	return p.setField(index, value)
+}
+
+// Return the index of the named field, or 0 if there's no such field.
+func (p *interp) fieldIndexByName(name string) (int, error) {
 	if p.fieldIndexes == nil {
 		// Lazily create map of field names to indexes.
 		if p.fieldNames == nil {
-			return null(), newError(`@ only supported if header parsing enabled; use -H or add "header" to INPUTMODE`)
+			if p.inputMode == JSONLMode {
+				return 0, newError(`@ not supported before the first JSONL record is read`)
+			}
+			return 0, newError(`@ only supported if header parsing enabled; use -H or add "header" to INPUTMODE`)
 		}
 		p.fieldIndexes = make(map[string]int, len(p.fieldNames))
 		for i, n := range p.fieldNames {
 			p.fieldIndexes[n] = i + 1
 		}
 	}
-	index := p.fieldIndexes[name]
-	if index == 0 {
-		return str(""), nil
-	}
-	return p.getField(index), nil
+	return p.fieldIndexes[name], nil
 }
 
 // Sets a single field, equivalent to "$index = value"
@@ -939,6 +1261,15 @@ func (p *interp) joinFields(fields []string) string {
 		line := p.csvJoinFieldsBuf.Bytes()
 		line = line[:len(line)-lenNewline(line)]
 		return string(line)
+	case JSONLMode:
+		args := make([]value, len(fields))
+		for i, field := range fields {
+			args[i] = numStr(field)
+		}
+		var buf bytes.Buffer
+		_ = p.writeJSONL(&buf, p.outputFieldNames(len(fields)), args)
+		line := buf.Bytes()
+		return string(line[:len(line)-1])
 	default:
 		return strings.Join(fields, p.outputFieldSep)
 	}
@@ -983,6 +1314,8 @@ func inputModeString(mode IOMode, csvConfig CSVInputConfig) string {
 	case TSVMode:
 		s = "tsv"
 		defaultSep = '\t'
+	case JSONLMode:
+		return "jsonl"
 	case DefaultMode:
 		return ""
 	}
@@ -992,7 +1325,9 @@ func inputModeString(mode IOMode, csvConfig CSVInputConfig) string {
 	if csvConfig.Comment != 0 {
 		s += " comment=" + string([]rune{csvConfig.Comment})
 	}
-	if csvConfig.Header {
+	if csvConfig.HeaderNames != "" {
+		s += " header=" + csvConfig.HeaderNames
+	} else if csvConfig.Header {
 		s += " header"
 	}
 	return s
@@ -1010,10 +1345,16 @@ func parseInputMode(s string) (mode IOMode, csvConfig CSVInputConfig, err error)
 	case "tsv":
 		mode = TSVMode
 		csvConfig.Separator = '\t'
+	case "jsonl":
+		mode = JSONLMode
 	default:
 		return DefaultMode, CSVInputConfig{}, newError("invalid input mode %q", fields[0])
 	}
 	for _, field := range fields[1:] {
+		if mode == JSONLMode {
+			// JSONL input mode has no configuration options
+			return DefaultMode, CSVInputConfig{}, newError("invalid input mode key %q", field)
+		}
 		key := field
 		val := ""
 		equals := strings.IndexByte(field, '=')
@@ -1035,10 +1376,18 @@ func parseInputMode(s string) (mode IOMode, csvConfig CSVInputConfig, err error)
 			}
 			csvConfig.Comment = r
 		case "header":
-			if val != "" && val != "true" && val != "false" {
-				return DefaultMode, CSVInputConfig{}, newError("invalid header value %q", val)
+			switch val {
+			case "", "true":
+				csvConfig.Header = true
+			case "false":
+				csvConfig.Header = false
+			default:
+				// Explicit list of field names, for example "header=name,age"
+				if !validHeaderNames(val) {
+					return DefaultMode, CSVInputConfig{}, newError("invalid header value %q", val)
+				}
+				csvConfig.HeaderNames = val
 			}
-			csvConfig.Header = val == "" || val == "true"
 		default:
 			return DefaultMode, CSVInputConfig{}, newError("invalid input mode key %q", key)
 		}
@@ -1056,6 +1405,8 @@ func outputModeString(mode IOMode, csvConfig CSVOutputConfig) string {
 	case TSVMode:
 		s = "tsv"
 		defaultSep = '\t'
+	case JSONLMode:
+		return "jsonl"
 	case DefaultMode:
 		return ""
 	}
@@ -1077,10 +1428,16 @@ func parseOutputMode(s string) (mode IOMode, csvConfig CSVOutputConfig, err erro
 	case "tsv":
 		mode = TSVMode
 		csvConfig.Separator = '\t'
+	case "jsonl":
+		mode = JSONLMode
 	default:
 		return DefaultMode, CSVOutputConfig{}, newError("invalid output mode %q", fields[0])
 	}
 	for _, field := range fields[1:] {
+		if mode == JSONLMode {
+			// JSONL output mode has no configuration options
+			return DefaultMode, CSVOutputConfig{}, newError("invalid output mode key %q", field)
+		}
 		key := field
 		val := ""
 		equals := strings.IndexByte(field, '=')
@@ -13,10 +13,12 @@ import (
 	"os/exec"
 	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/nuvolaris/goawk/interp"
 	"github.com/nuvolaris/goawk/parser"
@@ -325,6 +327,19 @@ BEGIN {
 	{`BEGIN { RS="x"; FS=",.*," } { for (i=1; i<=NF; i++) print $i }`, "one,\n,two", "one\ntwo\n", "", ""},
 	{`BEGIN { FS="x"; RS=",.*," } { print }  # !posix`, "one,\n,two", "one\ntwo\n", "", ""},
 	{`{ print NF }`, "\na\nc d\ne f g", "0\n1\n2\n3\n", "", ""},
+	{`BEGIN { FIELDWIDTHS="3 2 4"; OFS="|" } { print NF, $1, $2, $3 }  # !awk !posix`, "abcdefghi\nabcd\n\nab", "3|abc|de|fghi\n2|abc|d|\n0|||\n1|ab||\n", "", ""},
+	{`BEGIN { FIELDWIDTHS="2 1:3 2:*"; OFS="|" } { print NF, $1, $2, $3 }  # !awk !posix`, "aabcccddeee fff", "3|aa|ccc|eee fff\n", "", ""},
+	{`BEGIN { FIELDWIDTHS="1 2" } { print $2 }  # !awk !posix`, "aéüx", "éü\n", "", ""},
+	{`BEGIN { FIELDWIDTHS="2 2"; print FIELDWIDTHS } { $2 = "X"; print; print NF; FS=","; $0 = "ab,c"; print $1 }  # !awk !posix`, "aabb", "2 2\naa X\n2\nab\n", "", ""},
+	{`BEGIN { FIELDWIDTHS="2 2" } { print $1; FIELDWIDTHS=""; $0 = $0; print $1 }  # !awk !posix`, "aabb cc", "aa\naabb\n", "", ""},
+	{`BEGIN { FIELDWIDTHS="2 2"; FS=","; print "[" FIELDWIDTHS "]" } { print $1 }  # !awk !gawk`, "aa,bb", "[]\naa\n", "", ""},
+	{`BEGIN { FPAT="\"[^\"]*\"|[^,]*"; OFS="|" } { print NF, $1, $2, $3, $4 }  # !awk !posix`, "a,\"b,c\",,d\n,x,\n\n", "4|a|\"b,c\"||d\n3||x||\n0||||\n", "", ""},
+	{`BEGIN { FPAT="[0-9]+"; print FPAT } { $2 = "X"; print; print NF; FS=","; $0 = "1,2"; print NF }  # !awk !posix`, "a1b22c333", "[0-9]+\n1 X 333\n3\n2\n", "", ""},
+	{`BEGIN { FPAT="[a-z]+"; FIELDWIDTHS="1 1" } { print $1; FPAT="[0-9]+"; $0 = $0; print $1; FPAT=""; $0 = $0; print $1 }  # !awk !gawk`, "ab12 cd", "a\n12\nab12\n", "", ""},
+	{`BEGIN { FPAT="[0-9]+"; FS=","; print "[" FPAT "]"; FPAT="[a-z]+"; FIELDWIDTHS="1"; print "[" FPAT "]"; FPAT="[0-9]+"; print "[" FIELDWIDTHS "]" } { print $1 }  # !awk !gawk`, "ab12", "[]\n[]\n[]\n12\n", "", ""},
+	{`BEGIN { FPAT="(" }  # !awk !gawk`, "", "", "invalid regex \"(\": error parsing regexp: missing closing ): `(?s:()`", ""},
+	{`BEGIN { FIELDWIDTHS="2 x" }  # !awk !gawk`, "", "", `invalid FIELDWIDTHS "2 x"`, ""},
+	{`BEGIN { FIELDWIDTHS="* 2" }  # !awk !gawk`, "", "", `invalid FIELDWIDTHS "* 2"`, ""},
 	{`BEGIN { NR = 123; print NR }`, "", "123\n", "", ""},
 	{`{ print NR, $0 }`, "a\nb\nc", "1 a\n2 b\n3 c\n", "", ""},
 	{`
@@ -524,12 +539,80 @@ BEGIN {
 	{`BEGIN { print substr("food", -1) }`, "", "food\n", "", ""},
 	{`BEGIN { print substr("food", 5, 8) }`, "", "\n", "", ""},
 	{`BEGIN { print substr("food", 2, -3), substr("fööd", 2, -3) }`, "", " \n", "", ""},
+	{`BEGIN { s = "foo bar baz"; print gensub(/(b)(a)/, "\\2\\1", "g", s); print s }  # !awk`, "", "foo abr abz\nfoo bar baz\n", "", ""},
+	{`BEGIN { print gensub(/a/, "[&|\\0|\\&|\\\\]", 2, "banana") }  # !awk`, "", "ban[a|a|&|\\]na\n", "", ""},
+	{`BEGIN { print gensub(/a/, "A", "G", "banana"), gensub(/a/, "A", 3, "banana"), gensub(/a/, "A", 4, "banana") }  # !awk`, "", "bAnAnA bananA banana\n", "", ""},
+	{`BEGIN { print gensub(/a/, "A", 0, "banana"), gensub(/a/, "A", "x", "banana") }  # !awk !gawk - gawk warns`, "", "bAnana bAnana\n", "", ""},
+	{`{ print gensub(/([a-z]+)=([0-9]+)/, "\\2:\\1", "g"); print }  # !awk`, "x=1 y=22", "1:x 22:y\nx=1 y=22\n", "", ""},
+	{`BEGIN { print gensub(/(a)|(b)/, "[\\1\\2\\3]", "g", "ab") }  # !awk`, "", "[a][b]\n", "", ""},
+	{`BEGIN { print gensub(/x*/, "-", "g", "abc") }  # !awk`, "", "-a-b-c-\n", "", ""},
+	{`BEGIN { print gensub("(", "x", "g", "a") }  # !awk !gawk`, "", "", "invalid regex \"(\": error parsing regexp: missing closing ): `(?s:()`", ""},
+	{`BEGIN { print match("foo=42;", /([a-z]+)=([0-9]+)/, m), RSTART, RLENGTH; print m[0], m[1], m[2], m[2]+1; print m[1, "start"], m[1, "length"], m[2, "start"], m[2, "length"] }  # !awk`, "",
+		"1 1 6\nfoo=42 foo 42 43\n1 3 5 2\n", "", ""},
+	{`BEGIN { m["x"]=1; print match("abc", /z(q)?/, m), RSTART, RLENGTH; for (k in m) print k }  # !awk`, "", "0 0 -1\n", "", ""},
+	{`BEGIN { match("ac", /a(b)?(c)/, m); print (1 in m), m[2], m[2, "start"]; n = 0; for (k in m) n++; print n }  # !awk`, "", "0 c 2\n6\n", "", ""},
+	{`function f(s, arr) { match(s, /(.)$/, arr); return arr[1] } BEGIN { print f("xyz", a), a[0] }  # !awk`, "", "z z\n", "", ""},
+	{`BEGIN { x = 1; match("a", /a/, x) }  # !awk !gawk`, "", "", "parse error at 1:32: can't use scalar \"x\" as array", ""},
+	{`BEGIN { print strftime("%Y-%m-%d %H:%M:%S %j %a %A %b %B %p %%", 1641092645, 1) }  # !awk`, "", "2022-01-02 03:04:05 002 Sun Sunday Jan January AM %\n", "", ""},
+	{`BEGIN { print strftime("%e|%k|%l|%I|%y|%C|%u|%w|%U|%W|%V|%G|%s", 1672531199, 1) }  # !awk`, "", "31|23|11|11|22|20|6|6|52|52|52|2022|1672531199\n", "", ""},
+	{`BEGIN { print strftime("%D %T %F %R %r %z %Z %q", 0, 1) }  # !awk`, "", "01/01/70 00:00:00 1970-01-01 00:00 12:00:00 AM +0000 UTC %q\n", "", ""},
+	{`BEGIN { print mktime("2022 1 2 3 4 5", 1), mktime("2022 1 32 0 0 0 -1", 1) }  # !awk`, "", "1641092645 1643673600\n", "", ""},
+	{`BEGIN { print mktime("2022 1 2"), mktime("2022 1 2 3 4 x"), mktime("") }  # !awk`, "", "-1 -1 -1\n", "", ""},
+	{`BEGIN { t = systime(); print (t > 1600000000), t == int(t) }  # !awk`, "", "1 1\n", "", ""},
+	{`BEGIN { a["x"]=3; a["y"]=1; a["z"]=2; n = asort(a); for (i=1; i<=n; i++) print i, a[i]; print ("x" in a) }  # !awk`, "", "1 1\n2 2\n3 3\n0\n", "", ""},
+	{`BEGIN { a[1]="b"; a[2]=10; a[3]="a"; a[4]=9; b["old"]=1; n = asort(a, b); print n, b[1], b[2], b[3], b[4], a[1], ("old" in b) }  # !awk`, "", "4 9 10 a b b 0\n", "", ""},
+	{`{ a[NR]=$1 } END { asort(a); print a[1], a[2], a[3], a[4] }  # !awk`, "10\nx\n9\n-1", "-1 9 10 x\n", "", ""},
+	{`BEGIN { a["b"]=1; a["c"]=2; a["a"]=3; n = asorti(a, b); print n, b[1], b[2], b[3], a["a"] }  # !awk`, "", "3 a b c 3\n", "", ""},
+	{`BEGIN { a[10]; a[9]; a[100]; asorti(a); print a[1], a[2], a[3]; asorti(a, b, "@val_num_desc"); print b[1], b[2], b[3] }  # !awk`, "", "10 100 9\n2 1 3\n", "", ""},
+	{`BEGIN { a[1]=5; a[2]=40; a[3]=300; asort(a, b, "@val_str_asc"); print b[1], b[2], b[3]; asort(a, b, "@val_num_desc"); print b[1], b[2], b[3] }  # !awk`, "", "300 40 5\n300 40 5\n", "", ""},
+	{`function cmp(i1, v1, i2, v2) { return length(v1) - length(v2) } BEGIN { a[1]="ccc"; a[2]="a"; a[3]="bb"; print asort(a, b, "cmp"), b[1], b[2], b[3] }  # !awk`, "", "3 a bb ccc\n", "", ""},
+	{`function f(arr,   loc) { loc["q"]=2; loc["p"]=1; asorti(loc, arr); return arr[1] arr[2] } BEGIN { print f(x), x[2] }  # !awk`, "", "pq q\n", "", ""},
+	{`BEGIN { a["b"]=2; a["a"]=3; a["c"]=1; PROCINFO["sorted_in"]="@ind_str_asc"; for (k in a) print k; PROCINFO["sorted_in"]="@val_num_asc"; for (k in a) print k }  # !awk`, "", "a\nb\nc\nc\nb\na\n", "", ""},
+	{`BEGIN { a[10]; a[9]; a[100]; PROCINFO["sorted_in"]="@ind_num_desc"; for (k in a) print k; PROCINFO["sorted_in"]="@ind_num_asc"; for (k in a) { if (k+0 > 9) break; print k } }  # !awk`, "", "100\n10\n9\n9\n", "", ""},
+	{`BEGIN { a["x"]="b"; a["y"]=2; a["z"]="a"; a["w"]=1; PROCINFO["sorted_in"]="@val_type_desc"; for (k in a) printf "%s ", k; print "" }  # !awk`, "", "x z y w \n", "", ""},
+	{`function rev(i1, v1, i2, v2) { return i1 < i2 ? 1 : i1 > i2 ? -1 : 0 } BEGIN { a["a"]; a["c"]; a["b"]; PROCINFO["sorted_in"]="rev"; for (k in a) print k }  # !awk`, "", "c\nb\na\n", "", ""},
+	{`BEGIN { a[1]; PROCINFO["sorted_in"]="@unsorted"; for (k in a) print k; PROCINFO["sorted_in"]=""; for (k in a) print k }  # !awk`, "", "1\n1\n", "", ""},
+	{`BEGIN { a[1]; PROCINFO["sorted_in"]="@ind_str"; for (k in a) print k }  # !awk !gawk`, "", "", `invalid sort order "@ind_str"`, ""},
+	{`BEGIN { a[1]; asort(a, b, "@foo_asc") }  # !awk !gawk`, "", "", `invalid sort order "@foo_asc"`, ""},
+	{`BEGIN { a[1]; asort(a, b, "nosuch") }  # !awk !gawk`, "", "", `sort comparison function "nosuch" not defined`, ""},
+	{`function f(x) { return 0 } BEGIN { a[1]; a[2]; asort(a, b, "f") }  # !awk !gawk`, "", "", `"f" called with more arguments than declared`, ""},
+	{`function f(i1, v1, i2, v2) { v2[1] = 1 } BEGIN { a[1]; a[2]; asort(a, b, "f") }  # !awk !gawk`, "", "", `can't pass scalar as array param "v2" of "f"`, ""},
+	{`BEGIN { x = 1; asort(x) }  # !awk !gawk`, "", "", "parse error at 1:22: can't use scalar \"x\" as array", ""},
+	{`BEGIN { a[1][2]=3; a[1]["x"]="y"; a[2]=5; print a[1][2], a[1]["x"], a[2] }  # !awk`, "", "3 y 5\n", "", ""},
+	{`BEGIN { a["x", 1]["y"][2]="z"; print a["x", 1]["y"][2], (("x", 1) in a), ("y" in a["x", 1]), (2 in a["x", 1]["y"]) }  # !awk`, "", "z 1 1 1\n", "", ""},
+	{`BEGIN { a[1][2]=3; a[1][2]++; a[1][2]+=10; --a[1][2]; print a[1][2] }  # !awk`, "", "13\n", "", ""},
+	{`BEGIN { a[1][2]; a[1][3]; a[2]; delete a[1][2]; print (2 in a[1]), (3 in a[1]); delete a[1]; print (1 in a), (2 in a) }  # !awk`, "", "0 1\n0 1\n", "", ""},
+	{`BEGIN { a["x"]["b"]=2; a["x"]["a"]=1; PROCINFO["sorted_in"]="@ind_str_asc"; for (k in a["x"]) print k, a["x"][k] }  # !awk`, "", "a 1\nb 2\n", "", ""},
+	{`BEGIN { x = a[1]; a[1][2] = "ok"; print a[1][2] }  # !awk`, "", "ok\n", "", ""},
+	{`BEGIN { a[1][2]=1; a[2]=2; print isarray(a), isarray(a[1]), isarray(a[2]), isarray(a[3]), isarray(a[1][2]), isarray(x), isarray(1) }  # !awk`, "", "1 1 0 0 0 0 0\n", "", ""},
+	{`function f(arr, k) { arr["new"]=1; for (k in arr) n++; return n } BEGIN { a[1]["x"]; print f(a[1]), ("new" in a[1]) }  # !awk`, "", "2 1\n", "", ""},
+	{`function walk(arr, indent,   k) { for (k in arr) { if (isarray(arr[k])) { print indent k; walk(arr[k], indent "  ") } else print indent k "=" arr[k] } }
+	  BEGIN { t["a"]["b"]["c"]=1; t["a"]["d"]=2; t["e"]=3; PROCINFO["sorted_in"]="@ind_str_asc"; walk(t, "") }  # !awk`, "", "a\n  b\n    c=1\n  d=2\ne=3\n", "", ""},
+	{`function f(arr) { split("x y z", arr) } BEGIN { f(a[1]); print a[1][1], a[1][3] }  # !awk`, "", "x z\n", "", ""},
+	{`BEGIN { "echo hi" | getline a[1][2]; s[1]["x"]="foo"; sub(/o+/, "u", s[1]["x"]); print a[1][2], s[1]["x"] }  # !awk`, "", "hi fu\n", "", ""},
+	{`BEGIN { a[3]["x"]; a[1]="b"; a[2]=1; n = asort(a); print n, a[1], a[2], isarray(a[3]) }  # !awk`, "", "3 1 b 1\n", "", ""},
+	{`BEGIN { for (i = 1; i <= 5000; i++) { delete a; a[i][i] = i; b[i % 10][i] = i } for (k in b) for (j in b[k]) n++; print n, a[5000][5000], (4999 in a) }  # !awk`, "", "5000 5000 0\n", "", ""},
+	{`BEGIN { a[1]["x"] = "kept"; asort(a, b); delete a; for (i = 0; i < 5000; i++) c[i][i]; delete c; c[1][1]; print b[1]["x"] }  # !awk`, "", "kept\n", "", ""},
+	{`BEGIN { a[1][2]=3; print a[1] }  # !awk !gawk`, "", "", `can't use array element "1" as scalar`, ""},
+	{`BEGIN { a[1][2]=3; a[1]++ }  # !awk !gawk`, "", "", `can't use array element "1" as scalar`, ""},
+	{`BEGIN { a[1]=3; a[1][2]=4 }  # !awk !gawk`, "", "", `can't use scalar element "1" as array`, ""},
+	{`BEGIN { print and(12, 10), or(12, 10), xor(12, 10), and(15, 7, 6), or(1, 2, 4, 8), xor(1, 3, 7) }  # !awk`, "", "8 14 6 6 15 5\n", "", ""},
+	{`BEGIN { print lshift(1, 10), rshift(1024, 3), rshift(1, 64), lshift(1, 60), compl(0), compl(2^52) }  # !awk`, "", "1024 128 0 1152921504606846976 9007199254740991 4503599627370495\n", "", ""},
+	{`BEGIN { print and(7.9, 3.2), or("12abc", 1), lshift(2, 1.9), compl(0.5) == compl(0) }  # !awk`, "", "3 13 4 1\n", "", ""},
+	{`BEGIN { print and(1, -2) }  # !awk`, "", "", `and: negative value -2 is not allowed`, "is not allowed"},
+	{`BEGIN { print rshift(8, -1) }  # !awk`, "", "", `rshift: negative value -1 is not allowed`, "is not allowed"},
+	{`BEGIN { print compl(-0.5) }  # !awk`, "", "", `compl: negative value -0.5 is not allowed`, "is not allowed"},
 	{`BEGIN { n = split("", a); for (i=1; i<=n; i++) print a[i] }`, "", "", "", ""},
 	{`BEGIN { n = split("", a, "."); for (i=1; i<=n; i++) print a[i] }`, "", "", "", ""},
 	{`BEGIN { n = split("ab c d ", a); for (i=1; i<=n; i++) print a[i] }`, "", "ab\nc\nd\n", "", ""},
 	{`BEGIN { n = split("ab,c,d,", a, ","); for (i=1; i<=n; i++) print a[i] }`, "", "ab\nc\nd\n\n", "", ""},
 	{`BEGIN { n = split("ab,c.d,", a, /[,.]/); for (i=1; i<=n; i++) print a[i] }`, "", "ab\nc\nd\n\n", "", ""},
 	{`BEGIN { n = split("1 2", a); print (n, a[1], a[2], a[1]==1, a[2]==2) }`, "", "2 1 2 1 1\n", "", ""},
+	{`BEGIN { n = split("a,\"b,c\",,d", a, ",", "\"[^\"]*\"|[^,]*"); for (i=1; i<=n; i++) print i, a[i] }  # !awk !gawk`, "", "1 a\n2 \"b,c\"\n3 \n4 d\n", "", ""},
+	{`BEGIN { n = split("k1=v1 k2=v2", a, "x", /[a-z0-9]+=/); print n, a[1], a[2] }  # !awk !gawk`, "", "2 k1= k2=\n", "", ""},
+	{`BEGIN { n = split("a.b", a, ".", ""); print n, a[1], a[2] }  # !awk !gawk`, "", "2 a b\n", "", ""},
+	{`BEGIN { n = split("", a, ",", "[a-z]+"); print n }  # !awk !gawk`, "", "0\n", "", ""},
+	{`BEGIN { split("x", a, ",", "(") }  # !awk !gawk`, "", "", "invalid regex \"(\": error parsing regexp: missing closing ): `(?s:()`", ""},
 	{`BEGIN { x = "1.2.3"; print sub(/\./, ",", x); print x }`, "", "1\n1,2.3\n", "", ""},
 	{`BEGIN { x = "1.2.3"; print sub(/\./, ",\\", x); print x }`, "", "1\n1,\\2.3\n", "", ""},
 	{`{ print sub(/\./, ","); print $0 }`, "1.2.3", "1\n1,2.3\n", "", ""},
@@ -1309,6 +1392,123 @@ BEGIN { x=4; y=5; print foo(x), bar(y) }
 			map[string]interface{}{
 				"foo": func(i int) int { return i },
 			}},
+		{`BEGIN { a["x"]=1; a["y"]="foo"; print count(a), a["new"], length(a["y"]) }`, "", "2 3.5 3\n", "",
+			map[string]interface{}{
+				"count": func(m map[string]interface{}) int {
+					n := len(m)
+					m["new"] = 3.5
+					return n
+				},
+			}},
+		{`BEGIN { a[1]=5; a[2]="x"; upper(a); print a[1], a[2], (a[1] < 10) }`, "", "5 X 1\n", "",
+			map[string]interface{}{
+				"upper": func(m map[string]string) {
+					for k, v := range m {
+						m[k] = strings.ToUpper(v)
+					}
+				},
+			}},
+		{`BEGIN { a["f"] = 0.1 + 0.2; a["s"] = "10"; a["x"]["y"]; n = split("3", a2); print count(a), count(a2), (a["f"] == 0.3), (a["s"] < 9), isarray(a["x"]), (a2[1] < 10) }`, "", "3 1 0 1 1 1\n", "",
+			map[string]interface{}{
+				"count": func(m map[string]interface{}) int { return len(m) },
+			}},
+		{`BEGIN { a["f"] = 0.1 + 0.2; a["s"] = "10"; a["x"]["y"]; a["d"]; set(a); for (k in a) n++; print n, (a["f"] == 0.3), (a["s"] < 9), isarray(a["x"]), a["new"], ("d" in a) }`, "", "4 0 1 1 5 0\n", "",
+			map[string]interface{}{
+				"set": func(m map[string]string) {
+					m["new"] = "5"
+					delete(m, "d")
+				},
+			}},
+		{`function f(arr) { return keys(arr, "-") } BEGIN { a[1]["b"]; a[1]["a"]; print f(a[1]), keys(a[1], ",") }`, "", "a-b a,b\n", "",
+			map[string]interface{}{
+				"keys": func(m map[string]string, sep string) string {
+					keys := make([]string, 0, len(m))
+					for k := range m {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					return strings.Join(keys, sep)
+				},
+			}},
+		{`BEGIN { a["old"]; a = decode("x y"); for (k in a) n++; print n, a["x"], a["y"], ("old" in a); b[1] = decode("z"); print b[1]["z"] }`, "", "2 1 1 0\n1\n", "",
+			map[string]interface{}{
+				"decode": func(s string) map[string]string {
+					m := make(map[string]string)
+					for _, word := range strings.Fields(s) {
+						m[word] = "1"
+					}
+					return m
+				},
+			}},
+		{`BEGIN { v = obj(); print v["n"], v["s"], v["t"], v["list"][2], v["sub"]["k"], isarray(v["list"]), length(v["nil"]) }`, "", "42 str 1 b v 1 0\n", "",
+			map[string]interface{}{
+				"obj": func() (map[string]interface{}, error) {
+					return map[string]interface{}{
+						"n":    42.0,
+						"s":    "str",
+						"t":    true,
+						"list": []interface{}{"a", "b"},
+						"sub":  map[string]interface{}{"k": "v"},
+						"nil":  nil,
+					}, nil
+				},
+			}},
+		{`BEGIN { a = f() }`, "", "", "ERR",
+			map[string]interface{}{
+				"f": func() (map[string]string, error) { return nil, fmt.Errorf("ERR") },
+			}},
+		{`BEGIN { x = 1; f(x) }`, "", "", `parse error at 1:18: can't use scalar "x" as array`,
+			map[string]interface{}{
+				"f": func(m map[string]string) {},
+			}},
+		{`BEGIN { f(1) }`, "", "", `parse error at 1:9: can't pass scalar 1 as array param`,
+			map[string]interface{}{
+				"f": func(m map[string]string) {},
+			}},
+		{`BEGIN { print f() }`, "", "", `parse error at 1:15: can't use array returned by "f" as scalar`,
+			map[string]interface{}{
+				"f": func() map[string]string { return nil },
+			}},
+		{`BEGIN { 9 }`, "", "", `native function "f" param 0 is not int or string`,
+			map[string]interface{}{
+				"f": func(m ...map[string]string) {},
+			}},
+		{`{ print info("x") }`, "a b\nc d", "x 1 a b b\nx 2 c d d\n", "",
+			map[string]interface{}{
+				"info": func(c *interp.CallContext, s string) string {
+					nr, _ := c.Var("NR")
+					return fmt.Sprintf("%s %v %s %s", s, nr, c.Record(), c.Field(2))
+				},
+			}},
+		{`BEGIN { r = set("x", 42); print r, x, OFS; x = 1 }`, "", "ok-42--\n", "",
+			map[string]interface{}{
+				"set": func(c *interp.CallContext, name string, n int) string {
+					_ = c.SetVar(name, strconv.Itoa(n))
+					_ = c.SetVar("OFS", "-")
+					if _, ok := c.Var("nosuch"); ok {
+						return "nosuch exists"
+					}
+					return "ok"
+				},
+			}},
+		{`BEGIN { printf "a"; out("b"); print "c" }`, "", "abc\n", "",
+			map[string]interface{}{
+				"out": func(c *interp.CallContext, s string) {
+					fmt.Fprint(c.Output(), s)
+				},
+			}},
+		{`BEGIN { a[1]; a[2]; print count(a) }`, "", "2\n", "",
+			map[string]interface{}{
+				"count": func(c *interp.CallContext, m map[string]string) int { return len(m) },
+			}},
+		{`BEGIN { f(1, 2) }`, "", "", `parse error at 1:9: "f" called with more arguments than declared`,
+			map[string]interface{}{
+				"f": func(c *interp.CallContext, n int) {},
+			}},
+		{`BEGIN { f() }`, "", "", `native function "f" param 1 is not int or string`,
+			map[string]interface{}{
+				"f": func(n int, c *interp.CallContext) {},
+			}},
 	}
 	for _, test := range tests {
 		testName := test.src
@@ -1321,6 +1521,45 @@ BEGIN { x=4; y=5; print foo(x), bar(y) }
 	}
 }
 
+func TestNativeDifferentSignature(t *testing.T) {
+	tests := []struct {
+		src    string
+		parsed interface{}
+		funcs  interface{}
+		err    bool
+	}{
+		{`BEGIN { f(1) }`, func(s string) {}, func(n int) int { return n }, false},
+		{`BEGIN { a[1]; f(a) }`, func(m map[string]string) {}, func(c *interp.CallContext, m map[string]interface{}) {}, false},
+		{`BEGIN { f(1) }`, func(s string) {}, func(m map[string]string) {}, true},
+		{`BEGIN { a[1]; f(a) }`, func(m map[string]string) {}, func(s string) {}, true},
+		{`BEGIN { f(1, 2) }`, func(s, t string) {}, func(s string) {}, true},
+		{`BEGIN { f(1) }`, func(s ...string) {}, func(s string) {}, true},
+		{`BEGIN { a = f() }`, func() map[string]string { return nil }, func() string { return "" }, true},
+		{`BEGIN { a[1] = f() }`, func() string { return "" }, func() map[string]string { return nil }, true},
+	}
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			prog, err := parser.ParseProgram([]byte(test.src), &parser.ParserConfig{
+				Funcs: map[string]interface{}{"f": test.parsed},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = interp.ExecProgram(prog, &interp.Config{
+				Output: ioutil.Discard,
+				Funcs:  map[string]interface{}{"f": test.funcs},
+			})
+			expected := `native function "f" not in Config.Funcs or parsed with different Funcs`
+			if test.err && (err == nil || err.Error() != expected) {
+				t.Fatalf("expected error %q, got %v", expected, err)
+			}
+			if !test.err && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
 func TestSafeMode(t *testing.T) {
 	tests := []struct {
 		src  string
@@ -1354,6 +1593,263 @@ func TestSafeMode(t *testing.T) {
 	}
 }
 
+func TestIntegerMode(t *testing.T) {
+	tests := []struct {
+		src string
+		in  string
+		out string
+		err string
+	}{
+		{`{ s += $1 } END { print s, s - 1, s * 2, s % 10 }`, "9007199254740993\n9007199254740993\n", "18014398509481986 18014398509481985 36028797018963972 6\n", ""},
+		{`{ print ($1 < $2), ($1 == $2), ($1 > $2), ($1 >= $2), ($1 <= $2), ($1 != $2) }`, "9007199254740992 9007199254740993\n", "1 0 0 0 1 1\n", ""},
+		{`{ if ($1 < $2) print "less"; if ($1 != $2) print "ne" }`, "9007199254740992 9007199254740993\n", "less\nne\n", ""},
+		{`{ x = $1; x++; y = $1; y += 2; a[1] = $1; a[1]--; print x, y, a[1], -$1, +$1 }`, "9007199254740993\n", "9007199254740994 9007199254740995 9007199254740992 -9007199254740993 9007199254740993\n", ""},
+		{`{ printf "%d %5d|%*d|%u %s\n", $1, 3, 3, 4, $1, $1 + 0; x = $1 + 0; print x "" }`, "9007199254740993\n", "9007199254740993     3|  4|9007199254740993 9007199254740993\n9007199254740993\n", ""},
+		{`BEGIN { print 7 / 2, 6 / 2, "9007199254740994" / 2, 2 ^ 62, 3 ^ 40, (-3) ^ 3, 2 ^ -1, 0 ^ 0 }`, "", "3.5 3 4503599627370497 4611686018427387904 1.21577e+19 -27 0.5 1\n", ""},
+		{`BEGIN { m = "9223372036854775807"; print m + 0, m + 1, m * 2, -m - 2, "-9223372036854775808" / -1 }`, "", "9223372036854775807 9.22337e+18 1.84467e+19 -9223372036854775808 9.22337e+18\n", ""},
+		{`BEGIN { print 1.5 + 1, "2.5x" * 2, "12abc" + 1, "0x10" + 1, 1e3 + 1, int("9007199254740993"), int("-9007199254740993"), int(-2.5) }`, "", "2.5 5 13 17 1001 9007199254740993 -9007199254740993 -2\n", ""},
+		{`BEGIN { print -7 % 3, 7 % -3, "-9223372036854775808" % -1 }`, "", "-1 1 0\n", ""},
+		{`{ print and($1, 1), or($1, 0), rshift($1, 1), compl(0) }`, "18014398509481987\n", "1 18014398509481987 9007199254740993 9007199254740991\n", ""},
+		{`{ print and($1, 1) }`, "-18014398509481987\n", "", "and: negative value -18014398509481987 is not allowed"},
+		{`BEGIN { x = 1; x /= 0 }`, "", "", "division by zero"},
+		{`BEGIN { print 1 % 0 }`, "", "", "division by zero in mod"},
+		{`BEGIN { print 9007199254740993, 9007199254740993 - 1, (9007199254740993 == 9007199254740992), 1e3 }`, "", "9007199254740993 9007199254740992 0 1000\n", ""},
+	}
+	for _, test := range tests {
+		testName := test.src
+		if len(testName) > 70 {
+			testName = testName[:70]
+		}
+		t.Run(testName, func(t *testing.T) {
+			testGoAWK(t, test.src, test.in, test.out, test.err, nil, func(config *interp.Config) {
+				config.IntegerMode = true
+			})
+		})
+	}
+}
+
+func TestPrecisionMode(t *testing.T) {
+	tests := []struct {
+		src string
+		in  string
+		out string
+		err string
+	}{
+		{`{ s += $1 } END { print s; printf "%.2f %d\n", s, s }`, "0.10\n0.20\n1234567890123456789.70\n", "1234567890123456768\n1234567890123456768.00 1234567890123456768\n", ""},
+		{`BEGIN { PREC = 100 } { s += $1 } END { printf "%.2f\n", s }`, "0.10\n0.20\n1234567890123456789.70\n", "1234567890123456790.00\n", ""},
+		{`BEGIN { print 2^100, 2^100 + 1, 2^100 * 3 - 1, 2^100 / 2^98, 2^100 % 7, -(2^64), +"123456789012345678901234567890" }`, "", "1267650600228229401496703205376 1267650600228229401496703205377 3802951800684688204490109616127 4 2 -18446744073709551616 123456789012345678901234567890\n", ""},
+		{`BEGIN { print 7 / 2, 1 / 3, -7 % 3, 5.5 % 2, 2 ^ -2, (-2) ^ 3, int(-2.5), int("12345678901234567890123") }`, "", "3.5 0.333333 -1 1.5 0.25 -8 -2 12345678901234567890123\n", ""},
+		{`BEGIN { PREC = 200; CONVFMT = "%.30g"; x = 1 / 3; print x ""; printf "%.40f\n", 1 / 3 }`, "", "0.333333333333333333333333333333\n0.3333333333333333333333333333333333333333\n", ""},
+		{`BEGIN { printf "%.20f\n", 0.1; PREC = "quad"; printf "%d %.20f\n", PREC, 0.1 }`, "", "0.10000000000000000555\n113 0.10000000000000000000\n", ""},
+		{`BEGIN { ROUNDMODE = "Z"; PREC = 4; x = 2 / 3; ROUNDMODE = "U"; y = 2 / 3; print ROUNDMODE, x, y }`, "", "U 0.625 0.6875\n", ""},
+		{`{ print ($1 < $2), ($1 == $2), ($1 > $2), ($1 != $2) }`, "100000000000000000000000001 100000000000000000000000000\n", "0 0 1 1\n", ""},
+		{`BEGIN { x = "99999999999999999999"; x++; y = 3; y ^= 50; a[2^70] = 1; for (k in a) print x, y, k }`, "", "100000000000000000000 717897987691852588770249 1180591620717411303424\n", ""},
+		{`BEGIN { printf "%d %5.2f %e %x %c|%*d|\n", "123456789012345678901234567890", 3.14159, 2^70, 255, 65, 3, 7 }`, "", "123456789012345678901234567890  3.14 1.180592e+21 ff A|  7|\n", ""},
+		{`BEGIN { print "inf" + 1, -"nan", log(0), sqrt(2) }`, "", "inf nan -inf 1.41421\n", ""},
+		{`BEGIN { print 9007199254740993, 123456789012345678901234567890, 123456789012345678901234567890 % 1000, 2^53 + 1 == 9007199254740993 }`, "", "9007199254740993 123456789012345678901234567890 890 1\n", ""},
+		{`BEGIN { PREC = 100; x = 0.1; printf "%.25f\n", x * 3 }`, "", "0.3000000000000000000000000\n", ""},
+		{`BEGIN { PREC = 100 } { print and($1, 1), xor($1, 2), lshift($1, 1) }`, "18014398509481987\n", "1 18014398509481985 36028797018963974\n", ""},
+		{`BEGIN { PREC = 0 }`, "", "", `invalid PREC "0": must be a number of bits or a format name like "quad"`},
+		{`BEGIN { ROUNDMODE = "X" }`, "", "", `invalid ROUNDMODE "X": must be N, Z, U, D, or A`},
+		{`BEGIN { print 1 / 0 }`, "", "", "division by zero"},
+	}
+	for _, test := range tests {
+		testName := test.src
+		if len(testName) > 70 {
+			testName = testName[:70]
+		}
+		t.Run(testName, func(t *testing.T) {
+			testGoAWK(t, test.src, test.in, test.out, test.err, nil, func(config *interp.Config) {
+				config.Precision = 53
+			})
+		})
+	}
+}
+
+func TestParallel(t *testing.T) {
+	var sb strings.Builder
+	for i := 1; i <= 100000; i++ {
+		fmt.Fprintf(&sb, "%d k%d %d\n", i, i%7, i%3)
+	}
+	input := sb.String()
+
+	// These should give the same output as when run serially.
+	tests := []string{
+		`{ n++; sum += $1; count[$2]++ } END { print n, sum, NR, FNR, $0; PROCINFO["sorted_in"] = "@ind_str_asc"; for (k in count) print k, count[k] }`,
+		`$3 == 1 && $2 == "k3" { print NR, FNR, $1 }`,
+		`BEGIN { x = 10; a["init"] = 5; s = "x" } { x++; a["init"]++; a[$2][$3] += $1; if ($1 % 1000 == 0) s = s "y"; delete a["k0"] } END { print x, a["init"], a["k1"][2], length(s), ("k0" in a) }`,
+		`{ if ($1 % 10000 == 0) seen[$1] } END { for (k in seen) n++; print n }`,
+		`NR == 50000 { print "exiting"; exit } END { print NR, $0 }`,
+		`BEGIN { split("5 x", init); n = init[1] } { n++; total += $3 } END { print n, total }`,
+		`function add(arr, k, n) { arr[k] += n } { add(sums, $2, $1); add(counts, $2, 1) } END { print sums["k3"], counts["k3"] }`,
+		`$1 > max { max = $1 } END { print max }`,
+		`{ last = $0; seen[$3] = 1 } END { print last, seen[0] + seen[1] + seen[2] }`,
+		`{ split($0, parts); sum += parts[1] } END { print sum }`,
+		`!seen[$3]++`,
+		`{ n++; if (n == 100000) print }`,
+		`NR == 30000, NR == 90000 { n++ } END { print n }`,
+		`{ getline line < "/dev/null"; n++ } END { print n, line }`,
+		`function out() { print > "/dev/null" } { out(); n++ } END { print n }`,
+	}
+	for _, src := range tests {
+		t.Run(src, func(t *testing.T) {
+			prog, err := parser.ParseProgram([]byte(src), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var serial bytes.Buffer
+			_, err = interp.ExecProgram(prog, &interp.Config{
+				Stdin:  strings.NewReader(input),
+				Output: &serial,
+				Error:  &serial,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			testGoAWK(t, src, input, normalizeNewlines(serial.String()), "", nil, func(config *interp.Config) {
+				config.Parallel = 4
+			})
+		})
+	}
+
+	errorTests := []struct {
+		src string
+		err string
+	}{
+		{`NR == 100 { NF = -1 }`, "NF set to negative value: -1"},
+	}
+	for _, test := range errorTests {
+		t.Run(test.src, func(t *testing.T) {
+			testGoAWK(t, test.src, input, "", test.err, nil, func(config *interp.Config) {
+				config.Parallel = 4
+			})
+		})
+	}
+
+	t.Run("ParallelMerge", func(t *testing.T) {
+		src := `{ if ($1 > max) max = $1; last = $2; n++ } END { print max, last, n }`
+		testGoAWK(t, src, input, "100000 k1 100000\n", "", nil, func(config *interp.Config) {
+			config.Parallel = 4
+			config.ParallelMerge = func(name string, initial interface{}, values []interface{}) (interface{}, bool) {
+				switch name {
+				case "max":
+					max := 0.0
+					for _, v := range values {
+						if f, err := strconv.ParseFloat(fmt.Sprint(v), 64); err == nil && f > max {
+							max = f
+						}
+					}
+					return max, true
+				case "last":
+					return "k1", true
+				default:
+					return nil, false
+				}
+			}
+		})
+	})
+
+	t.Run("ParallelMergeDefault", func(t *testing.T) {
+		declineMerge := func(config *interp.Config) {
+			config.Parallel = 4
+			config.ParallelMerge = func(name string, initial interface{}, values []interface{}) (interface{}, bool) {
+				return nil, false
+			}
+		}
+		testGoAWK(t, `{ found = 1; n++ } END { print found, n }`, input, "1 100000\n", "", nil, declineMerge)
+		testGoAWK(t, `{ last = $2 } END { print last }`, input, "", `can't merge values of "last" from parallel workers`, nil, declineMerge)
+	})
+}
+
+// Tests of the compiler's optimizations (constant folding, dead code
+// elimination, jump threading, and superinstructions).
+var optimizerTests = []interpTest{
+	{`BEGIN { print 1+2*3, 10/4, 10/5, 7%3, -7%3, -(3), +"3x", !0, !"", !"0", 2^10 }`, "", "7 2.5 2 1 -1 -3 3 1 1 0 1024\n", "", ""},
+	{`BEGIN { printf "%.1f %.1f %.1f %.1f\n", -4%2, 0*-1, 0/-5, -0 }`, "", "-0.0 -0.0 -0.0 -0.0\n", "", ""},
+	{`BEGIN { print 9007199254740992+0, 9007199254740992*2, 4503599627370496*-3 }`, "", "9007199254740992 18014398509481984 -13510798882111488\n", "", ""},
+	{`BEGIN { x = "a" "b" 1 2; print x, ("a" < "b"), (10 < 9), ("10" < "9"), (1 == 1.0), ("x" == "x"), (2 == "2") }`, "", "ab12 1 0 1 1 1 1\n", "", ""},
+	{`BEGIN { CONVFMT = "%.2f"; x = 3.14159 ""; y = x "a" "b" (1 2); print x, y, (0.5 == "0.50") }`, "", "3.14 3.14ab12 1\n", "", ""},
+	{`BEGIN { if (0) print "no"; else print "yes"; if ("") print "no"; if ("0") print "str"
+	         while (0) print "never"; do print "once"; while (0); for (i = 5; 0; i++) print "never"; print i }`, "", "yes\nstr\nonce\n5\n", "", ""},
+	{`BEGIN { while (1) if (++n > 3) break; for (; 1; ) if (++m >= 2) break
+	         do { k++; if (k == 5) break; continue } while (1); print n, m, k }`, "", "4 2 5\n", "", ""},
+	{`BEGIN { print (1 ? "a" : "b"), (0 ? "a" : "b"), ("" ? x : y "c"), 1 && 0, 1 || x++, 0 && x++, x+0, (1 && x), (0 || "s") }`, "", "a b c 0 1 0 0 0 1\n", "", ""},
+	{`$1 == "b" { print "eq", $2 }  "c" != $1 { n++ }  { print ($2 == "2.0"), ($2 == 2) }  END { print n }`, "a 1\nb 2.0\nc 3\n", "0 0\neq 2.0\n1 1\n0 0\n2\n", "", ""},
+	{`{ if ($2 == "2") print "two"; if ("3" != $2) m++; while ($1 != "y") $1 = "y"; print }  END { print m }`, "a 1\nb 2\n", "y 1\ntwo\ny 2\n2\n", "", ""},
+	{`BEGIN { for (i = 0; i < 6; i++) { if (i % 2) { if (i % 3) a = a "x"; else a = a "y" } else { if (i > 2) continue; a = a "z" } }; print a }`, "", "zxzyx\n", "", ""},
+	{`BEGIN { a[1]; a[2]; if (x + 1) { for (k in a) { if (k) { n++; continue } else print "no" } } else print "else"; print n }`, "", "2\n", "", ""},
+	{`1; 0 { print "never" }  "" { print "never" }  "0" { print "s" }  1+1 { print "two" }`, "a\n", "a\ns\ntwo\n", "", ""},
+	{`BEGIN { a[1+1] = "x"; a[1, 2-1]; print a[2], ((2) in a), ((1, 1) in a), a["2"] }  { print $(1+1) }`, "a b\n", "x 1 1 x\nb\n", "", ""},
+	{`BEGIN { if (0) print 1/0; print "ok"; print 1/0 }`, "", "ok\n", "division by zero", "division by zero"},
+	{`BEGIN { print 5%0 }`, "", "", "division by zero in mod", "division by zero"},
+}
+
+func TestOptimizer(t *testing.T) {
+	for _, test := range optimizerTests {
+		testName := test.src
+		if len(testName) > 70 {
+			testName = testName[:70]
+		}
+		t.Run(testName, func(t *testing.T) {
+			testGoAWK(t, test.src, test.in, test.out, test.err, nil, nil)
+		})
+	}
+
+	// Differential test: the interpreter tests must give the same result
+	// when compiled without optimizations, in each number mode (only the
+	// tests above in arbitrary-precision mode, as some of the others
+	// calculate huge integers exactly in that mode).
+	modes := []struct {
+		name      string
+		configure func(config *interp.Config)
+		all       bool
+	}{
+		{"float", func(config *interp.Config) {}, true},
+		{"int", func(config *interp.Config) { config.IntegerMode = true }, true},
+		{"big", func(config *interp.Config) { config.Precision = 53 }, false},
+	}
+	run := func(t *testing.T, src, in string, noOptimize bool, configure func(config *interp.Config)) string {
+		prog, err := parser.ParseProgram([]byte(src), &parser.ParserConfig{NoOptimize: noOptimize})
+		if err != nil {
+			return "parse error: " + err.Error()
+		}
+		var out bytes.Buffer
+		config := &interp.Config{
+			Stdin:  strings.NewReader(in),
+			Output: &out,
+			Error:  &out,
+		}
+		configure(config)
+		status, err := interp.ExecProgram(prog, config)
+		return fmt.Sprintf("%s%d %v", out.String(), status, err)
+	}
+	for i, test := range append(optimizerTests, interpTests...) {
+		// Skip tests whose output depends on the time or ordering of
+		// output with subprocesses
+		if strings.Contains(test.src, "srand") || strings.Contains(test.src, "systime") ||
+			strings.Contains(test.src, "system") || strings.Contains(test.src, "|") {
+			continue
+		}
+		testName := test.src
+		if len(testName) > 70 {
+			testName = testName[:70]
+		}
+		for _, mode := range modes {
+			if !mode.all && i >= len(optimizerTests) {
+				continue
+			}
+			t.Run(mode.name+"_"+testName, func(t *testing.T) {
+				expected := run(t, test.src, test.in, true, mode.configure)
+				got := run(t, test.src, test.in, false, mode.configure)
+				if got != expected {
+					t.Fatalf("unoptimized/optimized:\n%q\n%q", expected, got)
+				}
+			})
+		}
+	}
+	_ = os.Remove("out")
+}
+
 func TestConfigVarsCorrect(t *testing.T) {
 	prog, err := parser.ParseProgram([]byte(`BEGIN { print x }`), nil)
 	if err != nil {
@@ -1530,6 +2026,22 @@ BEGIN {
 	})
 }
 
+func TestNow(t *testing.T) {
+	loc := time.FixedZone("XST", 2*60*60)
+	now := func() time.Time {
+		return time.Date(2022, 1, 2, 3, 4, 5, 0, loc)
+	}
+	src := `BEGIN {
+	t = systime()
+	print t, strftime("%H:%M %Z"), strftime("%H:%M", t, 1)
+	print mktime("2022 1 2 3 4 5"), mktime("2022 1 2 3 4 5", 1)
+}`
+	expected := "1641085445 03:04 XST 01:04\n1641085445 1641092645\n"
+	testGoAWK(t, src, "", expected, "", nil, func(config *interp.Config) {
+		config.Now = now
+	})
+}
+
 func TestExit(t *testing.T) {
 	tests := []struct {
 		src    string
@@ -1669,6 +2181,34 @@ var csvTests = []csvTest{
 	{`BEGIN { INPUTMODE="csv header" } NR==1 { for (i=1; i in FIELDS; i++) print i, FIELDS[i] }`, "name,email,age\na,b,c", "1 name\n2 email\n3 age\n", "", nil},
 	{`BEGIN { INPUTMODE="csv" } NR==1 { for (i=1; i in FIELDS; i++) print FIELDS[i] }`, "name,email,age\na,b,c", "", "", nil},
 
+	// Explicit header field names
+	{`BEGIN { INPUTMODE="csv header=name,age" } { print NR, @"age", @"name" }`, "Bob,42\nJane,37", "1 42 Bob\n2 37 Jane\n", "", nil},
+	{`BEGIN { INPUTMODE="csv header=name,age"; for (i=1; i in FIELDS; i++) print i, FIELDS[i] }`, "", "1 name\n2 age\n", "", nil},
+	{`BEGIN { INPUTMODE="tsv header=x" } { @"x" = @"x" 1; print }`, "a\nb", "a1\nb1\n", "", nil},
+	{`BEGIN { INPUTMODE="csv header=a,b"; print INPUTMODE }`, "", "csv header=a,b\n", "", nil},
+	{`BEGIN { INPUTMODE="csv header=a,b"; INPUTMODE="csv"; print (1 in FIELDS) } { print @"a" }`, "x,y", "0\n", `@ only supported if header parsing enabled; use -H or add "header" to INPUTMODE`, nil},
+	{`BEGIN { INPUTMODE="tsv" } { print @"a" }`, "x,y", "", `@ only supported if header parsing enabled; use -H or add "header" to INPUTMODE`, func(config *interp.Config) {
+		config.InputMode = interp.CSVMode
+		config.CSVInput.HeaderNames = "a,b"
+	}},
+	{`BEGIN { INPUTMODE="csv header=a,b" } { print @"a"; INPUTMODE="csv header=a,b"; print @"b" }`, "x,y", "x\ny\n", "", nil},
+	{`{ print @"age", @"name" }`, "Bob,42", "42 Bob\n", "", func(config *interp.Config) {
+		config.InputMode = interp.CSVMode
+		config.CSVInput.HeaderNames = "name,age"
+	}},
+	{`{ print @"age", @"name" }`, "Bob,42", "42 Bob\n", "", func(config *interp.Config) {
+		config.InputMode = interp.CSVMode
+		config.CSVInput.Header = true
+		config.CSVInput.HeaderNames = "name,age"
+	}},
+	{`{ print @"age" }`, "Bob,42", "", "input mode configuration not valid in default input mode", func(config *interp.Config) {
+		config.CSVInput.HeaderNames = "name,age"
+	}},
+	{`{ print @"age" }`, "Bob,42", "", `invalid CSV/TSV header names "name,"`, func(config *interp.Config) {
+		config.InputMode = interp.CSVMode
+		config.CSVInput.HeaderNames = "name,"
+	}},
+
 	// Parsing and formatting of INPUTMODE and OUTPUTMODE special variables
 	{`BEGIN { INPUTMODE="csv separator=,"; print INPUTMODE }`, "", "csv\n", "", nil},
 	{`BEGIN { INPUTMODE="csv header=true comment=# separator=|"; print INPUTMODE }`, "", "csv separator=| comment=# header\n", "", nil},
@@ -1682,17 +2222,90 @@ var csvTests = []csvTest{
 	{`BEGIN { INPUTMODE="xyz" }`, "", "", `invalid input mode "xyz"`, nil},
 	{`BEGIN { INPUTMODE="csv separator=foo" }`, "", "", `invalid CSV/TSV separator "foo"`, nil},
 	{`BEGIN { INPUTMODE="csv comment=bar" }`, "", "", `invalid CSV/TSV comment character "bar"`, nil},
-	{`BEGIN { INPUTMODE="csv header=x" }`, "", "", `invalid header value "x"`, nil},
+	{`BEGIN { INPUTMODE="csv header=a,,b" }`, "", "", `invalid header value "a,,b"`, nil},
 	{`BEGIN { INPUTMODE="csv foo=bar" }`, "", "", `invalid input mode key "foo"`, nil},
 	{`BEGIN { OUTPUTMODE="xyz" }`, "", "", `invalid output mode "xyz"`, nil},
 	{`BEGIN { OUTPUTMODE="csv separator=foo" }`, "", "", `invalid CSV/TSV separator "foo"`, nil},
 	{`BEGIN { OUTPUTMODE="csv foo=bar" }`, "", "", `invalid output mode key "foo"`, nil},
 
-	// Other errors
-	{`BEGIN { @"x" = "y" }`, "", "", "parse error at 1:14: assigning @ expression not supported", nil},
-	{`BEGIN { x="a"; @x = "y" }`, "", "", "parse error at 1:19: assigning @ expression not supported", nil},
-	{`BEGIN { @"x" += "y" }`, "", "", "parse error at 1:14: assigning @ expression not supported", nil},
-	{`BEGIN { x="a"; @x += "y" }`, "", "", "parse error at 1:19: assigning @ expression not supported", nil},
+	// printrow() function
+	{`BEGIN { OUTPUTMODE="csv"; a["name"]="Bob"; a["age"]=42; OFIELDS[1]="name"; OFIELDS[2]="age"; printrow(a) }`, "", "Bob,42\n", "", nil},
+	{`BEGIN { OUTPUTMODE="csv"; a["name"]="Bob, Jr"; a["age"]=42; f[1]="age"; f[2]="x"; f[3]="name"; n = printrow(a, f); print n }`, "", "42,,\"Bob, Jr\"\n3\n", "", nil},
+	{`BEGIN { OUTPUTMODE="tsv"; a["b"]=2; a["a"]=1; a["c"]=3; printrow(a) }`, "", "1\t2\t3\n", "", nil},
+	{`BEGIN { OUTPUTMODE="csv"; a[10]="x"; a[2]="y"; a[1]="z"; printrow(a) }`, "", "z,y,x\n", "", nil},
+	{`BEGIN { OUTPUTMODE="csv"; printrow(a) }`, "", "\n", "", nil},
+	{`BEGIN { OFS="-"; a["name"]="Bob"; a["age"]=42; printrow(a) }`, "", "42-Bob\n", "", nil},
+	{`function p(r, f) { printrow(r, f) } BEGIN { OUTPUTMODE="csv"; a["x"]=1; a["y"]=2; f[1]="y"; f[2]="x"; p(a, f) }`, "", "2,1\n", "", nil},
+	{`BEGIN { INPUTMODE="csv header"; OUTPUTMODE="csv" } { for (i=1; i in FIELDS; i++) { OFIELDS[i]=FIELDS[i]; r[FIELDS[i]]=$i } r["age"]++; printrow(r) }`, "name,age\nBob,42", "Bob,43\n", "", nil},
+	{`BEGIN { x = 1; printrow(x) }`, "", "", "parse error at 1:25: can't use scalar \"x\" as array", nil},
+	{`BEGIN { printrow("x") }`, "", "", "parse error at 1:18: expected name instead of string", nil},
+
+	// Named field assignment
+	{`BEGIN { INPUTMODE="csv header"; OUTPUTMODE="csv" } { @"age" = @"age" + 1; print }`, "name,age\nBob,42\nJane,37", "Bob,43\nJane,38\n", "", nil},
+	{`BEGIN { INPUTMODE="csv header"; OFS="|" } { x="name"; @x = toupper(@x); print; print NF }`, "name,age\nBob,42", "BOB|42\n2\n", "", nil},
+	{`BEGIN { INPUTMODE="csv header" } { @"age" += 10; @"age" *= 2; print $2 }`, "name,age\nBob,42", "104\n", "", nil},
+	{`BEGIN { INPUTMODE="csv header" } { f="age"; @f -= 2; print $2 }`, "name,age\nBob,42", "40\n", "", nil},
+	{`BEGIN { INPUTMODE="csv header" } { @"age"++; ++@"age"; @"age"--; print $2 }`, "name,age\nBob,42", "43\n", "", nil},
+	{`BEGIN { INPUTMODE="csv header" } { print @"age"++, ++@"age", (@"age" = 7), (@"age" += 1), $2 }`, "name,age\nBob,42", "42 44 7 8 8\n", "", nil},
+	{`BEGIN { INPUTMODE="csv header" } { sub(/o/, "0", @"name"); print $1 }`, "name,age\nBob,42", "B0b\n", "", nil},
+	{`BEGIN { INPUTMODE="csv header" } NR==1 { ret = getline @"name"; print ret, NR, $1 }`, "name,age\nBob,42\nJane,37", "1 2 Jane,37\n", "", nil},
+	{`BEGIN { INPUTMODE="csv header" } { ret = getline @"name" < "/no/such/file"; print ret, $1 }`, "name,age\nBob,42", "-1 Bob\n", "", nil},
+	{`{ getline @"name" }`, "a\nb", "", `@ only supported if header parsing enabled; use -H or add "header" to INPUTMODE`, nil},
+	{`BEGIN { INPUTMODE="csv header" } { @"x" = "y" }`, "name,age\nBob,42", "", `field "x" not found in header`, nil},
+	{`BEGIN { INPUTMODE="csv header" } { @"x"++ }`, "name,age\nBob,42", "", `field "x" not found in header`, nil},
+	{`BEGIN { @"x" = "y" }`, "", "", `@ only supported if header parsing enabled; use -H or add "header" to INPUTMODE`, nil},
+	{`BEGIN { x="a"; @x += "y" }`, "", "", `@ only supported if header parsing enabled; use -H or add "header" to INPUTMODE`, nil},
+
+	// JSON Lines input mode
+	{`BEGIN { INPUTMODE="jsonl" } { print NF, $1, $2, @"age", @"name" }`, "{\"name\": \"Bob\", \"age\": 42}\n{\"name\": \"Jane\", \"age\": 37}\n", "2 Bob 42 42 Bob\n2 Jane 37 37 Jane\n", "", nil},
+	{`BEGIN { INPUTMODE="jsonl" } { print @"user.id", @"user.name", @"tags.2", @"ok", "[" @"x" "]", NF }`, `{"user": {"id": 1, "name": "Bob"}, "tags": ["a", "b"], "ok": true, "x": null}`, "1 Bob b true [] 6\n", "", nil},
+	{`BEGIN { INPUTMODE="jsonl" } { for (i=1; i in FIELDS; i++) printf "%s=%s ", FIELDS[i], $i; print "" }`, "{\"a\":1,\"b\":{\"c\":2}}\n\n  \n{\"x\":\"y\"}\r\n", "a=1 b.c=2 \nx=y \n", "", nil},
+	{`BEGIN { INPUTMODE="jsonl" } { for (i=1; i<=NF; i++) printf "%s=[%s] ", FIELDS[i], $i; print NF }`, `{"a":{},"b":[],"c":{"d":[]},"e":[{}],"f":1}` + "\n{}", "a=[] b=[] c.d=[] e.1=[] f=[1] 5\n0\n", "", nil},
+	{`BEGIN { INPUTMODE="jsonl" } { print }`, "{\"a\": 1}\n{\"b\": 2}", "{\"a\": 1}\n{\"b\": 2}\n", "", nil},
+	{`BEGIN { INPUTMODE="jsonl" } $2 > 10 { n++ } END { print n }`, "{\"n\":\"a\",\"v\":9}\n{\"n\":\"b\",\"v\":10.5}\n{\"n\":\"c\",\"v\":\"11\"}", "2\n", "", nil},
+	{`BEGIN { INPUTMODE="jsonl" } { @"a" = @"a" * 2; print }`, "{\"a\":1,\"b\":2}", "2 2\n", "", nil},
+	{`BEGIN { INPUTMODE="jsonl" } { $0 = "{\"z\":\"new\"}"; print NF, @"z", FIELDS[1] }`, "{\"a\":1,\"b\":2}", "1 new z\n", "", nil},
+	{`BEGIN { INPUTMODE="jsonl"; print INPUTMODE }`, "", "jsonl\n", "", nil},
+	{`{ print @"name" }`, "{\"name\":\"Bob\"}", "Bob\n", "", func(config *interp.Config) {
+		config.InputMode = interp.JSONLMode
+	}},
+	{`{ print }`, "", "", "input mode configuration not valid in JSONL input mode", func(config *interp.Config) {
+		config.InputMode = interp.JSONLMode
+		config.CSVInput.Header = true
+	}},
+	{`BEGIN { INPUTMODE="jsonl header" }`, "", "", `invalid input mode key "header"`, nil},
+	{`BEGIN { INPUTMODE="jsonl"; print @"x" }`, "", "", `@ not supported before the first JSONL record is read`, nil},
+	{`BEGIN { INPUTMODE="jsonl" } { print @"a" }`, "{\"a\":1}\n[1, 2]\n", "", `error reading from input: invalid JSON on line 2: expected object`, nil},
+	{`BEGIN { INPUTMODE="jsonl" } { print @"a" }`, "{\"a\":1} x\n", "", `error reading from input: invalid JSON on line 1: unexpected data after object`, nil},
+	{`BEGIN { INPUTMODE="jsonl" } { print @"a" }`, "{\"a\":\n", "", `error reading from input: invalid JSON on line 1: unexpected EOF`, nil},
+
+	// JSON Lines output mode
+	{`BEGIN { OUTPUTMODE="jsonl"; print "Bob", 42, 1.5, "x\"y\n", "" }`, "", `{"1":"Bob","2":42,"3":1.5,"4":"x\"y\n","5":""}` + "\n", "", nil},
+	{`BEGIN { OUTPUTMODE="jsonl"; OFIELDS[1]="name"; OFIELDS[2]="age"; print "Bob", 42, "extra" }`, "", `{"name":"Bob","age":42,"3":"extra"}` + "\n", "", nil},
+	{`BEGIN { INPUTMODE="csv header"; OUTPUTMODE="jsonl" } { print $1, $2, $3 }`, "name,age,id\nBob,42,0x1\nJane,3.50,abc", `{"name":"Bob","age":42,"id":"0x1"}` + "\n" + `{"name":"Jane","age":3.50,"id":"abc"}` + "\n", "", nil},
+	{`BEGIN { INPUTMODE="csv"; OUTPUTMODE="jsonl" } { print $1, $2, $3, $4, $5, $6 }`, "3.14159265,12345678901234567,007,-0.5e-3,+1, 42 ", `{"1":3.14159265,"2":12345678901234567,"3":"007","4":-0.5e-3,"5":"+1","6":42}` + "\n", "", nil},
+	{`BEGIN { OUTPUTMODE="jsonl"; print 3.14159265, 1234567.5, 2^53, 1e30, -0.1, 1/3 }`, "", `{"1":3.14159265,"2":1234567.5,"3":9007199254740992,"4":1e+30,"5":-0.1,"6":0.3333333333333333}` + "\n", "", nil},
+	{`BEGIN { OUTPUTMODE="jsonl"; OFMT="%.2f"; print 3.14159265 }`, "", `{"1":3.14159265}` + "\n", "", nil},
+	{`BEGIN { OUTPUTMODE="jsonl"; print 2^62 + 1, 0.000001 / 10 }`, "", `{"1":4611686018427387905,"2":1e-07}` + "\n", "", func(config *interp.Config) {
+		config.IntegerMode = true
+	}},
+	{`BEGIN { OUTPUTMODE="jsonl"; print 2^100, 1/4 }`, "", `{"1":1267650600228229401496703205376,"2":0.25}` + "\n", "", func(config *interp.Config) {
+		config.Precision = 53
+	}},
+	{`BEGIN { INPUTMODE="csv header"; OUTPUTMODE="jsonl" } { print }`, "name,age\nBob,42", "Bob,42\n", "", nil},
+	{`BEGIN { INPUTMODE="csv header"; OUTPUTMODE="jsonl" } { $2 = $2 + 1; print }`, "name,age\nBob,42", `{"name":"Bob","age":43}` + "\n", "", nil},
+	{`BEGIN { INPUTMODE=OUTPUTMODE="jsonl" } { @"user.id"++; print }`, `{"user":{"id":1},"ok":"yes"}`, `{"user.id":2,"ok":"yes"}` + "\n", "", nil},
+	{`BEGIN { OUTPUTMODE="jsonl"; print x, "1e3", 1e3, log(-1), "\x01\t<é>" }`, "", `{"1":"","2":"1e3","3":1000,"4":"nan","5":"\u0001\t<é>"}` + "\n", "", nil},
+	{`BEGIN { OUTPUTMODE="jsonl"; a["name"]="Bob"; a["age"]=42; printrow(a); f[1]="name"; f[2]="x"; printrow(a, f) }`, "", `{"age":42,"name":"Bob"}` + "\n" + `{"name":"Bob","x":""}` + "\n", "", nil},
+	{`BEGIN { OUTPUTMODE="jsonl"; printf "%s", OUTPUTMODE }`, "", "jsonl", "", nil},
+	{`{ print $1 }`, "a", `{"1":"a"}` + "\n", "", func(config *interp.Config) {
+		config.OutputMode = interp.JSONLMode
+	}},
+	{`{ print $1 }`, "", "", "output mode configuration not valid in JSONL output mode", func(config *interp.Config) {
+		config.OutputMode = interp.JSONLMode
+		config.CSVOutput.Separator = ','
+	}},
+	{`BEGIN { OUTPUTMODE="jsonl separator=," }`, "", "", `invalid output mode key "separator=,"`, nil},
 }
 
 func TestCSV(t *testing.T) {
@@ -0,0 +1,136 @@
+// Arithmetic for integer mode (Config.IntegerMode).
+
+package interp
+
+import (
+	"math"
+)
+
+// In integer mode, arithmetic on two integers (including integral numeric
+// strings) produces an exact int64 result. If either operand isn't an
+// integer, or the result would overflow, these fall back to the normal
+// float64 arithmetic.
+
+func intAdd(l, r value) value {
+	a, aOk := l.int()
+	b, bOk := r.int()
+	if aOk && bOk {
+		c := a + b
+		if (c > a) == (b > 0) {
+			return integer(c)
+		}
+	}
+	return num(l.num() + r.num())
+}
+
+func intSub(l, r value) value {
+	a, aOk := l.int()
+	b, bOk := r.int()
+	if aOk && bOk {
+		c := a - b
+		if (c < a) == (b > 0) {
+			return integer(c)
+		}
+	}
+	return num(l.num() - r.num())
+}
+
+func intMul(l, r value) value {
+	a, aOk := l.int()
+	b, bOk := r.int()
+	if aOk && bOk {
+		if a == 0 || b == 0 {
+			return integer(0)
+		}
+		c := a * b
+		if c/b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64) {
+			return integer(c)
+		}
+	}
+	return num(l.num() * r.num())
+}
+
+// Division stays exact only if r divides l evenly, otherwise the result is
+// a float. The caller must check for division by zero.
+func intDiv(l, r value) value {
+	a, aOk := l.int()
+	b, bOk := r.int()
+	if aOk && bOk && b != 0 && a%b == 0 && !(a == math.MinInt64 && b == -1) {
+		return integer(a / b)
+	}
+	return num(l.num() / r.num())
+}
+
+// Like math.Mod, the result has the sign of l. The caller must check for
+// division by zero.
+func intMod(l, r value) value {
+	a, aOk := l.int()
+	b, bOk := r.int()
+	if aOk && bOk && b != 0 {
+		if b == -1 {
+			return integer(0) // avoid overflow of MinInt64 % -1
+		}
+		return integer(a % b)
+	}
+	return num(math.Mod(l.num(), r.num()))
+}
+
+// Raise l to the power r, exactly if r is a non-negative integer.
+func intPow(l, r value) value {
+	a, aOk := l.int()
+	b, bOk := r.int()
+	if aOk && bOk && b >= 0 {
+		switch {
+		case b == 0:
+			return integer(1)
+		case a == 0 || a == 1:
+			return integer(a)
+		case a == -1:
+			return integer(1 - 2*(b%2))
+		case b < 64: // any larger power of 2 or more overflows anyway
+			result := int64(1)
+			for ; b > 0; b-- {
+				c := result * a
+				if c/a != result {
+					return num(math.Pow(l.num(), r.num()))
+				}
+				result = c
+			}
+			return integer(result)
+		}
+	}
+	return num(math.Pow(l.num(), r.num()))
+}
+
+func intNeg(v value) value {
+	i, ok := v.int()
+	if ok && i != math.MinInt64 {
+		return integer(-i)
+	}
+	return num(-v.num())
+}
+
+// Convert v to a number, keeping it exact if it's an integer.
+func intNum(v value) value {
+	i, ok := v.int()
+	if ok {
+		return integer(i)
+	}
+	return num(v.num())
+}
+
+// Compare l and r exactly, returning -1, 0, or 1. This is used for numbers
+// whose float64 values are equal, to tell apart large integers that round to
+// the same float64 (it returns 0 if either isn't an integer).
+func compareInts(l, r value) int {
+	a, aOk := l.int()
+	b, bOk := r.int()
+	switch {
+	case !aOk || !bOk || a == b:
+		return 0
+	case a < b:
+		return -1
+	default:
+		return 1
+	}
+}
@@ -6,13 +6,17 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
 	"os/exec"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode/utf8"
@@ -42,6 +46,8 @@ func (p *interp) printArgs(writer io.Writer, args []value) error {
 		if err != nil {
 			return err
 		}
+	case JSONLMode:
+		return p.writeJSONL(writer, p.outputFieldNames(len(args)), args)
 	default:
 		// Print OFS-separated args followed by ORS (usually newline).
 		for i, arg := range args {
@@ -64,6 +70,192 @@ func (p *interp) printArgs(writer io.Writer, args []value) error {
 	return nil
 }
 
+// Print the values in array as a single row (for the "printrow" function),
+// in the order given by field names fields[1], fields[2], and so on. If fields
+// is empty, order by the array's keys: numerically if they're all integers,
+// otherwise as strings. Return the number of values printed.
+func (p *interp) printrow(array, fields map[string]value) (int, error) {
+	var args []value
+	var names []string
+	if len(fields) > 0 {
+		for i := 1; ; i++ {
+			name, ok := fields[strconv.Itoa(i)]
+			if !ok {
+				break
+			}
+			names = append(names, p.toString(name))
+			args = append(args, array[names[i-1]])
+		}
+	} else {
+		keys := make([]string, 0, len(array))
+		numeric := true
+		for k := range array {
+			keys = append(keys, k)
+			if _, err := strconv.Atoi(k); err != nil {
+				numeric = false
+			}
+		}
+		if numeric {
+			sort.Slice(keys, func(i, j int) bool {
+				m, _ := strconv.Atoi(keys[i])
+				n, _ := strconv.Atoi(keys[j])
+				return m < n
+			})
+		} else {
+			sort.Strings(keys)
+		}
+		args = make([]value, len(keys))
+		for i, k := range keys {
+			args[i] = array[k]
+		}
+		names = keys
+	}
+	if p.outputMode == JSONLMode {
+		// Use the field names as the JSON object's keys.
+		return len(args), p.writeJSONL(p.output, names, args)
+	}
+	return len(args), p.printArgs(p.output, args)
+}
+
+// Return the JSON object keys used for n output fields in JSONL output mode:
+// from OFIELDS if it's not empty, otherwise from FIELDS. If there's no name
+// for a field, its key is its field number.
+func (p *interp) outputFieldNames(n int) []string {
+	names := p.array(ast.ScopeGlobal, p.program.Arrays["OFIELDS"])
+	if len(names) == 0 {
+		names = p.array(ast.ScopeGlobal, p.program.Arrays["FIELDS"])
+	}
+	keys := make([]string, n)
+	for i := range keys {
+		k := strconv.Itoa(i + 1)
+		if name, ok := names[k]; ok {
+			k = p.toString(name)
+		}
+		keys[i] = k
+	}
+	return keys
+}
+
+// Write a single JSON object with the given keys and values, followed by a
+// newline. Numbers and numeric strings are written as JSON numbers, other
+// values as JSON strings.
+func (p *interp) writeJSONL(writer io.Writer, keys []string, args []value) error {
+	buf := append(p.jsonlBuf[:0], '{')
+	for i, arg := range args {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendJSONString(buf, keys[i])
+		buf = append(buf, ':')
+		buf = p.appendJSONValue(buf, arg)
+	}
+	buf = append(buf, '}', '\n')
+	p.jsonlBuf = buf
+	return writeOutput(writer, string(buf))
+}
+
+// Append v to buf as a JSON number if it's a number, or as a JSON string
+// otherwise. Numbers are written without loss of precision (not using
+// OFMT), and numeric strings are written as their original text if that's
+// a valid JSON number, so that input values are passed through unchanged.
+func (p *interp) appendJSONValue(buf []byte, v value) []byte {
+	switch v.typ {
+	case typeNum:
+		n := v.n
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return appendJSONString(buf, v.str(p.outputFormat))
+		}
+		// Same choice of format as encoding/json.
+		if abs := math.Abs(n); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+			return strconv.AppendFloat(buf, n, 'e', -1, 64)
+		}
+		return strconv.AppendFloat(buf, n, 'f', -1, 64)
+	case typeNumStr:
+		s := strings.TrimSpace(v.s)
+		if isJSONNumber(s) {
+			return append(buf, s...)
+		}
+		return appendJSONString(buf, v.s)
+	case typeInt:
+		return strconv.AppendInt(buf, v.intVal(), 10)
+	case typeBig:
+		if v.bigVal().IsInt() {
+			return v.bigVal().Append(buf, 'f', 0)
+		}
+		return v.bigVal().Append(buf, 'g', -1)
+	default: // typeStr, typeNull
+		return appendJSONString(buf, v.s)
+	}
+}
+
+// Report whether s is a number in JSON syntax, for example "-12.5e3" (but
+// not "007", "+1", ".5", or "0x1").
+func isJSONNumber(s string) bool {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	switch {
+	case i < len(s) && s[i] == '0':
+		i++
+	case i < len(s) && s[i] >= '1' && s[i] <= '9':
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+	default:
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		start := i
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+		if i == start {
+			return false
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		start := i
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+		if i == start {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+// Append s to buf as a quoted JSON string.
+func appendJSONString(buf []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	buf = append(buf, '"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			buf = append(buf, '\\', byte(r))
+		case r == '\n':
+			buf = append(buf, '\\', 'n')
+		case r == '\r':
+			buf = append(buf, '\\', 'r')
+		case r == '\t':
+			buf = append(buf, '\\', 't')
+		case r < 0x20:
+			buf = append(buf, '\\', 'u', '0', '0', hex[r>>4], hex[r&0xF])
+		case r < utf8.RuneSelf:
+			buf = append(buf, byte(r))
+		default:
+			buf = append(buf, string(r)...)
+		}
+	}
+	return append(buf, '"')
+}
+
 func (p *interp) writeCSV(output io.Writer, fields []string) error {
 	// If output is already a *bufio.Writer (the common case), csv.NewWriter
 	// will use it directly. This is not explicitly documented, but
@@ -127,6 +319,9 @@ func (p *interp) getOutputStream(redirect Token, destValue value) (io.Writer, er
 	if w, ok := p.outputStreams[name]; ok {
 		return w, nil
 	}
+	if p.parallelWorker && name != "/dev/stderr" {
+		return nil, newError("output redirection not supported in parallel mode")
+	}
 
 	switch redirect {
 	case GREATER, APPEND:
@@ -264,11 +459,17 @@ func (p *interp) newScanner(input io.Reader, buffer []byte) *bufio.Scanner {
 			separator:     p.csvInputConfig.Separator,
 			sepLen:        utf8.RuneLen(p.csvInputConfig.Separator),
 			comment:       p.csvInputConfig.Comment,
-			header:        p.csvInputConfig.Header,
+			header:        p.csvInputConfig.Header && p.csvInputConfig.HeaderNames == "",
 			fields:        &p.fields,
 			setFieldNames: p.setFieldNames,
 		}
 		scanner.Split(splitter.scan)
+	case p.inputMode == JSONLMode:
+		splitter := jsonlSplitter{
+			fields:        &p.fields,
+			setFieldNames: p.setJSONFieldNames,
+		}
+		scanner.Split(splitter.scan)
 	case p.recordSep == "\n":
 		// Scanner default is to split on newlines
 	case p.recordSep == "":
@@ -288,7 +489,8 @@ func (p *interp) newScanner(input io.Reader, buffer []byte) *bufio.Scanner {
 }
 
 // setFieldNames is called by csvSplitter.scan on the first row (if the
-// "header" option is specified).
+// "header" option is specified), or when the config is set if explicit
+// header names are specified.
 func (p *interp) setFieldNames(names []string) {
 	p.fieldNames = names
 	p.fieldIndexes = nil // clear name-to-index cache
@@ -303,6 +505,25 @@ func (p *interp) setFieldNames(names []string) {
 	}
 }
 
+// setJSONFieldNames is called by jsonlSplitter.scan for each record. Field
+// names are only updated if they differ from the previous record's, to
+// avoid rebuilding FIELDS and the name-to-index cache for every record.
+func (p *interp) setJSONFieldNames(names []string) {
+	if p.fieldNames != nil && len(names) == len(p.fieldNames) {
+		same := true
+		for i, name := range names {
+			if name != p.fieldNames[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return
+		}
+	}
+	p.setFieldNames(names)
+}
+
 // Copied from bufio/scan.go in the stdlib: I guess it's a bit more
 // efficient than bytes.TrimSuffix(data, []byte("\r"))
 func dropCR(data []byte) []byte {
@@ -632,6 +853,131 @@ func nextRune(b []byte) rune {
 	return r
 }
 
+// Splitter that splits records in JSON Lines format: one JSON object per
+// line, blank lines are skipped.
+type jsonlSplitter struct {
+	fields        *[]string
+	setFieldNames func(names []string)
+	lineNum       int
+}
+
+func (s *jsonlSplitter) scan(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	for {
+		if atEOF && len(data) == 0 {
+			// No more data, tell Scanner to stop (after skipping any
+			// blank lines).
+			return advance, nil, nil
+		}
+		var line []byte
+		newline := bytes.IndexByte(data, '\n')
+		switch {
+		case newline >= 0:
+			line = data[:newline]
+			data = data[newline+1:]
+			advance += newline + 1
+		case atEOF:
+			// If at EOF, we have a final record without a newline.
+			line = data
+			data = data[len(data):]
+			advance += len(line)
+		default:
+			// Need more data
+			return advance, nil, nil
+		}
+		s.lineNum++
+		line = dropCR(line)
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		names, fields, err := parseJSONObject(line)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid JSON on line %d: %v", s.lineNum, err)
+		}
+		*s.fields = fields
+		s.setFieldNames(names)
+		return advance, line, nil
+	}
+}
+
+// parseJSONObject parses a single JSON object and flattens it into a list of
+// field names and values in document order. Nested objects use dotted names
+// like "user.id", and array elements are named by their 1-based index, like
+// "tags.1". Strings and numbers are returned as is, booleans as "true" or
+// "false", and null and empty nested objects and arrays as the empty
+// string.
+func parseJSONObject(data []byte) (names, fields []string, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if token != json.Delim('{') {
+		return nil, nil, errors.New("expected object")
+	}
+	names, fields, err = flattenJSON(decoder, "", token, []string{}, []string{})
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = decoder.Token()
+	if err != io.EOF {
+		return nil, nil, errors.New("unexpected data after object")
+	}
+	return names, fields, nil
+}
+
+func flattenJSON(decoder *json.Decoder, name string, token json.Token, names, fields []string) ([]string, []string, error) {
+	prefix := name
+	if prefix != "" {
+		prefix += "."
+	}
+	var err error
+	switch token := token.(type) {
+	case json.Delim:
+		i := 1
+		for ; decoder.More(); i++ {
+			elemName := prefix + strconv.Itoa(i)
+			if token == '{' {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, nil, err
+				}
+				elemName = prefix + key.(string)
+			}
+			elem, err := decoder.Token()
+			if err != nil {
+				return nil, nil, err
+			}
+			names, fields, err = flattenJSON(decoder, elemName, elem, names, fields)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		_, err = decoder.Token() // closing '}' or ']'
+		if err != nil {
+			return nil, nil, err
+		}
+		if i > 1 || name == "" {
+			return names, fields, nil
+		}
+		// An empty nested object or array is a single empty field, so
+		// that it isn't dropped.
+		fields = append(fields, "")
+	case string:
+		fields = append(fields, token)
+	case json.Number:
+		fields = append(fields, token.String())
+	case bool:
+		fields = append(fields, strconv.FormatBool(token))
+	case nil:
+		fields = append(fields, "")
+	}
+	return append(names, name), fields, nil
+}
+
 // Setup for a new input file with given name (empty string if stdin)
 func (p *interp) setFile(filename string) {
 	p.filename = numStr(filename)
@@ -674,6 +1020,23 @@ func (p *interp) ensureFields() {
 		} else {
 			// Normally fields have already been parsed by csvSplitter
 		}
+	case p.inputMode == JSONLMode:
+		if p.reparseCSV {
+			names, fields, err := parseJSONObject([]byte(p.line))
+			if err != nil {
+				names, fields = []string{}, nil
+			}
+			p.fields = fields
+			p.setJSONFieldNames(names)
+		} else {
+			// Normally fields have already been parsed by jsonlSplitter
+		}
+	case p.fieldWidths != nil:
+		// FIELDWIDTHS means split fields by column widths
+		p.fields = splitFieldWidths(p.line, p.fieldWidths)
+	case p.fieldPatRegex != nil:
+		// FPAT means fields are successive matches of the regex
+		p.fields = splitFieldPat(p.line, p.fieldPatRegex)
 	case p.fieldSep == " ":
 		// FS space (default) means split fields on any whitespace
 		p.fields = strings.Fields(p.line)
@@ -690,7 +1053,7 @@ func (p *interp) ensureFields() {
 	// Special case for when RS=="" and FS is single character,
 	// split on newline in addition to FS. See more here:
 	// https://www.gnu.org/software/gawk/manual/html_node/Multiple-Line.html
-	if p.inputMode == DefaultMode && p.recordSep == "" && utf8.RuneCountInString(p.fieldSep) == 1 {
+	if p.inputMode == DefaultMode && p.fieldWidths == nil && p.fieldPatRegex == nil && p.recordSep == "" && utf8.RuneCountInString(p.fieldSep) == 1 {
 		fields := make([]string, 0, len(p.fields))
 		for _, field := range p.fields {
 			lines := strings.Split(field, "\n")
@@ -709,6 +1072,90 @@ func (p *interp) ensureFields() {
 	p.numFields = len(p.fields)
 }
 
+// A single entry in FIELDWIDTHS: skip characters followed by a field of
+// width characters (or the rest of the record if width is -1).
+type fieldWidth struct {
+	skip  int
+	width int
+}
+
+// Parse FIELDWIDTHS, a space-separated list of field widths. Each width may
+// be preceded by "skip:" to skip characters before the field, and the last
+// width may be "*" to mean the rest of the record. An empty string turns off
+// fixed-width splitting (nil is returned).
+func parseFieldWidths(s string) ([]fieldWidth, error) {
+	parts := strings.Fields(s)
+	if len(parts) == 0 {
+		return nil, nil
+	}
+	widths := make([]fieldWidth, len(parts))
+	for i, part := range parts {
+		var w fieldWidth
+		widthStr := part
+		if colon := strings.IndexByte(part, ':'); colon >= 0 {
+			skip, err := strconv.Atoi(part[:colon])
+			if err != nil || skip < 0 {
+				return nil, newError("invalid FIELDWIDTHS %q", s)
+			}
+			w.skip = skip
+			widthStr = part[colon+1:]
+		}
+		if widthStr == "*" && i == len(parts)-1 {
+			w.width = -1
+		} else {
+			width, err := strconv.Atoi(widthStr)
+			if err != nil || width <= 0 {
+				return nil, newError("invalid FIELDWIDTHS %q", s)
+			}
+			w.width = width
+		}
+		widths[i] = w
+	}
+	return widths, nil
+}
+
+// Split line into fields by the given widths (in characters, not bytes).
+// Splitting stops at the end of the line, so NF may be less than the number
+// of widths.
+func splitFieldWidths(line string, widths []fieldWidth) []string {
+	fields := make([]string, 0, len(widths))
+	for _, w := range widths {
+		line = line[runeOffset(line, w.skip):]
+		if line == "" {
+			break
+		}
+		if w.width < 0 {
+			fields = append(fields, line)
+			break
+		}
+		n := runeOffset(line, w.width)
+		fields = append(fields, line[:n])
+		line = line[n:]
+	}
+	return fields
+}
+
+// Split s into fields that are successive non-overlapping matches of re (as
+// for FPAT). An empty match is only a field if it doesn't directly follow
+// the previous match, so "a,,b" with FPAT "[^,]*" gives "a", "", and "b".
+func splitFieldPat(s string, re *regexp.Regexp) []string {
+	if s == "" {
+		return nil
+	}
+	return re.FindAllString(s, -1)
+}
+
+// Return the byte offset of the n'th character in s, or len(s) if s has
+// fewer than n characters.
+func runeOffset(s string, n int) int {
+	offset := 0
+	for i := 0; i < n && offset < len(s); i++ {
+		_, size := utf8.DecodeRuneInString(s[offset:])
+		offset += size
+	}
+	return offset
+}
+
 // Fetch next line (record) of input from current input file, opening
 // next input file if done with previous one
 func (p *interp) nextLine() (string, error) {
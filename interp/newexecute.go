@@ -5,6 +5,7 @@ package interp
 import (
 	"context"
 	"math"
+	"math/big"
 
 	"github.com/nuvolaris/goawk/internal/ast"
 	"github.com/nuvolaris/goawk/parser"
@@ -31,6 +32,19 @@ func New(program *parser.Program) (*Interpreter, error) {
 	return &Interpreter{interp: p}, nil
 }
 
+// Clone returns a new Interpreter for the same program. The clone shares
+// the immutable parts of this interpreter, such as the parsed program,
+// compiled code, regexes, and native function information, but has its
+// own fresh variables and I/O state, as if it had been created with New.
+//
+// An Interpreter must only be used by one goroutine at a time, but clones
+// may be executed concurrently with each other and with the original.
+func (p *Interpreter) Clone() *Interpreter {
+	clone := newInterp(p.interp.program)
+	clone.nativeFuncs = p.interp.nativeFuncs
+	return &Interpreter{interp: clone}
+}
+
 // Execute runs this program with the given execution configuration (input,
 // output, and variables) and returns the exit status code of the program. A
 // nil config is valid and will use the defaults (zero values).
@@ -63,27 +77,152 @@ func (p *Interpreter) Execute(config *Config) (int, error) {
 
 // Array returns a map representing the items in the named AWK array. AWK
 // numbers are included as type float64, strings (including "numeric strings")
-// are included as type string. If the named array does not exist, return nil.
+// are included as type string, and subarrays as map[string]interface{}. If
+// the named array does not exist, return nil.
 func (p *Interpreter) Array(name string) map[string]interface{} {
 	index, exists := p.interp.program.Arrays[name]
 	if !exists {
 		return nil
 	}
+	return p.interp.arrayToMap(p.interp.array(ast.ScopeGlobal, index))
+}
+
+// NumStr is a "numeric string", such as an input field or an element
+// created by split(). It compares as a number if it looks like one, and as
+// a string otherwise. GetVar returns numeric strings as this type, and
+// SetVar and SetArray accept it to create them.
+type NumStr string
+
+// GetVar returns the value of the named global or special variable. Unlike
+// Array, it preserves the distinction between AWK value types: numbers are
+// returned as float64 (int64 in integer mode, or *big.Float in -M mode),
+// strings as string, numeric strings as NumStr, and uninitialized variables
+// as nil. If there's no such scalar variable in the program, GetVar returns
+// false.
+func (p *Interpreter) GetVar(name string) (interface{}, bool) {
+	if index := ast.SpecialVarIndex(name); index > 0 {
+		return p.interp.scalarToInterface(p.interp.getSpecial(index)), true
+	}
+	index, exists := p.interp.program.Scalars[name]
+	if !exists {
+		return nil, false
+	}
+	return p.interp.scalarToInterface(p.interp.globals[index]), true
+}
+
+// SetVar sets the named global or special variable to value, which is
+// typically a type returned by GetVar: nil, float64, string, NumStr, or in
+// the exact number modes, int64 or *big.Float. Other integer types and bool
+// are converted to numbers. The value is kept between calls to Execute
+// (until ResetVars), though Config.Vars are applied on top of it.
+//
+// Variables that aren't used in the program are ignored. An error is
+// returned if name is an array, or if the value isn't valid for a special
+// variable, such as a negative NF.
+func (p *Interpreter) SetVar(name string, value interface{}) error {
+	v := p.interp.interfaceToValue(value)
+	if index := ast.SpecialVarIndex(name); index > 0 {
+		return p.interp.setSpecial(index, v)
+	}
+	if _, isArray := p.interp.program.Arrays[name]; isArray {
+		return newError("can't set array %q with SetVar", name)
+	}
+	if index, exists := p.interp.program.Scalars[name]; exists {
+		p.interp.globals[index] = v
+	}
+	return nil
+}
+
+// SetArray replaces the contents of the named global array with the items
+// in m, whose values are converted as for SetVar. Nested maps and slices
+// become subarrays. Arrays that aren't used in the program are ignored.
+func (p *Interpreter) SetArray(name string, m map[string]interface{}) {
+	index, exists := p.interp.program.Arrays[name]
+	if !exists {
+		return
+	}
+	array := p.interp.array(ast.ScopeGlobal, index)
+	for k := range array {
+		delete(array, k)
+	}
+	for k, v := range m {
+		array[k] = p.interp.interfaceToValue(v)
+	}
+}
+
+// DeleteArray deletes all items in the named global array, like AWK's
+// "delete a". Arrays that aren't used in the program are ignored.
+func (p *Interpreter) DeleteArray(name string) {
+	index, exists := p.interp.program.Arrays[name]
+	if !exists {
+		return
+	}
 	array := p.interp.array(ast.ScopeGlobal, index)
+	for k := range array {
+		delete(array, k)
+	}
+}
+
+// Convert an AWK array to the map form returned by Interpreter.Array.
+func (p *interp) arrayToMap(array map[string]value) map[string]interface{} {
 	result := make(map[string]interface{}, len(array))
 	for k, v := range array {
-		switch v.typ {
-		case typeNum:
-			result[k] = v.n
-		case typeStr, typeNumStr:
-			result[k] = v.s
-		default:
-			result[k] = ""
-		}
+		result[k] = p.valueToInterface(v)
 	}
 	return result
 }
 
+// Convert a value to float64 (numbers) or string (strings and "numeric
+// strings"), or map[string]interface{} for subarrays. Integers in integer
+// mode are returned as int64, and numbers in -M mode as *big.Float.
+func (p *interp) valueToInterface(v value) interface{} {
+	switch v.typ {
+	case typeNum:
+		return v.n
+	case typeInt:
+		return v.intVal()
+	case typeBig:
+		return v.bigVal()
+	case typeStr, typeNumStr:
+		return v.s
+	case typeArray:
+		return p.arrayToMap(p.subarrayMap(v))
+	default:
+		return ""
+	}
+}
+
+// Convert a scalar value to the form returned by GetVar, which (unlike
+// valueToInterface) keeps numeric strings and null values distinct.
+func (p *interp) scalarToInterface(v value) interface{} {
+	switch v.typ {
+	case typeNull:
+		return nil
+	case typeNumStr:
+		return NumStr(v.s)
+	default:
+		return p.valueToInterface(v)
+	}
+}
+
+// Convert a Go value passed to SetVar or SetArray to an AWK value. Exact
+// numbers are kept exact if the interpreter is in the matching number mode.
+func (p *interp) interfaceToValue(v interface{}) value {
+	switch v := v.(type) {
+	case int64:
+		if p.numMode == intMode {
+			return integer(v)
+		}
+	case *big.Float:
+		if p.numMode == bigMode && !v.IsInf() {
+			return bigNum(v)
+		}
+		f, _ := v.Float64()
+		return num(f)
+	}
+	return p.fromInterface(v)
+}
+
 func (p *interp) resetCore() {
 	p.scanner = nil
 	for k := range p.scanners {
@@ -135,12 +274,18 @@ func (p *interp) resetVars() {
 	p.outputFormat = "%.6g"
 	p.fieldSep = " "
 	p.fieldSepRegex = nil
+	p.fieldWidthsStr = ""
+	p.fieldWidths = nil
+	p.fieldPat = ""
+	p.fieldPatRegex = nil
 	p.recordSep = "\n"
 	p.recordSepRegex = nil
 	p.recordTerminator = ""
 	p.outputFieldSep = " "
 	p.outputRecordSep = "\n"
 	p.subscriptSep = "\x1c"
+	p.precision = defaultPrecision
+	p.roundMode = big.ToNearestEven
 	p.matchLength = 0
 	p.matchStart = 0
 }
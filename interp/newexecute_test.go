@@ -6,8 +6,10 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"math"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -138,6 +140,369 @@ function g(arr) { arr["a"]=1.23 }`)
 	}
 }
 
+func TestGetSetVar(t *testing.T) {
+	interpreter := newInterp(t, `
+{ n = $1; s = $1 ""; f = $1 + 0 }
+END { print x, y, z == 10, w == 10, ("k" in a), a["k"] < 9, a["m"]["n"], u == 0 && u == "" }`)
+	var output bytes.Buffer
+	_, err := interpreter.Execute(&interp.Config{
+		Stdin:  strings.NewReader("42\n"),
+		Output: &output,
+	})
+	if err != nil {
+		t.Fatalf("error executing: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		expected interface{}
+		ok       bool
+	}{
+		{"n", interp.NumStr("42"), true},
+		{"s", "42", true},
+		{"f", 42.0, true},
+		{"u", nil, true},
+		{"NR", 1.0, true},
+		{"FS", " ", true},
+		{"a", nil, false},
+		{"missing", nil, false},
+	}
+	for _, test := range tests {
+		v, ok := interpreter.GetVar(test.name)
+		if v != test.expected || ok != test.ok {
+			t.Errorf("GetVar(%q): expected %#v, %v, got %#v, %v", test.name, test.expected, test.ok, v, ok)
+		}
+	}
+
+	// Pass values from the first execution to the second.
+	n, _ := interpreter.GetVar("n")
+	s, _ := interpreter.GetVar("s")
+	for _, kv := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"x", n},
+		{"y", s},
+		{"z", interp.NumStr("10.0")},
+		{"w", "10.0"},
+		{"missing", 1},
+		{"OFS", "-"},
+	} {
+		err := interpreter.SetVar(kv.name, kv.value)
+		if err != nil {
+			t.Fatalf("SetVar(%q): %v", kv.name, err)
+		}
+	}
+	interpreter.SetArray("a", map[string]interface{}{
+		"k": interp.NumStr("10"),
+		"m": map[string]interface{}{"n": true},
+	})
+	interpreter.SetArray("missing", map[string]interface{}{"k": 1})
+	output.Reset()
+	_, err = interpreter.Execute(&interp.Config{Output: &output})
+	if err != nil {
+		t.Fatalf("error executing: %v", err)
+	}
+	expected := "42-42-1-0-1-0-1-1\n"
+	if output.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, output.String())
+	}
+
+	interpreter.DeleteArray("a")
+	if arr := interpreter.Array("a"); len(arr) != 0 {
+		t.Errorf("expected array to be deleted, got %v", arr)
+	}
+	err = interpreter.SetVar("a", 1)
+	if err == nil || err.Error() != `can't set array "a" with SetVar` {
+		t.Errorf("expected array error, got %v", err)
+	}
+	err = interpreter.SetVar("NF", -1)
+	if err == nil || err.Error() != "NF set to negative value: -1" {
+		t.Errorf("expected NF error, got %v", err)
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	interpreter := newInterp(t, `
+BEGIN { print "begin" }
+/start/,/stop/ { print "range", NR }
+{ print NR, NF, $2; n += $1 }
+$1 == "exit" { exit 3 }
+END { print "end", n }`)
+
+	var output bytes.Buffer
+	err := interpreter.Start(&interp.Config{Output: &output})
+	if err != nil {
+		t.Fatalf("error starting: %v", err)
+	}
+	records := []string{"1 start", "2 x y", "3 stop"}
+	for _, record := range records {
+		err := interpreter.Feed(record)
+		if err != nil {
+			t.Fatalf("error feeding %q: %v", record, err)
+		}
+	}
+	err = interpreter.FeedFields([]string{"4", "a b", "c"})
+	if err != nil {
+		t.Fatalf("error feeding fields: %v", err)
+	}
+	err = interpreter.Feed("exit")
+	if err != nil {
+		t.Fatalf("error feeding exit: %v", err)
+	}
+	err = interpreter.Feed("100 ignored")
+	if err != nil {
+		t.Fatalf("error feeding after exit: %v", err)
+	}
+	status, err := interpreter.Finish()
+	if err != nil {
+		t.Fatalf("error finishing: %v", err)
+	}
+	if status != 3 {
+		t.Errorf("expected status 3, got %d", status)
+	}
+	expected := "begin\nrange 1\n1 2 start\nrange 2\n2 3 x\nrange 3\n3 2 stop\n4 3 a b\n5 1 \nend 10\n"
+	if normalizeNewlines(output.String()) != expected {
+		t.Errorf("expected %q, got %q", expected, output.String())
+	}
+
+	// Range state and exit are reset by Start; variables are kept.
+	output.Reset()
+	err = interpreter.Start(&interp.Config{Output: &output, Vars: []string{"FS", ","}})
+	if err != nil {
+		t.Fatalf("error starting: %v", err)
+	}
+	err = interpreter.Feed("5,stop")
+	if err != nil {
+		t.Fatalf("error feeding: %v", err)
+	}
+	_, err = interpreter.Finish()
+	if err != nil {
+		t.Fatalf("error finishing: %v", err)
+	}
+	expected = "begin\n1 2 stop\nend 15\n"
+	if normalizeNewlines(output.String()) != expected {
+		t.Errorf("expected %q, got %q", expected, output.String())
+	}
+
+	err = interpreter.Feed("x")
+	if err == nil || err.Error() != "Feed called without Start" {
+		t.Errorf("expected error feeding without Start, got %v", err)
+	}
+	_, err = interpreter.Finish()
+	if err == nil || err.Error() != "Finish called without Start" {
+		t.Errorf("expected error finishing without Start, got %v", err)
+	}
+}
+
+func TestStreamingError(t *testing.T) {
+	interpreter := newInterp(t, `{ NF = $1 } END { print "end" }`)
+	var output bytes.Buffer
+	err := interpreter.Start(&interp.Config{Output: &output})
+	if err != nil {
+		t.Fatalf("error starting: %v", err)
+	}
+	err = interpreter.Feed("-1")
+	if err == nil || err.Error() != "NF set to negative value: -1" {
+		t.Fatalf("expected NF error, got %v", err)
+	}
+	err = interpreter.FeedFields([]string{"1"})
+	if err == nil || err.Error() != "FeedFields called without Start" {
+		t.Fatalf("expected error after failed Feed, got %v", err)
+	}
+	if output.String() != "" {
+		t.Errorf("expected no output, got %q", output.String())
+	}
+}
+
+func TestClone(t *testing.T) {
+	interpreter := newInterp(t, `{ n += $1; a[$2]++ } END { print n, ("x" in a), rand() < 1; n = 100 }`)
+	var output bytes.Buffer
+	_, err := interpreter.Execute(&interp.Config{
+		Stdin:  strings.NewReader("1 x\n2 y\n"),
+		Output: &output,
+	})
+	if err != nil {
+		t.Fatalf("error executing: %v", err)
+	}
+
+	// Clone must start with fresh state, not the original's variables.
+	clone := interpreter.Clone()
+	for i, interpreter := range []*interp.Interpreter{interpreter, clone} {
+		output.Reset()
+		_, err = interpreter.Execute(&interp.Config{
+			Stdin:  strings.NewReader("3 z\n"),
+			Output: &output,
+		})
+		if err != nil {
+			t.Fatalf("error executing: %v", err)
+		}
+		expected := []string{"103 1 1\n", "3 0 1\n"}[i]
+		if normalizeNewlines(output.String()) != expected {
+			t.Errorf("expected %q, got %q", expected, output.String())
+		}
+	}
+}
+
+func TestPool(t *testing.T) {
+	prog, err := parser.ParseProgram([]byte(`
+BEGIN { srand(); x = rand() }
+{ n += $1; c += !($1 in a); a[$1] }
+END { print id, n, c, x == rand() }`), nil)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	pool, err := interp.NewPool(prog)
+	if err != nil {
+		t.Fatalf("interp.NewPool error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var output bytes.Buffer
+			_, err := pool.Execute(&interp.Config{
+				Stdin:   strings.NewReader(fmt.Sprintf("%d\n%d\n", i, i)),
+				Output:  &output,
+				Vars:    []string{"id", fmt.Sprint(i)},
+				Environ: []string{},
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+			expected := fmt.Sprintf("%d %d 1 0\n", i, 2*i)
+			if normalizeNewlines(output.String()) != expected {
+				errs <- fmt.Errorf("expected %q, got %q", expected, output.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// Interpreters checked out manually are reset when they're put back.
+	interpreter := pool.Get()
+	_, err = interpreter.Execute(&interp.Config{Stdin: strings.NewReader("5\n"), Output: &bytes.Buffer{}})
+	if err != nil {
+		t.Fatalf("error executing: %v", err)
+	}
+	pool.Put(interpreter)
+	interpreter = pool.Get()
+	defer pool.Put(interpreter)
+	if n, _ := interpreter.GetVar("n"); n != nil {
+		t.Errorf("expected n to be reset, got %v", n)
+	}
+}
+
+func TestMarshalProgram(t *testing.T) {
+	src := `
+function double(x) { return x*2 }
+/b+/ { n++; a[$1] = double(NR); sub(/b+/, "X") }
+{ s = s $0 }
+END { for (k in a) t += a[k]; print n, t, s, sum(1, 2), ("c" in a) }
+`
+	funcs := map[string]interface{}{
+		"sum": func(x, y int) int { return x + y },
+	}
+	prog, err := parser.ParseProgram([]byte(src), &parser.ParserConfig{Funcs: funcs})
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	data, err := prog.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	loaded := &parser.Program{}
+	err = loaded.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+
+	var expected, got bytes.Buffer
+	err = prog.Disassemble(&expected)
+	if err != nil {
+		t.Fatalf("error disassembling: %v", err)
+	}
+	err = loaded.Disassemble(&got)
+	if err != nil {
+		t.Fatalf("error disassembling: %v", err)
+	}
+	if got.String() != expected.String() {
+		t.Fatalf("expected disassembly:\n%s\ngot:\n%s", expected.String(), got.String())
+	}
+
+	interpreter, err := interp.New(loaded)
+	if err != nil {
+		t.Fatalf("interp.New error: %v", err)
+	}
+	var output bytes.Buffer
+	_, err = interpreter.Execute(&interp.Config{
+		Stdin:  strings.NewReader("ab\nc\nbbb\n"),
+		Output: &output,
+		Vars:   []string{"t", "10"},
+		Funcs:  funcs,
+	})
+	if err != nil {
+		t.Fatalf("error executing: %v", err)
+	}
+	if normalizeNewlines(output.String()) != "2 18 aXcX 3 0\n" {
+		t.Fatalf("expected %q, got %q", "2 18 aXcX 3 0\n", output.String())
+	}
+	if v, _ := interpreter.GetVar("n"); v != 2.0 {
+		t.Fatalf("expected n to be 2, got %v", v)
+	}
+
+	interpreter, err = interp.New(loaded)
+	if err != nil {
+		t.Fatalf("interp.New error: %v", err)
+	}
+	_, err = interpreter.Execute(&interp.Config{
+		Stdin: strings.NewReader(""),
+		Funcs: map[string]interface{}{"add": func(x, y int) int { return x + y }},
+	})
+	if err == nil || !strings.Contains(err.Error(), `native function "sum"`) {
+		t.Fatalf("expected native function error, got %v", err)
+	}
+
+	interpreter, err = interp.New(loaded)
+	if err != nil {
+		t.Fatalf("interp.New error: %v", err)
+	}
+	_, err = interpreter.Execute(&interp.Config{
+		Stdin: strings.NewReader(""),
+		Funcs: map[string]interface{}{"sum": func(x int, m map[string]string) int { return x + len(m) }},
+	})
+	if err == nil || !strings.Contains(err.Error(), `native function "sum"`) {
+		t.Fatalf("expected native function error, got %v", err)
+	}
+
+	for _, test := range []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"empty", nil, "not a compiled GoAWK program"},
+		{"source", []byte(src), "not a compiled GoAWK program"},
+		{"version", append(data[:15:15], 0xff, 0, 0, 0), "compiled program is from an incompatible version of GoAWK"},
+		{"truncated", data[:len(data)-10], "invalid compiled program: checksum mismatch"},
+		{"corrupt", append(data[:len(data)-1:len(data)-1], data[len(data)-1]^1), "invalid compiled program: checksum mismatch"},
+		{"no checksum", data[:60], "invalid compiled program: unexpected EOF"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := (&parser.Program{}).UnmarshalBinary(test.data)
+			if err == nil || err.Error() != test.err {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
 func TestExecuteContextNoError(t *testing.T) {
 	interpreter := newInterp(t, `BEGIN {}`)
 	_, err := interpreter.ExecuteContext(context.Background(), nil)
@@ -166,6 +531,33 @@ func TestExecuteContextCancel(t *testing.T) {
 	}
 }
 
+func TestExecuteContextNative(t *testing.T) {
+	funcs := map[string]interface{}{
+		"wait": func(c *interp.CallContext) (int, error) {
+			<-c.Context().Done()
+			return 0, c.Context().Err()
+		},
+	}
+	prog, err := parser.ParseProgram([]byte(`BEGIN { wait(); print "not reached" }`), &parser.ParserConfig{Funcs: funcs})
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	interpreter, err := interp.New(prog)
+	if err != nil {
+		t.Fatalf("interp.New error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	var output bytes.Buffer
+	_, err = interpreter.ExecuteContext(ctx, &interp.Config{Output: &output, Funcs: funcs})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded error, got: %v", err)
+	}
+	if output.String() != "" {
+		t.Fatalf("expected no output, got %q", output.String())
+	}
+}
+
 func TestExecuteContextSystemTimeout(t *testing.T) {
 	t.Skip("TODO: skipping for now due to #122")
 	interpreter := newInterp(t, `BEGIN { print system("sleep 4") }`)
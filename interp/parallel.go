@@ -0,0 +1,724 @@
+// Parallel processing of input records (Config.Parallel).
+
+package interp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nuvolaris/goawk/internal/ast"
+	"github.com/nuvolaris/goawk/internal/compiler"
+)
+
+// In parallel mode, BEGIN runs as usual, and then the input is split into
+// chunks that are processed by a number of worker interpreters, each with
+// its own copy of the global variables as they were after BEGIN. When the
+// input is newline-separated records, each input is split into chunks of
+// about parallelChunkSize bytes at newline boundaries; otherwise each input
+// file is a chunk. Output from each chunk is buffered and written in input
+// order. When all chunks have been processed, the workers' variables are
+// merged back into the main interpreter, and END runs as usual.
+
+const parallelChunkSize = 256 * 1024
+
+// A chunk of input to be processed by a worker.
+type parallelJob struct {
+	seq         int    // sequence number, for ordering output
+	filename    string // input filename, or "-" for stdin
+	data        []byte // newline-separated records, or nil to read filename
+	lineNum     int    // NR before the first record
+	fileLineNum int    // FNR before the first record
+	err         error  // error reading the input
+}
+
+// Results from a worker processing a parallelJob.
+type parallelResult struct {
+	seq         int
+	output      []byte
+	records     int
+	filename    value
+	fileLineNum int
+	line        string
+	exitStatus  int
+	err         error // errExit if the program called exit
+}
+
+// Execute pattern-action blocks using p.parallel worker interpreters.
+// Falls back to normal execution if the input can't be split up or the
+// pattern-actions can't run in parallel.
+func (p *interp) execActionsParallel(actions []compiler.Action) error {
+	inputs, ok := p.parallelInputs()
+	lineChunks := p.recordSep == "\n" && (p.inputMode == DefaultMode || p.inputMode == JSONLMode)
+	if !ok || p.debugger != nil || !lineChunks && len(inputs) < 2 || hasRangePattern(actions) ||
+		p.program.Compiled.ActionsUseIO || !p.canMerge() {
+		return p.execActions(actions)
+	}
+
+	errorOutput := &lockedWriter{w: p.errorOutput}
+	workers := make([]*interp, p.parallel)
+	for i := range workers {
+		workers[i] = p.newWorker(errorOutput)
+	}
+
+	// Once a chunk exits or fails, chunks after it in the input aren't
+	// processed, though chunks before it must still finish.
+	stopSeq := int64(math.MaxInt64)
+	stopCh := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func(seq int) {
+		for {
+			old := atomic.LoadInt64(&stopSeq)
+			if int64(seq) >= old || atomic.CompareAndSwapInt64(&stopSeq, old, int64(seq)) {
+				break
+			}
+		}
+		stopOnce.Do(func() { close(stopCh) })
+	}
+	stopped := func(seq int) bool {
+		return int64(seq) > atomic.LoadInt64(&stopSeq)
+	}
+
+	// Read chunks, limiting the number of chunks in memory at once.
+	jobs := make(chan *parallelJob, p.parallel)
+	slots := make(chan struct{}, 2*p.parallel)
+	go func() {
+		defer close(jobs)
+		p.readChunks(inputs, lineChunks, func(job *parallelJob) bool {
+			select {
+			case slots <- struct{}{}:
+			case <-stopCh:
+				return false
+			}
+			select {
+			case jobs <- job:
+				return true
+			case <-stopCh:
+				return false
+			}
+		})
+	}()
+
+	results := make(chan *parallelResult, p.parallel)
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *interp) {
+			defer wg.Done()
+			defer w.closeAll()
+			for {
+				var job *parallelJob
+				select {
+				case job = <-jobs:
+				case <-stopCh:
+				}
+				if job == nil {
+					return
+				}
+				result := w.runParallelJob(job)
+				if result.err != nil {
+					stop(job.seq)
+				}
+				results <- result
+			}
+		}(w)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Write output in input order, and total up NR and friends.
+	var (
+		err      error
+		finished bool
+		last     *parallelResult
+		records  int
+		pending  = make(map[int]*parallelResult)
+		next     = 0
+	)
+	for result := range results {
+		pending[result.seq] = result
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-slots
+			if finished || stopped(r.seq) {
+				continue
+			}
+			_, writeErr := p.output.Write(r.output)
+			if writeErr != nil && r.err == nil {
+				r.err = writeErr
+				stop(r.seq)
+			}
+			records += r.records
+			last = r
+			if r.err != nil {
+				finished = true
+				if r.err == errExit {
+					p.exitStatus = r.exitStatus
+				}
+				err = r.err
+			}
+		}
+	}
+	if err != nil && err != errExit {
+		return err
+	}
+
+	p.lineNum = records
+	if last != nil {
+		p.filename = last.filename
+		p.fileLineNum = last.fileLineNum
+		p.setLine(last.line, false)
+	}
+	mergeErr := p.mergeWorkers(workers)
+	if mergeErr != nil {
+		return mergeErr
+	}
+	return err
+}
+
+// Report whether the workers' variables can be merged. Unless there's a
+// ParallelMerge function, variables the pattern-actions change other than
+// by adding or appending (for example "max = $1" or "last = $0") can't be.
+func (p *interp) canMerge() bool {
+	if p.parallelMerge != nil {
+		return true
+	}
+	compiled := p.program.Compiled
+	for _, change := range compiled.ScalarChanges {
+		if change == compiler.ChangeOther {
+			return false
+		}
+	}
+	for name, index := range p.program.Arrays {
+		if compiled.ArrayChanges[index] == compiler.ChangeOther && !isSpecialArray(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// Report whether any of the actions has a range pattern, as in
+// "NR==10, NR==20". Whether a record is in the range depends on the
+// records before it, which may be in another worker's chunk.
+func hasRangePattern(actions []compiler.Action) bool {
+	for _, action := range actions {
+		if len(action.Pattern) == 2 {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the list of inputs from ARGV (or "-" for stdin), or false if
+// ARGV includes var=value assignments, which must be handled in order.
+func (p *interp) parallelInputs() ([]string, bool) {
+	argvArray := p.array(ast.ScopeGlobal, p.program.Arrays["ARGV"])
+	var inputs []string
+	for i := 1; i < p.argc; i++ {
+		filename := p.toString(argvArray[strconv.Itoa(i)])
+		if filename == "" {
+			continue
+		}
+		if !p.noArgVars && varRegex.MatchString(filename) {
+			return nil, false
+		}
+		inputs = append(inputs, filename)
+	}
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+	return inputs, true
+}
+
+// Split the inputs into jobs and call send for each, stopping if it
+// returns false. If lineChunks is false, each input is a single job.
+func (p *interp) readChunks(inputs []string, lineChunks bool, send func(job *parallelJob) bool) {
+	seq := 0
+	lineNum := 0
+	for _, filename := range inputs {
+		if !lineChunks {
+			if !send(&parallelJob{seq: seq, filename: filename}) {
+				return
+			}
+			seq++
+			continue
+		}
+
+		var input io.Reader
+		if filename == "-" {
+			input = p.stdin
+		} else if p.noFileReads {
+			send(&parallelJob{seq: seq, err: newError("can't read from file due to NoFileReads")})
+			return
+		} else {
+			f, err := os.Open(filename)
+			if err != nil {
+				send(&parallelJob{seq: seq, err: err})
+				return
+			}
+			input = f
+		}
+
+		fileLineNum := 0
+		var leftover []byte
+		for {
+			buf := make([]byte, len(leftover), len(leftover)+parallelChunkSize)
+			copy(buf, leftover)
+			n, err := io.ReadFull(input, buf[len(leftover):cap(buf)])
+			buf = buf[:len(leftover)+n]
+			eof := err == io.EOF || err == io.ErrUnexpectedEOF
+			if err != nil && !eof {
+				send(&parallelJob{seq: seq, err: fmt.Errorf("error reading from input: %s", err)})
+				return
+			}
+
+			// Cut the chunk at the last newline, keeping the rest of
+			// the buffer for the next chunk.
+			chunk := buf
+			leftover = nil
+			if !eof {
+				i := bytes.LastIndexByte(buf, '\n')
+				if i < 0 {
+					leftover = buf // no complete record yet
+					continue
+				}
+				chunk, leftover = buf[:i+1], buf[i+1:]
+			}
+			if len(chunk) > 0 {
+				records := bytes.Count(chunk, []byte{'\n'})
+				if chunk[len(chunk)-1] != '\n' {
+					records++
+				}
+				job := &parallelJob{
+					seq:         seq,
+					filename:    filename,
+					data:        chunk,
+					lineNum:     lineNum,
+					fileLineNum: fileLineNum,
+				}
+				if !send(job) {
+					closeInput(input, p.stdin)
+					return
+				}
+				seq++
+				lineNum += records
+				fileLineNum += records
+			}
+			if eof {
+				break
+			}
+		}
+		closeInput(input, p.stdin)
+	}
+}
+
+// Close input if it's a file we opened (not stdin).
+func closeInput(input, stdin io.Reader) {
+	if c, ok := input.(io.Closer); ok && input != stdin {
+		_ = c.Close()
+	}
+}
+
+// Create a worker interpreter with a copy of p's global variables and
+// settings.
+func (p *interp) newWorker(errorOutput io.Writer) *interp {
+	w := newInterp(p.program)
+	w.nativeFuncs = p.nativeFuncs
+	w.parallelWorker = true
+
+	copy(w.globals, p.globals)
+	for _, index := range p.program.Arrays {
+		array := w.array(ast.ScopeGlobal, index)
+		for k, v := range p.array(ast.ScopeGlobal, index) {
+			array[k] = w.copyValue(p, v)
+		}
+	}
+	for i := ast.V_ILLEGAL + 1; i <= ast.V_LAST; i++ {
+		switch i {
+		case ast.V_NF, ast.V_NR, ast.V_FNR, ast.V_FILENAME, ast.V_RT, ast.V_INPUTMODE, ast.V_OUTPUTMODE:
+			continue
+		}
+		_ = w.setSpecial(i, p.getSpecial(i)) // values are already valid
+	}
+	w.inputMode = p.inputMode
+	w.csvInputConfig = p.csvInputConfig
+	w.outputMode = p.outputMode
+	w.csvOutputConfig = p.csvOutputConfig
+	if p.csvInputConfig.HeaderNames != "" {
+		w.setFieldNames(strings.Split(p.csvInputConfig.HeaderNames, ","))
+	}
+
+	w.numMode = p.numMode
+	w.random.Seed(p.random.Int63())
+	w.noExec = p.noExec
+	w.noFileWrites = p.noFileWrites
+	w.noFileReads = p.noFileReads
+	w.shellCommand = p.shellCommand
+	w.now = p.now
+	w.stdin = p.stdin
+	w.errorOutput = errorOutput
+	w.checkCtx = p.checkCtx
+	w.ctx = p.ctx
+	w.ctxDone = p.ctxDone
+	return w
+}
+
+// Process a single chunk of input in worker p.
+func (p *interp) runParallelJob(job *parallelJob) *parallelResult {
+	result := &parallelResult{seq: job.seq}
+	if job.err != nil {
+		result.err = job.err
+		return result
+	}
+
+	var input io.Reader
+	switch {
+	case job.data != nil:
+		input = bytes.NewReader(job.data)
+	case job.filename == "-":
+		input = p.stdin
+	case p.noFileReads:
+		result.err = newError("can't read from file due to NoFileReads")
+		return result
+	default:
+		f, err := os.Open(job.filename)
+		if err != nil {
+			result.err = err
+			return result
+		}
+		input = f
+	}
+	var output bytes.Buffer
+	p.output = &output
+	p.input = input
+	if p.inputBuffer == nil {
+		p.inputBuffer = make([]byte, inputBufSize)
+	}
+	p.scanner = p.newScanner(input, p.inputBuffer)
+	p.setFile(job.filename)
+	p.filenameIndex = p.argc // no more inputs after this one
+	p.lineNum = job.lineNum
+	p.fileLineNum = job.fileLineNum
+
+	err := p.execActions(p.program.Compiled.Actions)
+	closeInput(input, p.stdin)
+	p.input = nil
+	p.scanner = nil
+
+	result.output = output.Bytes()
+	result.records = p.lineNum - job.lineNum
+	result.filename = p.filename
+	result.fileLineNum = p.fileLineNum
+	result.line = p.line
+	result.exitStatus = p.exitStatus
+	result.err = err
+	return result
+}
+
+// Merge the global variables of the workers back into p, which still has
+// the values they had before the workers started. Special variables and
+// special arrays like ENVIRON aren't merged.
+func (p *interp) mergeWorkers(workers []*interp) error {
+	values := make([]value, len(workers))
+	for name, index := range p.program.Scalars {
+		initial := p.globals[index]
+		for i, w := range workers {
+			values[i] = w.globals[index]
+		}
+		changed := p.changedValues(initial, values)
+		if len(changed) == 0 {
+			continue
+		}
+		if p.parallelMerge != nil {
+			merged, ok := p.parallelMerge(name, p.scalarToInterface(initial), valuesToInterfaces(values, p.scalarToInterface))
+			if ok {
+				p.globals[index] = p.interfaceToValue(merged)
+				continue
+			}
+		}
+		merged, ok := p.mergeValues(p.program.Compiled.ScalarChanges[index], initial, changed)
+		if !ok {
+			return newError("can't merge values of %q from parallel workers", name)
+		}
+		p.globals[index] = merged
+	}
+
+	for name, index := range p.program.Arrays {
+		if isSpecialArray(name) {
+			continue
+		}
+		array := p.array(ast.ScopeGlobal, index)
+		initial := p.subarray(array)
+		for i, w := range workers {
+			// Copy the worker's subarrays into p's subarrays table (the
+			// worker is finished with its array, so it's updated in place)
+			workerArray := w.array(ast.ScopeGlobal, index)
+			for k, v := range workerArray {
+				if v.typ == typeArray {
+					workerArray[k] = p.copyValue(w, v)
+				}
+			}
+			values[i] = p.subarray(workerArray)
+		}
+		changed := p.changedValues(initial, values)
+		if len(changed) == 0 {
+			continue
+		}
+		var merged map[string]value
+		if p.parallelMerge != nil {
+			result, ok := p.parallelMerge(name, p.arrayToMap(array), valuesToInterfaces(values, p.valueToInterface))
+			if ok {
+				m, isMap := result.(map[string]interface{})
+				if !isMap {
+					return newError("ParallelMerge returned %T for array %q, not a map[string]interface{}", result, name)
+				}
+				merged = make(map[string]value, len(m))
+				for k, v := range m {
+					merged[k] = p.interfaceToValue(v)
+				}
+			}
+		}
+		if merged == nil {
+			value, ok := p.mergeValues(p.program.Compiled.ArrayChanges[index], initial, changed)
+			if !ok {
+				return newError("can't merge values of %q from parallel workers", name)
+			}
+			merged = p.subarrayMap(value)
+		}
+		for k := range array {
+			delete(array, k)
+		}
+		for k, v := range merged {
+			array[k] = v
+		}
+	}
+	return nil
+}
+
+// Merge the values in changed, which are different from initial, based
+// on how the program changes the variable. Numbers (including numeric
+// strings) that are only added to are summed (initial plus the sum of the
+// differences from initial), strings that are only appended to are
+// appended (initial plus the part of each after initial), and arrays are
+// merged element by element. Otherwise the changed values must all be the
+// same; if they're not, return false.
+func (p *interp) mergeValues(change compiler.VarChange, initial value, changed []value) (value, bool) {
+	if len(changed) == 1 {
+		return changed[0], true
+	}
+	allNull, allNums, allArrays, anyArray := true, true, true, false
+	for _, v := range changed {
+		allNull = allNull && v.typ == typeNull
+		allNums = allNums && isMergeNumber(v)
+		allArrays = allArrays && v.typ == typeArray
+		anyArray = anyArray || v.typ == typeArray
+	}
+	switch {
+	case allNull:
+		return null(), true
+	case allArrays && (initial.typ == typeArray || initial.typ == typeNull):
+		arrays := make([]map[string]value, len(changed))
+		for i, v := range changed {
+			arrays[i] = p.subarrayMap(v)
+		}
+		var initialArray map[string]value
+		if initial.typ == typeArray {
+			initialArray = p.subarrayMap(initial)
+		}
+		merged, ok := p.mergeArrays(change, initialArray, arrays)
+		return p.subarray(merged), ok
+	case anyArray || initial.typ == typeArray:
+		return value{}, false
+	case change == compiler.ChangeAdd && allNums && (isMergeNumber(initial) || initial.typ == typeNull):
+		if initial.typ == typeNull {
+			initial = num(0)
+		}
+		sum := initial
+		for _, v := range changed {
+			sum = p.mergeAdd(sum, p.mergeSub(v, initial))
+		}
+		return sum, true
+	case change == compiler.ChangeAppend:
+		prefix := p.toString(initial)
+		var sb strings.Builder
+		sb.WriteString(prefix)
+		for _, v := range changed {
+			sb.WriteString(strings.TrimPrefix(p.toString(v), prefix))
+		}
+		return str(sb.String()), true
+	default:
+		for _, v := range changed[1:] {
+			if !p.sameValue(v, changed[0]) {
+				return value{}, false
+			}
+		}
+		return changed[0], true
+	}
+}
+
+// Merge arrays element by element. Elements that were deleted by any of
+// the arrays and not changed by the others are deleted.
+func (p *interp) mergeArrays(change compiler.VarChange, initial map[string]value, arrays []map[string]value) (map[string]value, bool) {
+	result := make(map[string]value, len(initial))
+	seen := make(map[string]bool, len(initial))
+	merge := func(k string) bool {
+		if seen[k] {
+			return true
+		}
+		seen[k] = true
+		initialValue, inInitial := initial[k]
+		deleted := false
+		var changed []value
+		for _, array := range arrays {
+			v, ok := array[k]
+			if !ok {
+				deleted = deleted || inInitial
+				continue
+			}
+			if !inInitial || !p.sameValue(v, initialValue) {
+				changed = append(changed, v)
+			}
+		}
+		switch {
+		case len(changed) > 0:
+			merged, ok := p.mergeValues(change, initialValue, changed)
+			if !ok {
+				return false
+			}
+			result[k] = merged
+		case inInitial && !deleted:
+			result[k] = initialValue
+		}
+		return true
+	}
+	for k := range initial {
+		if !merge(k) {
+			return nil, false
+		}
+	}
+	for _, array := range arrays {
+		for k := range array {
+			if !merge(k) {
+				return nil, false
+			}
+		}
+	}
+	return result, true
+}
+
+func (p *interp) mergeAdd(l, r value) value {
+	if p.numMode != floatMode {
+		return p.exactAdd(l, r)
+	}
+	return num(l.num() + r.num())
+}
+
+func (p *interp) mergeSub(l, r value) value {
+	if p.numMode != floatMode {
+		return p.exactSub(l, r)
+	}
+	return num(l.num() - r.num())
+}
+
+// Return the values that are different from initial.
+func (p *interp) changedValues(initial value, values []value) []value {
+	var changed []value
+	for _, v := range values {
+		if !p.sameValue(v, initial) {
+			changed = append(changed, v)
+		}
+	}
+	return changed
+}
+
+func valuesToInterfaces(values []value, convert func(value) interface{}) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = convert(v)
+	}
+	return result
+}
+
+// Report whether v is a number, or a numeric string that looks like one.
+func isMergeNumber(v value) bool {
+	switch v.typ {
+	case typeNum, typeInt, typeBig:
+		return true
+	case typeNumStr:
+		_, isStr := v.isTrueStr()
+		return !isStr
+	default:
+		return false
+	}
+}
+
+// Report whether a and b have the same type and value. Arrays are
+// compared element by element.
+func (p *interp) sameValue(a, b value) bool {
+	if a.typ != b.typ {
+		return false
+	}
+	switch a.typ {
+	case typeNum:
+		return a.n == b.n || math.IsNaN(a.n) && math.IsNaN(b.n)
+	case typeInt:
+		return a.intVal() == b.intVal()
+	case typeBig:
+		return a.bigVal().Cmp(b.bigVal()) == 0
+	case typeStr, typeNumStr:
+		return a.s == b.s
+	case typeArray:
+		aArray, bArray := p.subarrayMap(a), p.subarrayMap(b)
+		if len(aArray) != len(bArray) {
+			return false
+		}
+		for k, v := range aArray {
+			other, ok := bArray[k]
+			if !ok || !p.sameValue(v, other) {
+				return false
+			}
+		}
+		return true
+	default:
+		return true
+	}
+}
+
+// Return a copy of v, which belongs to interpreter from, copying subarrays
+// recursively into p's subarrays.
+func (p *interp) copyValue(from *interp, v value) value {
+	if v.typ != typeArray {
+		return v
+	}
+	src := from.subarrayMap(v)
+	array := make(map[string]value, len(src))
+	for k, elem := range src {
+		array[k] = p.copyValue(from, elem)
+	}
+	return p.subarray(array)
+}
+
+// A writer that serializes writes from multiple goroutines.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
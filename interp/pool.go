@@ -0,0 +1,72 @@
+// A goroutine-safe pool of interpreters for the same program.
+
+package interp
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nuvolaris/goawk/parser"
+)
+
+// Pool is a pool of Interpreters for a single program, which allows the
+// program to be executed efficiently from many goroutines at once (for
+// example, in HTTP handlers). Unlike an Interpreter, a Pool is safe for
+// concurrent use.
+//
+// Interpreters in the pool are created with Interpreter.Clone, and are
+// reset before being returned to the pool, so each execution starts with
+// fresh variables, I/O state, and random number seed.
+type Pool struct {
+	template *Interpreter
+	pool     sync.Pool
+}
+
+// NewPool creates a pool of interpreters for the given program.
+func NewPool(program *parser.Program) (*Pool, error) {
+	template, err := New(program)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pool{template: template}
+	p.pool.New = func() interface{} {
+		return p.template.Clone()
+	}
+	return p, nil
+}
+
+// Get checks out an Interpreter from the pool, creating a new one if none
+// are available. The caller has exclusive use of it until it's returned
+// with Put.
+func (p *Pool) Get() *Interpreter {
+	return p.pool.Get().(*Interpreter)
+}
+
+// Put resets interpreter's variables, I/O state, and random number seed,
+// and returns it to the pool. The interpreter must have been obtained from
+// Get, and must not be used after calling Put.
+func (p *Pool) Put(interpreter *Interpreter) {
+	interpreter.interp.resetCore()
+	interpreter.interp.resetVars()
+	interpreter.ResetRand()
+	p.pool.Put(interpreter)
+}
+
+// Execute runs the program using an interpreter from the pool, and returns
+// the program's exit status. See Interpreter.Execute for details about
+// config, including the requirement that config.Funcs is the same value
+// provided to parser.ParseProgram.
+func (p *Pool) Execute(config *Config) (int, error) {
+	interpreter := p.Get()
+	defer p.Put(interpreter)
+	return interpreter.Execute(config)
+}
+
+// ExecuteContext is like Execute, but takes a context to allow the caller
+// to set an execution timeout or cancel the execution, as with
+// Interpreter.ExecuteContext.
+func (p *Pool) ExecuteContext(ctx context.Context, config *Config) (int, error) {
+	interpreter := p.Get()
+	defer p.Put(interpreter)
+	return interpreter.ExecuteContext(ctx, config)
+}
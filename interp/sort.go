@@ -0,0 +1,214 @@
+// Array sorting for asort(), asorti(), and PROCINFO["sorted_in"].
+
+package interp
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An array element to be sorted.
+type sortElem struct {
+	index string
+	value value
+}
+
+// Guts of the asort() and asorti() functions: sort the values (or indexes
+// if indices is true) of src according to how, clear dest, and set
+// dest[1..n] to the sorted results. Return the number of elements. Note
+// that src and dest may be the same array.
+func (p *interp) asort(src, dest map[string]value, how string, indices bool) (int, error) {
+	elems, err := p.sortArray(src, how)
+	if err != nil {
+		return 0, err
+	}
+	for k := range dest {
+		delete(dest, k)
+	}
+	for i, elem := range elems {
+		v := elem.value
+		if indices {
+			v = str(elem.index)
+		}
+		dest[strconv.Itoa(i+1)] = v
+	}
+	return len(elems), nil
+}
+
+// Return the value of PROCINFO["sorted_in"], or "" if for-in loops should
+// iterate in the usual (unspecified) order.
+func (p *interp) sortedIn() string {
+	procinfo := p.arrays[p.program.Arrays["PROCINFO"]]
+	v, ok := procinfo["sorted_in"]
+	if !ok {
+		return ""
+	}
+	how := p.toString(v)
+	if how == "@unsorted" {
+		return ""
+	}
+	return how
+}
+
+// Return the elements of array sorted according to how, which is either one
+// of gawk's predefined orders like "@ind_str_asc" or "@val_num_desc", or the
+// name of a user-defined function cmp(i1, v1, i2, v2) that returns a number
+// less than, equal to, or greater than zero.
+func (p *interp) sortArray(array map[string]value, how string) ([]sortElem, error) {
+	elems := make([]sortElem, 0, len(array))
+	for index, v := range array {
+		elems = append(elems, sortElem{index, v})
+	}
+	if how == "@unsorted" {
+		return elems, nil
+	}
+
+	if strings.HasPrefix(how, "@") {
+		compare, err := p.sortOrder(how)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(elems, func(i, j int) bool {
+			return compare(elems[i], elems[j]) < 0
+		})
+		return elems, nil
+	}
+
+	funcIndex := -1
+	for i, f := range p.program.Compiled.Functions {
+		if f.Name == how {
+			funcIndex = i
+			break
+		}
+	}
+	if funcIndex < 0 {
+		return nil, newError("sort comparison function %q not defined", how)
+	}
+	// Start in index order so the result is deterministic even when the
+	// comparison function says elements are equal.
+	sort.Slice(elems, func(i, j int) bool {
+		return elems[i].index < elems[j].index
+	})
+	var err error
+	sort.SliceStable(elems, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		if elems[i].value.typ == typeArray || elems[j].value.typ == typeArray {
+			err = newError("can't pass subarray to sort comparison function %q", how)
+			return false
+		}
+		var r value
+		r, err = p.callUserFunc(funcIndex, []value{
+			str(elems[i].index), elems[i].value, str(elems[j].index), elems[j].value,
+		})
+		return r.num() < 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	return elems, nil
+}
+
+// Return the comparison function for a predefined sort order like
+// "@ind_num_asc". Ties are broken by comparing indexes as strings.
+func (p *interp) sortOrder(how string) (func(a, b sortElem) int, error) {
+	var compare func(a, b sortElem) int
+	name := strings.TrimSuffix(strings.TrimSuffix(how, "_asc"), "_desc")
+	switch name {
+	case "@ind_str":
+		compare = func(a, b sortElem) int {
+			return strings.Compare(a.index, b.index)
+		}
+	case "@ind_num":
+		compare = func(a, b sortElem) int {
+			return compareNums(parseFloatPrefix(a.index), parseFloatPrefix(b.index))
+		}
+	case "@val_str":
+		compare = func(a, b sortElem) int {
+			return strings.Compare(p.toString(a.value), p.toString(b.value))
+		}
+	case "@val_num":
+		compare = func(a, b sortElem) int {
+			return compareNums(a.value.num(), b.value.num())
+		}
+	case "@val_type":
+		// Numbers (including numeric strings) sort before strings
+		compare = func(a, b sortElem) int {
+			an, aIsStr := a.value.isTrueStr()
+			bn, bIsStr := b.value.isTrueStr()
+			switch {
+			case aIsStr && bIsStr:
+				return strings.Compare(a.value.s, b.value.s)
+			case aIsStr:
+				return 1
+			case bIsStr:
+				return -1
+			default:
+				return compareNums(an, bn)
+			}
+		}
+	default:
+		return nil, newError("invalid sort order %q", how)
+	}
+
+	descending := strings.HasSuffix(how, "_desc")
+	if !descending && !strings.HasSuffix(how, "_asc") {
+		return nil, newError("invalid sort order %q", how)
+	}
+	byValue := strings.HasPrefix(name, "@val_")
+	return func(a, b sortElem) int {
+		var c int
+		aIsArray, bIsArray := a.value.typ == typeArray, b.value.typ == typeArray
+		switch {
+		case byValue && aIsArray && !bIsArray:
+			c = 1 // subarrays sort after scalars
+		case byValue && bIsArray && !aIsArray:
+			c = -1
+		case byValue && aIsArray && bIsArray:
+			c = 0
+		default:
+			c = compare(a, b)
+		}
+		if c == 0 {
+			c = strings.Compare(a.index, b.index)
+		}
+		if descending {
+			c = -c
+		}
+		return c
+	}, nil
+}
+
+func compareNums(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Call user-defined function with the given scalar arguments from Go code
+// (rather than via the CallUser opcode), and return its result.
+func (p *interp) callUserFunc(index int, args []value) (value, error) {
+	f := p.program.Compiled.Functions[index]
+	if len(args) > len(f.Params) {
+		return null(), newError("%q called with more arguments than declared", f.Name)
+	}
+	for i, arg := range args {
+		if f.Arrays[i] {
+			return null(), newError("can't pass scalar as array param %q of %q", f.Params[i], f.Name)
+		}
+		p.push(arg)
+	}
+	p.pushNulls(f.NumScalars - len(args))
+	err := p.callUser(index, nil)
+	if err != nil {
+		return null(), err
+	}
+	return p.pop(), nil
+}
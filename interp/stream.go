@@ -0,0 +1,116 @@
+// The Start...Feed...Finish API (allows you to push records into a running program).
+
+package interp
+
+// Start begins a streaming execution of this program with the given
+// configuration, and runs the BEGIN blocks. Instead of reading records from
+// Config.Stdin, the caller then pushes them into the program one at a time
+// using Feed or FeedFields, and finally calls Finish to run the END blocks.
+//
+// Variables and special variables like NR are handled as they are by
+// Execute. A plain getline in an action still reads from Config.Stdin.
+// Config.CSVInput.Header isn't supported when feeding records; set
+// Config.CSVInput.HeaderNames instead.
+//
+// If a call to Start or Feed returns an error, execution is stopped and
+// further calls to Feed or Finish return an error. A streaming execution
+// must be finished before the Interpreter is used to execute again.
+func (p *Interpreter) Start(config *Config) error {
+	p.interp.resetCore()
+	p.interp.checkCtx = false
+	p.interp.inRange = nil
+	p.interp.streamExited = false
+
+	err := p.interp.setExecuteConfig(config)
+	if err != nil {
+		return err
+	}
+
+	p.interp.streaming = true
+	err = p.interp.executeBlock("BEGIN", 0, nil, p.interp.program.Compiled.BeginLines, p.interp.program.Compiled.Begin)
+	if err == errExit {
+		p.interp.streamExited = true
+		return nil
+	}
+	if err != nil {
+		p.interp.stopStream()
+		return err
+	}
+	return nil
+}
+
+// Feed runs the pattern-action blocks on a single input record, which is
+// split into fields as usual. NR and FNR are incremented for each record.
+// After the program calls exit, further records are ignored.
+func (p *Interpreter) Feed(record string) error {
+	if !p.interp.streaming {
+		return newError("Feed called without Start")
+	}
+	if p.interp.streamExited {
+		return nil
+	}
+	p.interp.setLine(record, false)
+	return p.interp.feedRecord()
+}
+
+// FeedFields is like Feed, but the record's fields are provided directly
+// rather than split from a string (FS and the input mode are not used).
+// $0 is set to the fields joined with OFS.
+func (p *Interpreter) FeedFields(fields []string) error {
+	if !p.interp.streaming {
+		return newError("FeedFields called without Start")
+	}
+	if p.interp.streamExited {
+		return nil
+	}
+	i := p.interp
+	i.fields = append(i.fields[:0], fields...)
+	i.fieldsIsTrueStr = i.fieldsIsTrueStr[:0]
+	for range i.fields {
+		i.fieldsIsTrueStr = append(i.fieldsIsTrueStr, false)
+	}
+	i.numFields = len(i.fields)
+	i.haveFields = true
+	i.line = i.joinFields(i.fields)
+	i.lineIsTrueStr = false
+	i.reparseCSV = false
+	return i.feedRecord()
+}
+
+// Finish runs the END blocks, closes any files and commands opened by the
+// program, and returns the program's exit status, ending the streaming
+// execution started by Start.
+func (p *Interpreter) Finish() (int, error) {
+	if !p.interp.streaming {
+		return 0, newError("Finish called without Start")
+	}
+	defer p.interp.stopStream()
+
+	err := p.interp.executeBlock("END", 0, nil, p.interp.program.Compiled.EndLines, p.interp.program.Compiled.End)
+	if err != nil && err != errExit {
+		return 0, err
+	}
+	return p.interp.exitStatus, nil
+}
+
+// Run the pattern-action blocks on the record that's just been set up.
+func (p *interp) feedRecord() error {
+	p.lineNum++
+	p.fileLineNum++
+	err := p.execRecord(p.program.Compiled.Actions)
+	if err == errExit {
+		p.streamExited = true
+		return nil
+	}
+	if err != nil {
+		p.stopStream()
+		return err
+	}
+	return nil
+}
+
+// End a streaming execution, closing all streams and flushing output.
+func (p *interp) stopStream() {
+	p.streaming = false
+	p.closeAll()
+}
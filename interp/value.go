@@ -5,6 +5,7 @@ package interp
 import (
 	"fmt"
 	"math"
+	"math/big"
 	"strconv"
 	"strings"
 )
@@ -16,13 +17,19 @@ const (
 	typeStr
 	typeNum
 	typeNumStr
+	typeArray
+	typeInt
+	typeBig
 )
 
-// An AWK value (these are passed around by value)
+// An AWK value (these are passed around by value). Don't add fields to
+// this: the Go compiler only keeps structs of up to 32 bytes in registers,
+// and a larger value makes the VM a lot slower. Values of the less common
+// types are stored in the existing fields instead.
 type value struct {
 	typ valueType // Type of value
-	s   string    // String value (for typeStr and typeNumStr)
-	n   float64   // Numeric value (for typeNum)
+	s   string    // String value (for typeStr and typeNumStr), or see bigNum
+	n   float64   // Numeric value (for typeNum), or see integer and subarray
 }
 
 // Create a new null value
@@ -35,6 +42,34 @@ func num(n float64) value {
 	return value{typ: typeNum, n: n}
 }
 
+// Create a new exact integer value (only used in integer mode). To keep
+// values small, the integer's bits are stored in the n field.
+func integer(i int64) value {
+	return value{typ: typeInt, n: math.Float64frombits(uint64(i))}
+}
+
+// Return the integer of a typeInt value.
+func (v value) intVal() int64 {
+	return int64(math.Float64bits(v.n))
+}
+
+// Create a new arbitrary-precision value (only used in -M mode). To keep
+// values small, the big.Float is stored in the s field in its gob encoding.
+func bigNum(f *big.Float) value {
+	if f.IsInf() {
+		return num(math.Inf(f.Sign())) // only NaN and infinity use float64
+	}
+	b, _ := f.GobEncode() // can only fail for a nil f
+	return value{typ: typeBig, s: string(b)}
+}
+
+// Return the big.Float of a typeBig value (a new one each time).
+func (v value) bigVal() *big.Float {
+	f := new(big.Float)
+	_ = f.GobDecode([]byte(v.s)) // s was encoded by bigNum
+	return f
+}
+
 // Create a new string value
 func str(s string) value {
 	return value{typ: typeStr, s: s}
@@ -45,6 +80,80 @@ func numStr(s string) value {
 	return value{typ: typeNumStr, s: s}
 }
 
+// Create a new subarray value, to be stored as an array element. Rather
+// than adding a field to every value, which makes the VM much slower, the
+// map is stored in the interpreter's subarrays table and its index in the
+// n field (see collectSubarrays for how entries are reused).
+func (p *interp) subarray(a map[string]value) value {
+	var i int
+	if n := len(p.freeSubarrays); n > 0 {
+		i = p.freeSubarrays[n-1]
+		p.freeSubarrays = p.freeSubarrays[:n-1]
+		p.subarrays[i] = a
+	} else {
+		i = len(p.subarrays)
+		p.subarrays = append(p.subarrays, a)
+	}
+	p.newSubarrays++
+	return value{typ: typeArray, n: float64(i)}
+}
+
+// Return the map of a typeArray value.
+func (p *interp) subarrayMap(v value) map[string]value {
+	return p.subarrays[int(v.n)]
+}
+
+// Free the entries of the subarrays table that are no longer referenced,
+// if enough subarrays have been created since this last did. Subarray
+// values are only stored in arrays and on the stack, so this must only be
+// called when no other subarray values are in use (the VM calls it before
+// fetching subarrays and before calling native functions).
+func (p *interp) collectSubarrays() {
+	if p.newSubarrays < p.collectSubarraysAt {
+		return
+	}
+	used := make([]bool, len(p.subarrays))
+	visited := 0
+	var mark func(v value)
+	mark = func(v value) {
+		if v.typ != typeArray || used[int(v.n)] {
+			return
+		}
+		used[int(v.n)] = true
+		for _, elem := range p.subarrays[int(v.n)] {
+			mark(elem)
+		}
+		visited += len(p.subarrays[int(v.n)])
+	}
+	for _, array := range p.arrays {
+		for _, v := range array {
+			mark(v)
+		}
+		visited += len(array)
+	}
+	for _, v := range p.stack[:p.sp] {
+		mark(v)
+	}
+
+	p.freeSubarrays = p.freeSubarrays[:0]
+	for i, u := range used {
+		if !u {
+			p.subarrays[i] = nil
+			p.freeSubarrays = append(p.freeSubarrays, i)
+		}
+	}
+	// Wait until about as many subarrays have been created as elements
+	// were visited, so the time spent here is proportional to that.
+	p.newSubarrays = 0
+	p.collectSubarraysAt = visited
+	if p.collectSubarraysAt < minCollectSubarrays {
+		p.collectSubarraysAt = minCollectSubarrays
+	}
+}
+
+// Minimum number of subarrays created between calls to collectSubarrays.
+const minCollectSubarrays = 1024
+
 // Create a numeric value from a Go bool
 func boolean(b bool) value {
 	if b {
@@ -62,6 +171,12 @@ func (v value) String() string {
 		return fmt.Sprintf("num(%s)", v.str("%.6g"))
 	case typeNumStr:
 		return fmt.Sprintf("numStr(%q)", v.s)
+	case typeArray:
+		return fmt.Sprintf("array(#%d)", int(v.n))
+	case typeInt:
+		return fmt.Sprintf("int(%d)", v.intVal())
+	case typeBig:
+		return fmt.Sprintf("big(%s)", v.bigVal().Text('g', 20))
 	default:
 		return "null()"
 	}
@@ -80,6 +195,11 @@ func (v value) isTrueStr() (float64, bool) {
 			return 0, true
 		}
 		return f, false
+	case typeInt:
+		return float64(v.intVal()), false
+	case typeBig:
+		f, _ := v.bigVal().Float64()
+		return f, false
 	default: // typeNum, typeNull
 		return v.n, false
 	}
@@ -98,6 +218,10 @@ func (v value) boolean() bool {
 			return v.s != ""
 		}
 		return f != 0
+	case typeInt:
+		return v.intVal() != 0
+	case typeBig:
+		return v.bigVal().Sign() != 0
 	default: // typeNum, typeNull
 		return v.n != 0
 	}
@@ -131,6 +255,15 @@ func parseFloat(s string) (float64, error) {
 // format if a number value. Integers are a special case and don't
 // use floatFormat.
 func (v value) str(floatFormat string) string {
+	if v.typ == typeInt {
+		return strconv.FormatInt(v.intVal(), 10)
+	}
+	if v.typ == typeBig {
+		if v.bigVal().IsInt() {
+			return v.bigVal().Text('f', 0) // all the digits, like integer mode
+		}
+		return fmt.Sprintf(floatFormat, v.bigVal())
+	}
 	if v.typ == typeNum {
 		switch {
 		case math.IsNaN(v.n):
@@ -155,17 +288,79 @@ func (v value) str(floatFormat string) string {
 	return v.s
 }
 
-// Return value's number value, converting from string if necessary
+// Return value's number value, converting from string if necessary. The
+// common case is kept small enough to be inlined.
 func (v value) num() float64 {
+	if v.typ == typeNum {
+		return v.n
+	}
+	return v.convertNum()
+}
+
+// Guts of num for the types other than typeNum.
+func (v value) convertNum() float64 {
 	switch v.typ {
 	case typeStr, typeNumStr:
 		// Ensure string starts with a float and convert it
 		return parseFloatPrefix(v.s)
-	default: // typeNum, typeNull
+	case typeInt:
+		return float64(v.intVal())
+	case typeBig:
+		f, _ := v.bigVal().Float64()
+		return f
+	default: // typeNull
 		return v.n
 	}
 }
 
+// Return value's exact integer value, converting from string if necessary,
+// and true if it's an integer that fits in an int64. Used in integer mode.
+func (v value) int() (int64, bool) {
+	switch v.typ {
+	case typeStr, typeNumStr:
+		return parseIntPrefix(v.s)
+	case typeInt:
+		return v.intVal(), true
+	case typeBig:
+		i, acc := v.bigVal().Int64()
+		return i, acc == big.Exact
+	default: // typeNum, typeNull
+		return floatToInt(v.n)
+	}
+}
+
+// Return f as an int64, and true if it's an integer in the int64 range.
+func floatToInt(f float64) (int64, bool) {
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// Like parseFloatPrefix, but parse a decimal integer prefix exactly, so that
+// integers above 2^53 don't lose precision. Return false if the number at the
+// start of s isn't an integer or doesn't fit in an int64.
+func parseIntPrefix(s string) (int64, bool) {
+	i := 0
+	for i < len(s) && asciiSpace[s[i]] != 0 {
+		i++
+	}
+	start := i
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digitsStart := i
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	if i == digitsStart || i < len(s) && strings.IndexByte(".eExX", s[i]) >= 0 {
+		// Not a plain integer, for example "1.5", "1e3", "0x10", or "inf"
+		return floatToInt(parseFloatPrefix(s))
+	}
+	n, err := strconv.ParseInt(s[start:i], 10, 64)
+	return n, err == nil
+}
+
 var asciiSpace = [256]uint8{'\t': 1, '\n': 1, '\v': 1, '\f': 1, '\r': 1, ' ': 1}
 
 // Like strconv.ParseFloat, but parses at the start of string and
@@ -194,10 +389,24 @@ func parseFloatPrefix(s string) float64 {
 		}
 	}
 
-	// Parse mantissa: initial digit(s), optional '.', then more digits
 	if i+2 < len(s) && hasHexPrefix(s[i:]) {
 		return parseHexFloatPrefix(s, start, i+2)
 	}
+	end := scanDecimal(s, i)
+	if end < 0 {
+		return 0
+	}
+
+	floatStr := s[start:end]
+	f, _ := strconv.ParseFloat(floatStr, 64)
+	return f // Returns infinity in case of "value out of range" error
+}
+
+// Scan the decimal number starting at s[i] (after any sign) and return the
+// index of its end, or -1 if there are no digits. Used by parseFloatPrefix
+// and parseBigPrefix.
+func scanDecimal(s string, i int) int {
+	// Parse mantissa: initial digit(s), optional '.', then more digits
 	gotDigit := false
 	for i < len(s) && isDigit(s[i]) {
 		gotDigit = true
@@ -211,7 +420,7 @@ func parseFloatPrefix(s string) float64 {
 		i++
 	}
 	if !gotDigit {
-		return 0
+		return -1
 	}
 
 	// Parse exponent ("1e" and similar are allowed, but ParseFloat
@@ -227,10 +436,7 @@ func parseFloatPrefix(s string) float64 {
 			end = i
 		}
 	}
-
-	floatStr := s[start:end]
-	f, _ := strconv.ParseFloat(floatStr, 64)
-	return f // Returns infinity in case of "value out of range" error
+	return end
 }
 
 func hasHexPrefix(s string) bool {
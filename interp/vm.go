@@ -38,12 +38,18 @@ func (p *interp) execute(code []compiler.Opcode) error {
 				return err
 			}
 		}
+		if p.debugger != nil {
+			err := p.debugger.hook(p, ip-1)
+			if err != nil {
+				return err
+			}
+		}
 
 		switch op {
 		case compiler.Num:
 			index := code[ip]
 			ip++
-			p.push(num(p.nums[index]))
+			p.push(p.nums[index])
 
 		case compiler.Str:
 			index := code[ip]
@@ -111,6 +117,9 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			array := p.arrays[arrayIndex]
 			index := p.toString(p.peekTop())
 			v := arrayGet(array, index)
+			if v.typ == typeArray {
+				return subarrayError(index)
+			}
 			p.replaceTop(v)
 
 		case compiler.ArrayLocal:
@@ -119,6 +128,9 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			array := p.localArray(int(arrayIndex))
 			index := p.toString(p.peekTop())
 			v := arrayGet(array, index)
+			if v.typ == typeArray {
+				return subarrayError(index)
+			}
 			p.replaceTop(v)
 
 		case compiler.InGlobal:
@@ -137,6 +149,37 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			_, ok := array[index]
 			p.replaceTop(boolean(ok))
 
+		case compiler.IsArray:
+			arrayScope := code[ip]
+			arrayIndex := code[ip+1]
+			ip += 2
+			array := p.array(ast.VarScope(arrayScope), int(arrayIndex))
+			index := p.toString(p.peekTop())
+			p.replaceTop(boolean(array[index].typ == typeArray))
+
+		case compiler.ArrayValue:
+			arrayScope := code[ip]
+			arrayIndex := code[ip+1]
+			ip += 2
+			p.push(p.subarray(p.array(ast.VarScope(arrayScope), int(arrayIndex))))
+
+		case compiler.SubArray:
+			arrayScope := code[ip]
+			arrayIndex := code[ip+1]
+			numSubscripts := code[ip+2]
+			tempIndex := code[ip+3]
+			ip += 4
+			p.collectSubarrays()
+			array := p.array(ast.VarScope(arrayScope), int(arrayIndex))
+			for _, v := range p.popSlice(int(numSubscripts)) {
+				var err error
+				array, err = p.subarrayGet(array, p.toString(v))
+				if err != nil {
+					return err
+				}
+			}
+			p.arrays[tempIndex] = array
+
 		case compiler.AssignField:
 			right, index := p.popTwo()
 			err := p.setField(int(index.num()), p.toString(right))
@@ -144,6 +187,21 @@ func (p *interp) execute(code []compiler.Opcode) error {
 				return err
 			}
 
+		case compiler.AssignFieldByName:
+			right, name := p.popTwo()
+			err := p.setFieldByName(p.toString(name), p.toString(right))
+			if err != nil {
+				return err
+			}
+
+		case compiler.AssignFieldByNameStr:
+			index := code[ip]
+			ip++
+			err := p.setFieldByName(p.strs[index], p.toString(p.pop()))
+			if err != nil {
+				return err
+			}
+
 		case compiler.AssignGlobal:
 			index := code[ip]
 			ip++
@@ -176,6 +234,18 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			v, index := p.popTwo()
 			array[p.toString(index)] = v
 
+		case compiler.CopyArray:
+			arrayScope := code[ip]
+			arrayIndex := code[ip+1]
+			ip += 2
+			array := p.array(ast.VarScope(arrayScope), int(arrayIndex))
+			for k := range array {
+				delete(array, k)
+			}
+			for k, v := range p.subarrayMap(p.pop()) {
+				array[k] = v
+			}
+
 		case compiler.Delete:
 			arrayScope := code[ip]
 			arrayIndex := code[ip+1]
@@ -198,7 +268,20 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			ip++
 			index := int(p.pop().num())
 			v := p.getField(index)
-			err := p.setField(index, p.toString(num(v.num()+float64(amount))))
+			err := p.setField(index, p.toString(p.incr(v, amount)))
+			if err != nil {
+				return err
+			}
+
+		case compiler.IncrFieldByName:
+			amount := code[ip]
+			ip++
+			name := p.toString(p.pop())
+			v, err := p.getFieldByName(name)
+			if err != nil {
+				return err
+			}
+			err = p.setFieldByName(name, p.toString(p.incr(v, amount)))
 			if err != nil {
 				return err
 			}
@@ -207,20 +290,20 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			amount := code[ip]
 			index := code[ip+1]
 			ip += 2
-			p.globals[index] = num(p.globals[index].num() + float64(amount))
+			p.globals[index] = p.incr(p.globals[index], amount)
 
 		case compiler.IncrLocal:
 			amount := code[ip]
 			index := code[ip+1]
 			ip += 2
-			p.frame[index] = num(p.frame[index].num() + float64(amount))
+			p.frame[index] = p.incr(p.frame[index], amount)
 
 		case compiler.IncrSpecial:
 			amount := code[ip]
 			index := int(code[ip+1])
 			ip += 2
 			v := p.getSpecial(index)
-			err := p.setSpecial(index, num(v.num()+float64(amount)))
+			err := p.setSpecial(index, p.incr(v, amount))
 			if err != nil {
 				return err
 			}
@@ -231,7 +314,11 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			ip += 2
 			array := p.arrays[arrayIndex]
 			index := p.toString(p.pop())
-			array[index] = num(array[index].num() + float64(amount))
+			v := array[index]
+			if v.typ == typeArray {
+				return subarrayError(index)
+			}
+			array[index] = p.incr(v, amount)
 
 		case compiler.IncrArrayLocal:
 			amount := code[ip]
@@ -239,7 +326,11 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			ip += 2
 			array := p.localArray(int(arrayIndex))
 			index := p.toString(p.pop())
-			array[index] = num(array[index].num() + float64(amount))
+			v := array[index]
+			if v.typ == typeArray {
+				return subarrayError(index)
+			}
+			array[index] = p.incr(v, amount)
 
 		case compiler.AugAssignField:
 			operation := compiler.AugOp(code[ip])
@@ -256,6 +347,24 @@ func (p *interp) execute(code []compiler.Opcode) error {
 				return err
 			}
 
+		case compiler.AugAssignFieldByName:
+			operation := compiler.AugOp(code[ip])
+			ip++
+			right, nameVal := p.popTwo()
+			name := p.toString(nameVal)
+			field, err := p.getFieldByName(name)
+			if err != nil {
+				return err
+			}
+			v, err := p.augAssignOp(operation, field, right)
+			if err != nil {
+				return err
+			}
+			err = p.setFieldByName(name, p.toString(v))
+			if err != nil {
+				return err
+			}
+
 		case compiler.AugAssignGlobal:
 			operation := compiler.AugOp(code[ip])
 			index := code[ip+1]
@@ -295,6 +404,9 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			ip += 2
 			array := p.arrays[arrayIndex]
 			index := p.toString(p.pop())
+			if array[index].typ == typeArray {
+				return subarrayError(index)
+			}
 			v, err := p.augAssignOp(operation, array[index], p.pop())
 			if err != nil {
 				return err
@@ -308,6 +420,9 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			array := p.localArray(int(arrayIndex))
 			right, indexVal := p.popTwo()
 			index := p.toString(indexVal)
+			if array[index].typ == typeArray {
+				return subarrayError(index)
+			}
 			v, err := p.augAssignOp(operation, array[index], right)
 			if err != nil {
 				return err
@@ -333,15 +448,27 @@ func (p *interp) execute(code []compiler.Opcode) error {
 
 		case compiler.Add:
 			l, r := p.peekPop()
-			p.replaceTop(num(l.num() + r.num()))
+			if p.numMode != floatMode {
+				p.replaceTop(p.exactAdd(l, r))
+			} else {
+				p.replaceTop(num(l.num() + r.num()))
+			}
 
 		case compiler.Subtract:
 			l, r := p.peekPop()
-			p.replaceTop(num(l.num() - r.num()))
+			if p.numMode != floatMode {
+				p.replaceTop(p.exactSub(l, r))
+			} else {
+				p.replaceTop(num(l.num() - r.num()))
+			}
 
 		case compiler.Multiply:
 			l, r := p.peekPop()
-			p.replaceTop(num(l.num() * r.num()))
+			if p.numMode != floatMode {
+				p.replaceTop(p.exactMul(l, r))
+			} else {
+				p.replaceTop(num(l.num() * r.num()))
+			}
 
 		case compiler.Divide:
 			l, r := p.peekPop()
@@ -349,11 +476,19 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			if rf == 0.0 {
 				return newError("division by zero")
 			}
-			p.replaceTop(num(l.num() / rf))
+			if p.numMode != floatMode {
+				p.replaceTop(p.exactDiv(l, r))
+			} else {
+				p.replaceTop(num(l.num() / rf))
+			}
 
 		case compiler.Power:
 			l, r := p.peekPop()
-			p.replaceTop(num(math.Pow(l.num(), r.num())))
+			if p.numMode != floatMode {
+				p.replaceTop(p.exactPow(l, r))
+			} else {
+				p.replaceTop(num(math.Pow(l.num(), r.num())))
+			}
 
 		case compiler.Modulo:
 			l, r := p.peekPop()
@@ -361,7 +496,11 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			if rf == 0.0 {
 				return newError("division by zero in mod")
 			}
-			p.replaceTop(num(math.Mod(l.num(), rf)))
+			if p.numMode != floatMode {
+				p.replaceTop(p.exactMod(l, r))
+			} else {
+				p.replaceTop(num(math.Mod(l.num(), rf)))
+			}
 
 		case compiler.Equals:
 			l, r := p.peekPop()
@@ -369,6 +508,8 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			rn, rIsStr := r.isTrueStr()
 			if lIsStr || rIsStr {
 				p.replaceTop(boolean(p.toString(l) == p.toString(r)))
+			} else if p.numMode != floatMode && ln == rn {
+				p.replaceTop(boolean(p.compareExact(l, r) == 0))
 			} else {
 				p.replaceTop(boolean(ln == rn))
 			}
@@ -379,6 +520,8 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			rn, rIsStr := r.isTrueStr()
 			if lIsStr || rIsStr {
 				p.replaceTop(boolean(p.toString(l) != p.toString(r)))
+			} else if p.numMode != floatMode && ln == rn {
+				p.replaceTop(boolean(p.compareExact(l, r) != 0))
 			} else {
 				p.replaceTop(boolean(ln != rn))
 			}
@@ -389,6 +532,8 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			rn, rIsStr := r.isTrueStr()
 			if lIsStr || rIsStr {
 				p.replaceTop(boolean(p.toString(l) < p.toString(r)))
+			} else if p.numMode != floatMode && ln == rn {
+				p.replaceTop(boolean(p.compareExact(l, r) < 0))
 			} else {
 				p.replaceTop(boolean(ln < rn))
 			}
@@ -399,6 +544,8 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			rn, rIsStr := r.isTrueStr()
 			if lIsStr || rIsStr {
 				p.replaceTop(boolean(p.toString(l) > p.toString(r)))
+			} else if p.numMode != floatMode && ln == rn {
+				p.replaceTop(boolean(p.compareExact(l, r) > 0))
 			} else {
 				p.replaceTop(boolean(ln > rn))
 			}
@@ -409,6 +556,8 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			rn, rIsStr := r.isTrueStr()
 			if lIsStr || rIsStr {
 				p.replaceTop(boolean(p.toString(l) <= p.toString(r)))
+			} else if p.numMode != floatMode && ln == rn {
+				p.replaceTop(boolean(p.compareExact(l, r) <= 0))
 			} else {
 				p.replaceTop(boolean(ln <= rn))
 			}
@@ -419,6 +568,8 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			rn, rIsStr := r.isTrueStr()
 			if lIsStr || rIsStr {
 				p.replaceTop(boolean(p.toString(l) >= p.toString(r)))
+			} else if p.numMode != floatMode && ln == rn {
+				p.replaceTop(boolean(p.compareExact(l, r) >= 0))
 			} else {
 				p.replaceTop(boolean(ln >= rn))
 			}
@@ -460,14 +611,36 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			p.replaceTop(boolean(!p.peekTop().boolean()))
 
 		case compiler.UnaryMinus:
-			p.replaceTop(num(-p.peekTop().num()))
+			if p.numMode != floatMode {
+				p.replaceTop(p.exactNeg(p.peekTop()))
+			} else {
+				p.replaceTop(num(-p.peekTop().num()))
+			}
 
 		case compiler.UnaryPlus:
-			p.replaceTop(num(p.peekTop().num()))
+			if p.numMode != floatMode {
+				p.replaceTop(p.exactNum(p.peekTop()))
+			} else {
+				p.replaceTop(num(p.peekTop().num()))
+			}
 
 		case compiler.Boolean:
 			p.replaceTop(boolean(p.peekTop().boolean()))
 
+		case compiler.FieldIntEqualsStr:
+			index := code[ip]
+			strIndex := code[ip+1]
+			ip += 2
+			v := p.getField(int(index))
+			p.push(boolean(p.toString(v) == p.strs[strIndex]))
+
+		case compiler.FieldIntNotEqualsStr:
+			index := code[ip]
+			strIndex := code[ip+1]
+			ip += 2
+			v := p.getField(int(index))
+			p.push(boolean(p.toString(v) != p.strs[strIndex]))
+
 		case compiler.Jump:
 			offset := code[ip]
 			ip += 1 + int(offset)
@@ -497,6 +670,8 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			var b bool
 			if lIsStr || rIsStr {
 				b = p.toString(l) == p.toString(r)
+			} else if p.numMode != floatMode && ln == rn {
+				b = p.compareExact(l, r) == 0
 			} else {
 				b = ln == rn
 			}
@@ -513,6 +688,8 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			var b bool
 			if lIsStr || rIsStr {
 				b = p.toString(l) != p.toString(r)
+			} else if p.numMode != floatMode && ln == rn {
+				b = p.compareExact(l, r) != 0
 			} else {
 				b = ln != rn
 			}
@@ -529,6 +706,8 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			var b bool
 			if lIsStr || rIsStr {
 				b = p.toString(l) < p.toString(r)
+			} else if p.numMode != floatMode && ln == rn {
+				b = p.compareExact(l, r) < 0
 			} else {
 				b = ln < rn
 			}
@@ -545,6 +724,8 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			var b bool
 			if lIsStr || rIsStr {
 				b = p.toString(l) > p.toString(r)
+			} else if p.numMode != floatMode && ln == rn {
+				b = p.compareExact(l, r) > 0
 			} else {
 				b = ln > rn
 			}
@@ -561,6 +742,8 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			var b bool
 			if lIsStr || rIsStr {
 				b = p.toString(l) <= p.toString(r)
+			} else if p.numMode != floatMode && ln == rn {
+				b = p.compareExact(l, r) <= 0
 			} else {
 				b = ln <= rn
 			}
@@ -577,6 +760,8 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			var b bool
 			if lIsStr || rIsStr {
 				b = p.toString(l) >= p.toString(r)
+			} else if p.numMode != floatMode && ln == rn {
+				b = p.compareExact(l, r) >= 0
 			} else {
 				b = ln >= rn
 			}
@@ -584,6 +769,26 @@ func (p *interp) execute(code []compiler.Opcode) error {
 				ip += int(offset)
 			}
 
+		case compiler.JumpFieldIntEqualsStr:
+			index := code[ip]
+			strIndex := code[ip+1]
+			offset := code[ip+2]
+			ip += 3
+			v := p.getField(int(index))
+			if p.toString(v) == p.strs[strIndex] {
+				ip += int(offset)
+			}
+
+		case compiler.JumpFieldIntNotEqualsStr:
+			index := code[ip]
+			strIndex := code[ip+1]
+			offset := code[ip+2]
+			ip += 3
+			v := p.getField(int(index))
+			if p.toString(v) != p.strs[strIndex] {
+				ip += int(offset)
+			}
+
 		case compiler.Next:
 			return errNext
 
@@ -601,24 +806,30 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			ip += 5
 			array := p.array(ast.VarScope(arrayScope), int(arrayIndex))
 			loopCode := code[ip : ip+int(offset)]
-			for index := range array {
-				switch ast.VarScope(varScope) {
-				case ast.ScopeGlobal:
-					p.globals[varIndex] = str(index)
-				case ast.ScopeLocal:
-					p.frame[varIndex] = str(index)
-				default: // ScopeSpecial
-					err := p.setSpecial(int(varIndex), str(index))
+			if how := p.sortedIn(); how != "" {
+				// PROCINFO["sorted_in"] is set, loop in sorted order
+				elems, err := p.sortArray(array, how)
+				if err != nil {
+					return err
+				}
+				for _, elem := range elems {
+					err := p.forInIteration(ast.VarScope(varScope), int(varIndex), elem.index, loopCode, ip)
+					if err == errBreak {
+						break
+					}
 					if err != nil {
 						return err
 					}
 				}
-				err := p.execute(loopCode)
-				if err == errBreak {
-					break
-				}
-				if err != nil {
-					return err
+			} else {
+				for index := range array {
+					err := p.forInIteration(ast.VarScope(varScope), int(varIndex), index, loopCode, ip)
+					if err == errBreak {
+						break
+					}
+					if err != nil {
+						return err
+					}
 				}
 			}
 			ip += int(offset)
@@ -639,7 +850,7 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			arrayIndex := code[ip+1]
 			ip += 2
 			s := p.toString(p.peekTop())
-			n, err := p.split(s, ast.VarScope(arrayScope), int(arrayIndex), p.fieldSep)
+			n, err := p.split(s, ast.VarScope(arrayScope), int(arrayIndex), p.fieldSep, "")
 			if err != nil {
 				return err
 			}
@@ -650,12 +861,35 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			arrayIndex := code[ip+1]
 			ip += 2
 			s, fieldSep := p.peekPop()
-			n, err := p.split(p.toString(s), ast.VarScope(arrayScope), int(arrayIndex), p.toString(fieldSep))
+			n, err := p.split(p.toString(s), ast.VarScope(arrayScope), int(arrayIndex), p.toString(fieldSep), "")
+			if err != nil {
+				return err
+			}
+			p.replaceTop(num(float64(n)))
+
+		case compiler.CallSplitSepPat:
+			arrayScope := code[ip]
+			arrayIndex := code[ip+1]
+			ip += 2
+			fieldPat := p.pop()
+			s, fieldSep := p.peekPop()
+			n, err := p.split(p.toString(s), ast.VarScope(arrayScope), int(arrayIndex), p.toString(fieldSep), p.toString(fieldPat))
 			if err != nil {
 				return err
 			}
 			p.replaceTop(num(float64(n)))
 
+		case compiler.CallMatchArray:
+			arrayScope := code[ip]
+			arrayIndex := code[ip+1]
+			ip += 2
+			s, regex := p.peekPop()
+			start, err := p.matchArray(p.toString(s), p.toString(regex), ast.VarScope(arrayScope), int(arrayIndex))
+			if err != nil {
+				return err
+			}
+			p.replaceTop(num(float64(start)))
+
 		case compiler.CallSprintf:
 			numArgs := code[ip]
 			ip++
@@ -666,19 +900,51 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			}
 			p.push(str(s))
 
-		case compiler.CallUser:
-			funcIndex := code[ip]
-			numArrayArgs := int(code[ip+1])
+		case compiler.CallPrintrow:
+			arrayScope := code[ip]
+			arrayIndex := code[ip+1]
 			ip += 2
+			array := p.array(ast.VarScope(arrayScope), int(arrayIndex))
+			fields := p.arrays[p.program.Arrays["OFIELDS"]]
+			n, err := p.printrow(array, fields)
+			if err != nil {
+				return err
+			}
+			p.push(num(float64(n)))
 
-			f := p.program.Compiled.Functions[funcIndex]
-			if p.callDepth >= maxCallDepth {
-				return newError("calling %q exceeded maximum call depth of %d", f.Name, maxCallDepth)
+		case compiler.CallPrintrowFields:
+			arrayScope := code[ip]
+			arrayIndex := code[ip+1]
+			fieldsScope := code[ip+2]
+			fieldsIndex := code[ip+3]
+			ip += 4
+			array := p.array(ast.VarScope(arrayScope), int(arrayIndex))
+			fields := p.array(ast.VarScope(fieldsScope), int(fieldsIndex))
+			n, err := p.printrow(array, fields)
+			if err != nil {
+				return err
+			}
+			p.push(num(float64(n)))
+
+		case compiler.CallAsort, compiler.CallAsorti:
+			srcScope := code[ip]
+			srcIndex := code[ip+1]
+			destScope := code[ip+2]
+			destIndex := code[ip+3]
+			ip += 4
+			src := p.array(ast.VarScope(srcScope), int(srcIndex))
+			dest := p.array(ast.VarScope(destScope), int(destIndex))
+			how := p.toString(p.peekTop())
+			n, err := p.asort(src, dest, how, op == compiler.CallAsorti)
+			if err != nil {
+				return err
 			}
+			p.replaceTop(num(float64(n)))
 
-			// Set up frame for scalar arguments
-			oldFrame := p.frame
-			p.frame = p.peekSlice(f.NumScalars)
+		case compiler.CallUser:
+			funcIndex := code[ip]
+			numArrayArgs := int(code[ip+1])
+			ip += 2
 
 			// Handle array arguments
 			var arrays []int
@@ -688,30 +954,10 @@ func (p *interp) execute(code []compiler.Opcode) error {
 				ip += 2
 				arrays = append(arrays, p.arrayIndex(arrayScope, arrayIndex))
 			}
-			oldArraysLen := len(p.arrays)
-			for j := numArrayArgs; j < f.NumArrays; j++ {
-				arrays = append(arrays, len(p.arrays))
-				p.arrays = append(p.arrays, make(map[string]value))
-			}
-			p.localArrays = append(p.localArrays, arrays)
 
-			// Execute the function!
-			p.callDepth++
-			err := p.execute(f.Body)
-			p.callDepth--
-
-			// Pop the locals off the stack
-			p.popSlice(f.NumScalars)
-			p.frame = oldFrame
-			p.localArrays = p.localArrays[:len(p.localArrays)-1]
-			p.arrays = p.arrays[:oldArraysLen]
-
-			if r, ok := err.(returnValue); ok {
-				p.push(r.Value)
-			} else if err != nil {
+			err := p.callUser(int(funcIndex), arrays)
+			if err != nil {
 				return err
-			} else {
-				p.push(null())
 			}
 
 		case compiler.CallNative:
@@ -719,6 +965,7 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			numArgs := int(code[ip+1])
 			ip += 2
 
+			p.collectSubarrays()
 			args := p.popSlice(numArgs)
 			r, err := p.callNative(funcIndex, args)
 			if err != nil {
@@ -823,6 +1070,23 @@ func (p *interp) execute(code []compiler.Opcode) error {
 			}
 			p.push(num(ret))
 
+		case compiler.GetlineFieldByName:
+			redirect := lexer.Token(code[ip])
+			ip++
+
+			ret, line, err := p.getline(redirect)
+			if err != nil {
+				return err
+			}
+			name := p.toString(p.peekTop())
+			if ret == 1 {
+				err := p.setFieldByName(name, line)
+				if err != nil {
+					return err
+				}
+			}
+			p.replaceTop(num(ret))
+
 		case compiler.GetlineGlobal:
 			redirect := lexer.Token(code[ip])
 			index := code[ip+1]
@@ -892,6 +1156,14 @@ func (p *interp) execute(code []compiler.Opcode) error {
 
 func (p *interp) callBuiltin(builtinOp compiler.BuiltinOp) error {
 	switch builtinOp {
+	case compiler.BuiltinAnd:
+		l, r := p.peekPop()
+		x, y, err := p.bitwiseArgs("and", l, r)
+		if err != nil {
+			return err
+		}
+		p.replaceTop(p.bitwiseResult(x & y))
+
 	case compiler.BuiltinAtan2:
 		y, x := p.peekPop()
 		p.replaceTop(num(math.Atan2(y.num(), x.num())))
@@ -925,6 +1197,13 @@ func (p *interp) callBuiltin(builtinOp compiler.BuiltinOp) error {
 			}
 		}
 
+	case compiler.BuiltinCompl:
+		x, err := p.bitwiseArg("compl", p.peekTop())
+		if err != nil {
+			return err
+		}
+		p.replaceTop(p.bitwiseResult(^x))
+
 	case compiler.BuiltinCos:
 		p.replaceTop(num(math.Cos(p.peekTop().num())))
 
@@ -963,6 +1242,22 @@ func (p *interp) callBuiltin(builtinOp compiler.BuiltinOp) error {
 		}
 		p.replaceTwo(num(float64(n)), str(out))
 
+	case compiler.BuiltinGensub:
+		how, in := p.popTwo()
+		regex, repl := p.peekPop()
+		global := false
+		n := 1
+		if howStr := p.toString(how); howStr != "" && (howStr[0] == 'g' || howStr[0] == 'G') {
+			global = true
+		} else if howNum := int(how.num()); howNum > 1 {
+			n = howNum
+		}
+		out, err := p.gensub(p.toString(regex), p.toString(repl), p.toString(in), global, n)
+		if err != nil {
+			return err
+		}
+		p.replaceTop(str(out))
+
 	case compiler.BuiltinIndex:
 		sValue, substr := p.peekPop()
 		s := p.toString(sValue)
@@ -970,7 +1265,11 @@ func (p *interp) callBuiltin(builtinOp compiler.BuiltinOp) error {
 		p.replaceTop(num(float64(index + 1)))
 
 	case compiler.BuiltinInt:
-		p.replaceTop(num(float64(int(p.peekTop().num()))))
+		if p.numMode != floatMode {
+			p.replaceTop(p.exactInt(p.peekTop()))
+		} else {
+			p.replaceTop(num(float64(int(p.peekTop().num()))))
+		}
 
 	case compiler.BuiltinLength:
 		p.push(num(float64(len(p.line))))
@@ -982,6 +1281,14 @@ func (p *interp) callBuiltin(builtinOp compiler.BuiltinOp) error {
 	case compiler.BuiltinLog:
 		p.replaceTop(num(math.Log(p.peekTop().num())))
 
+	case compiler.BuiltinLshift:
+		l, r := p.peekPop()
+		x, shift, err := p.bitwiseArgs("lshift", l, r)
+		if err != nil {
+			return err
+		}
+		p.replaceTop(p.bitwiseResult(x << shift))
+
 	case compiler.BuiltinMatch:
 		sValue, regex := p.peekPop()
 		s := p.toString(sValue)
@@ -1000,9 +1307,29 @@ func (p *interp) callBuiltin(builtinOp compiler.BuiltinOp) error {
 			p.replaceTop(num(float64(p.matchStart)))
 		}
 
+	case compiler.BuiltinMktime:
+		spec, utc := p.peekPop()
+		p.replaceTop(num(float64(p.mktime(p.toString(spec), utc.boolean()))))
+
+	case compiler.BuiltinOr:
+		l, r := p.peekPop()
+		x, y, err := p.bitwiseArgs("or", l, r)
+		if err != nil {
+			return err
+		}
+		p.replaceTop(p.bitwiseResult(x | y))
+
 	case compiler.BuiltinRand:
 		p.push(num(p.random.Float64()))
 
+	case compiler.BuiltinRshift:
+		l, r := p.peekPop()
+		x, shift, err := p.bitwiseArgs("rshift", l, r)
+		if err != nil {
+			return err
+		}
+		p.replaceTop(p.bitwiseResult(x >> shift))
+
 	case compiler.BuiltinSin:
 		p.replaceTop(num(math.Sin(p.peekTop().num())))
 
@@ -1020,6 +1347,11 @@ func (p *interp) callBuiltin(builtinOp compiler.BuiltinOp) error {
 		p.random.Seed(int64(math.Float64bits(p.randSeed)))
 		p.replaceTop(num(prevSeed))
 
+	case compiler.BuiltinStrftime:
+		timestamp, utc := p.popTwo()
+		format := p.toString(p.peekTop())
+		p.replaceTop(str(p.strftime(format, timestamp.num(), utc.boolean())))
+
 	case compiler.BuiltinSub:
 		regex, repl, in := p.peekPeekPop()
 		out, n, err := p.sub(p.toString(regex), p.toString(repl), p.toString(in), false)
@@ -1086,11 +1418,22 @@ func (p *interp) callBuiltin(builtinOp compiler.BuiltinOp) error {
 		}
 		p.replaceTop(num(ret))
 
+	case compiler.BuiltinSystime:
+		p.push(num(float64(p.now().Unix())))
+
 	case compiler.BuiltinTolower:
 		p.replaceTop(str(strings.ToLower(p.toString(p.peekTop()))))
 
 	case compiler.BuiltinToupper:
 		p.replaceTop(str(strings.ToUpper(p.toString(p.peekTop()))))
+
+	case compiler.BuiltinXor:
+		l, r := p.peekPop()
+		x, y, err := p.bitwiseArgs("xor", l, r)
+		if err != nil {
+			return err
+		}
+		p.replaceTop(p.bitwiseResult(x ^ y))
 	}
 
 	return nil
@@ -1108,6 +1451,97 @@ func arrayGet(array map[string]value, index string) value {
 	return v
 }
 
+// Return the subarray array[index], creating it if the element doesn't exist
+// (or is an uninitialized value), or an error if the element is a scalar.
+func (p *interp) subarrayGet(array map[string]value, index string) (map[string]value, error) {
+	v := array[index]
+	switch v.typ {
+	case typeArray:
+		return p.subarrayMap(v), nil
+	case typeNull:
+		m := make(map[string]value)
+		array[index] = p.subarray(m)
+		return m, nil
+	default:
+		return nil, newError("can't use scalar element %q as array", index)
+	}
+}
+
+// Return the error for using subarray array[index] in a scalar context.
+func subarrayError(index string) error {
+	return newError("can't use array element %q as scalar", index)
+}
+
+// Set the loop variable of a for-in loop to index and execute the body of the
+// loop once.
+func (p *interp) forInIteration(varScope ast.VarScope, varIndex int, index string, loopCode []compiler.Opcode, offset int) error {
+	switch varScope {
+	case ast.ScopeGlobal:
+		p.globals[varIndex] = str(index)
+	case ast.ScopeLocal:
+		p.frame[varIndex] = str(index)
+	default: // ScopeSpecial
+		err := p.setSpecial(varIndex, str(index))
+		if err != nil {
+			return err
+		}
+	}
+	return p.executeLoop(loopCode, offset)
+}
+
+// Call user-defined function. The caller has already pushed the function's
+// scalar arguments (and nulls for any remaining scalar locals); arrays holds
+// the indexes of the array arguments. The return value is pushed.
+func (p *interp) callUser(index int, arrays []int) error {
+	f := p.program.Compiled.Functions[index]
+	if p.callDepth >= maxCallDepth {
+		return newError("calling %q exceeded maximum call depth of %d", f.Name, maxCallDepth)
+	}
+
+	// Set up frame for scalar arguments
+	oldFrame := p.frame
+	p.frame = p.peekSlice(f.NumScalars)
+
+	// Give subarray arguments their own slots, as the temporary arrays
+	// they were fetched into may be reused while the function runs
+	oldArraysLen := len(p.arrays)
+	tempStart := len(p.program.Arrays)
+	tempEnd := tempStart + p.program.Compiled.TempArrays
+	for j, arrayIndex := range arrays {
+		if arrayIndex >= tempStart && arrayIndex < tempEnd {
+			arrays[j] = len(p.arrays)
+			p.arrays = append(p.arrays, p.arrays[arrayIndex])
+		}
+	}
+
+	// Create local arrays for array parameters that weren't passed
+	for j := len(arrays); j < f.NumArrays; j++ {
+		arrays = append(arrays, len(p.arrays))
+		p.arrays = append(p.arrays, make(map[string]value))
+	}
+	p.localArrays = append(p.localArrays, arrays)
+
+	// Execute the function!
+	p.callDepth++
+	err := p.executeBlock("function", 0, &p.functions[index], f.Lines, f.Body)
+	p.callDepth--
+
+	// Pop the locals off the stack
+	p.popSlice(f.NumScalars)
+	p.frame = oldFrame
+	p.localArrays = p.localArrays[:len(p.localArrays)-1]
+	p.arrays = p.arrays[:oldArraysLen]
+
+	if r, ok := err.(returnValue); ok {
+		p.push(r.Value)
+	} else if err != nil {
+		return err
+	} else {
+		p.push(null())
+	}
+	return nil
+}
+
 // Stack operations follow. These should be inlined. Instead of just push and
 // pop, for efficiency we have custom operations for when we're replacing the
 // top of stack without changing the stack pointer. Primarily this avoids the
@@ -1184,6 +1618,9 @@ func (p *interp) peekSlice(n int) []value {
 // of a line, and returns the result. If the result is 1 (success in AWK), the
 // caller will set the target to the returned string.
 func (p *interp) getline(redirect lexer.Token) (float64, string, error) {
+	if p.parallelWorker {
+		return 0, "", newError("getline not supported in parallel mode")
+	}
 	switch redirect {
 	case lexer.PIPE: // redirect from command
 		name := p.toString(p.pop())
@@ -1232,8 +1669,29 @@ func (p *interp) getline(redirect lexer.Token) (float64, string, error) {
 	}
 }
 
+// Return v incremented by amount (which may be negative), for the Incr*
+// opcodes. The common case is kept small enough to be inlined.
+func (p *interp) incr(v value, amount compiler.Opcode) value {
+	if v.typ == typeNum && p.numMode == floatMode {
+		v.n += float64(amount)
+		return v
+	}
+	return p.incrOther(v, amount)
+}
+
+// Guts of incr for values that aren't numbers, or in the exact modes.
+func (p *interp) incrOther(v value, amount compiler.Opcode) value {
+	if p.numMode != floatMode {
+		return p.exactAdd(v, integer(int64(amount)))
+	}
+	return num(v.num() + float64(amount))
+}
+
 // Perform augmented assignment operation.
 func (p *interp) augAssignOp(op compiler.AugOp, l, r value) (value, error) {
+	if p.numMode != floatMode {
+		return p.exactAugAssignOp(op, l, r)
+	}
 	switch op {
 	case compiler.AugOpAdd:
 		return num(l.num() + r.num()), nil
@@ -127,6 +127,13 @@ func (l *Lexer) scan() (Position, Token, string) {
 		tok = DOLLAR
 	case '@':
 		tok = AT
+		if l.hasPrefixWord("include") {
+			// @include "file" directive
+			for i := 0; i < len("include"); i++ {
+				l.next()
+			}
+			tok = INCLUDE
+		}
 	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '.':
 		// Avoid make/append and use l.offset directly for performance
 		start := l.offset - 2
@@ -360,6 +367,17 @@ func (l *Lexer) next() {
 	l.offset++
 }
 
+// Return true if the source at the current character starts with the given
+// word, followed by a character that can't be part of a name.
+func (l *Lexer) hasPrefixWord(word string) bool {
+	start := l.offset - 1
+	end := start + len(word)
+	if start < 0 || end > len(l.src) || string(l.src[start:end]) != word {
+		return false
+	}
+	return end == len(l.src) || !isNameStart(l.src[end]) && !isDigit(l.src[end])
+}
+
 // Un-read the character just scanned (doesn't handle line boundaries).
 func (l *Lexer) unread() {
 	l.offset--
@@ -27,6 +27,9 @@ func TestLexer(t *testing.T) {
 		{"x y0", `1:1 name "x", 1:3 name "y0"`},
 		{"x 0y", `1:1 name "x", 1:3 number "0", 1:4 name "y"`},
 		{"sub SUB", `1:1 sub "", 1:5 name "SUB"`},
+		{`@include "a.awk"`, `1:1 @include "", 1:10 string "a.awk"`},
+		{"@includes @include", `1:1 @ "", 1:2 name "includes", 1:11 @include ""`},
+		{`@"include"`, `1:1 @ "", 1:2 string "include"`},
 
 		// String tokens
 		{`"foo"`, `1:1 string "foo"`},
@@ -222,9 +225,9 @@ func TestAllTokens(t *testing.T) {
 		"+ += && = : , -- /\n/= $ @ == >= > >> ++ { [ < ( #\n" +
 		"<= ~ % %= * *= !~ ! != | || ^ ^= ** **= ? } ] ) ; - -= " +
 		"BEGIN break continue delete do else END exit " +
-		"for function getline if in next print printf return while " +
-		"atan2 close cos exp fflush gsub index int length log match rand " +
-		"sin split sprintf sqrt srand sub substr system tolower toupper " +
+		"for function getline if in @include next print printf return while " +
+		"and asort asorti atan2 close compl cos exp fflush gensub gsub index int isarray length log lshift match mktime or " +
+		"printrow rand rshift sin split sprintf sqrt srand strftime sub substr system systime tolower toupper xor " +
 		"x \"str\\n\" 1234\n" +
 		"` ."
 
@@ -245,9 +248,9 @@ func TestAllTokens(t *testing.T) {
 		"+ += && = : , -- / <newline> /= $ @ == >= > >> ++ { [ < ( <newline> " +
 		"<= ~ % %= * *= !~ ! != | || ^ ^= ^ ^= ? } ] ) ; - -= " +
 		"BEGIN break continue delete do else END exit " +
-		"for function getline if in next print printf return while " +
-		"atan2 close cos exp fflush gsub index int length log match rand " +
-		"sin split sprintf sqrt srand sub substr system tolower toupper " +
+		"for function getline if in @include next print printf return while " +
+		"and asort asorti atan2 close compl cos exp fflush gensub gsub index int isarray length log lshift match mktime or " +
+		"printrow rand rshift sin split sprintf sqrt srand strftime sub substr system systime tolower toupper xor " +
 		"name string number <newline> " +
 		"<illegal> <illegal> EOF"
 	if output != expected {
@@ -69,6 +69,7 @@ const (
 	GETLINE
 	IF
 	IN
+	INCLUDE
 	NEXT
 	PRINT
 	PRINTF
@@ -77,28 +78,42 @@ const (
 
 	// Built-in functions
 
+	F_AND
+	F_ASORT
+	F_ASORTI
 	F_ATAN2
 	F_CLOSE
+	F_COMPL
 	F_COS
 	F_EXP
 	F_FFLUSH
+	F_GENSUB
 	F_GSUB
 	F_INDEX
 	F_INT
+	F_ISARRAY
 	F_LENGTH
 	F_LOG
+	F_LSHIFT
 	F_MATCH
+	F_MKTIME
+	F_OR
+	F_PRINTROW
 	F_RAND
+	F_RSHIFT
 	F_SIN
 	F_SPLIT
 	F_SPRINTF
 	F_SQRT
 	F_SRAND
+	F_STRFTIME
 	F_SUB
 	F_SUBSTR
 	F_SYSTEM
+	F_SYSTIME
 	F_TOLOWER
 	F_TOUPPER
+	F_XOR
 
 	// Literals and names (variables and arrays)
 
@@ -108,8 +123,8 @@ const (
 	REGEX
 
 	LAST       = REGEX
-	FIRST_FUNC = F_ATAN2
-	LAST_FUNC  = F_TOUPPER
+	FIRST_FUNC = F_AND
+	LAST_FUNC  = F_XOR
 )
 
 var keywordTokens = map[string]Token{
@@ -132,28 +147,42 @@ var keywordTokens = map[string]Token{
 	"return":   RETURN,
 	"while":    WHILE,
 
-	"atan2":   F_ATAN2,
-	"close":   F_CLOSE,
-	"cos":     F_COS,
-	"exp":     F_EXP,
-	"fflush":  F_FFLUSH,
-	"gsub":    F_GSUB,
-	"index":   F_INDEX,
-	"int":     F_INT,
-	"length":  F_LENGTH,
-	"log":     F_LOG,
-	"match":   F_MATCH,
-	"rand":    F_RAND,
-	"sin":     F_SIN,
-	"split":   F_SPLIT,
-	"sprintf": F_SPRINTF,
-	"sqrt":    F_SQRT,
-	"srand":   F_SRAND,
-	"sub":     F_SUB,
-	"substr":  F_SUBSTR,
-	"system":  F_SYSTEM,
-	"tolower": F_TOLOWER,
-	"toupper": F_TOUPPER,
+	"and":      F_AND,
+	"asort":    F_ASORT,
+	"asorti":   F_ASORTI,
+	"atan2":    F_ATAN2,
+	"close":    F_CLOSE,
+	"compl":    F_COMPL,
+	"cos":      F_COS,
+	"exp":      F_EXP,
+	"fflush":   F_FFLUSH,
+	"gensub":   F_GENSUB,
+	"gsub":     F_GSUB,
+	"index":    F_INDEX,
+	"int":      F_INT,
+	"isarray":  F_ISARRAY,
+	"length":   F_LENGTH,
+	"log":      F_LOG,
+	"lshift":   F_LSHIFT,
+	"match":    F_MATCH,
+	"mktime":   F_MKTIME,
+	"or":       F_OR,
+	"printrow": F_PRINTROW,
+	"rand":     F_RAND,
+	"rshift":   F_RSHIFT,
+	"sin":      F_SIN,
+	"split":    F_SPLIT,
+	"sprintf":  F_SPRINTF,
+	"sqrt":     F_SQRT,
+	"srand":    F_SRAND,
+	"strftime": F_STRFTIME,
+	"sub":      F_SUB,
+	"substr":   F_SUBSTR,
+	"system":   F_SYSTEM,
+	"systime":  F_SYSTIME,
+	"tolower":  F_TOLOWER,
+	"toupper":  F_TOUPPER,
+	"xor":      F_XOR,
 }
 
 // KeywordToken returns the token associated with the given keyword
@@ -222,34 +251,49 @@ var tokenNames = map[Token]string{
 	GETLINE:  "getline",
 	IF:       "if",
 	IN:       "in",
+	INCLUDE:  "@include",
 	NEXT:     "next",
 	PRINT:    "print",
 	PRINTF:   "printf",
 	RETURN:   "return",
 	WHILE:    "while",
 
-	F_ATAN2:   "atan2",
-	F_CLOSE:   "close",
-	F_COS:     "cos",
-	F_EXP:     "exp",
-	F_FFLUSH:  "fflush",
-	F_GSUB:    "gsub",
-	F_INDEX:   "index",
-	F_INT:     "int",
-	F_LENGTH:  "length",
-	F_LOG:     "log",
-	F_MATCH:   "match",
-	F_RAND:    "rand",
-	F_SIN:     "sin",
-	F_SPLIT:   "split",
-	F_SPRINTF: "sprintf",
-	F_SQRT:    "sqrt",
-	F_SRAND:   "srand",
-	F_SUB:     "sub",
-	F_SUBSTR:  "substr",
-	F_SYSTEM:  "system",
-	F_TOLOWER: "tolower",
-	F_TOUPPER: "toupper",
+	F_AND:      "and",
+	F_ASORT:    "asort",
+	F_ASORTI:   "asorti",
+	F_ATAN2:    "atan2",
+	F_CLOSE:    "close",
+	F_COMPL:    "compl",
+	F_COS:      "cos",
+	F_EXP:      "exp",
+	F_FFLUSH:   "fflush",
+	F_GENSUB:   "gensub",
+	F_GSUB:     "gsub",
+	F_INDEX:    "index",
+	F_INT:      "int",
+	F_ISARRAY:  "isarray",
+	F_LENGTH:   "length",
+	F_LOG:      "log",
+	F_LSHIFT:   "lshift",
+	F_MATCH:    "match",
+	F_MKTIME:   "mktime",
+	F_OR:       "or",
+	F_PRINTROW: "printrow",
+	F_RAND:     "rand",
+	F_RSHIFT:   "rshift",
+	F_SIN:      "sin",
+	F_SPLIT:    "split",
+	F_SPRINTF:  "sprintf",
+	F_SQRT:     "sqrt",
+	F_SRAND:    "srand",
+	F_STRFTIME: "strftime",
+	F_SUB:      "sub",
+	F_SUBSTR:   "substr",
+	F_SYSTEM:   "system",
+	F_SYSTIME:  "systime",
+	F_TOLOWER:  "tolower",
+	F_TOUPPER:  "toupper",
+	F_XOR:      "xor",
 
 	NAME:   "name",
 	NUMBER: "number",
@@ -0,0 +1,123 @@
+// Handling of @include directives.
+
+package parser
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/nuvolaris/goawk/internal/ast"
+	. "github.com/nuvolaris/goawk/lexer"
+)
+
+// Parse an @include "file" directive, and parse the top-level items in the
+// included file into prog (unless it has already been included).
+func (p *parser) include(prog *ast.Program) {
+	pos := p.pos
+	p.next()
+	if p.tok != STRING {
+		panic(p.errorf("expected file name string after @include"))
+	}
+	name := p.val
+	p.next()
+
+	includePath, key, src := p.readInclude(name, pos)
+	for i, inc := range p.includeStack {
+		if inc.key == key {
+			var cycle []string
+			for _, inc := range p.includeStack[i:] {
+				cycle = append(cycle, inc.path)
+			}
+			cycle = append(cycle, includePath)
+			panic(ast.PosErrorf(pos, "@include cycle: %s", strings.Join(cycle, " -> ")))
+		}
+	}
+	if p.included[key] {
+		return
+	}
+	if p.included == nil {
+		p.included = make(map[string]bool)
+	}
+	p.included[key] = true
+	if p.config != nil && p.config.IncludeSources != nil {
+		err := p.config.IncludeSources.AddFile(includePath, bytes.NewReader(src))
+		if err != nil {
+			panic(ast.PosErrorf(pos, "%v", err))
+		}
+	}
+
+	// Parse the included file with a new lexer, numbering its lines after
+	// all the source seen so far, then continue where we left off.
+	lexer, nextPos, tok, prevTok, val, lineOffset := p.lexer, p.pos, p.tok, p.prevTok, p.val, p.lineOffset
+	p.lexer = NewLexer(src)
+	p.lineOffset = p.numLines
+	p.numLines += countLines(src)
+	p.includeStack = append(p.includeStack, includeFile{includePath, key})
+	p.next()
+	p.items(prog)
+	p.includeStack = p.includeStack[:len(p.includeStack)-1]
+	p.lexer, p.pos, p.tok, p.prevTok, p.val, p.lineOffset = lexer, nextPos, tok, prevTok, val, lineOffset
+}
+
+// A file being included, with the key used to detect duplicates.
+type includeFile struct {
+	path string
+	key  string
+}
+
+// Find and read the @include file with the given name, returning its path,
+// the key used to detect duplicate includes, and its source.
+func (p *parser) readInclude(name string, pos Position) (includePath, key string, src []byte) {
+	var config ParserConfig
+	if p.config != nil {
+		config = *p.config
+	}
+	readFile, join, clean := ioutil.ReadFile, filepath.Join, filepath.Abs
+	if config.IncludeFS != nil {
+		readFile, join = config.IncludeFS.ReadFile, path.Join
+		clean = func(name string) (string, error) { return path.Clean(name), nil }
+	} else if !config.IncludeOSFiles {
+		panic(ast.PosErrorf(pos, "can't @include %q: IncludeFS or IncludeOSFiles not set", name))
+	}
+
+	dirs := config.IncludePaths
+	if strings.ContainsRune(name, '/') || filepath.IsAbs(name) || len(dirs) == 0 {
+		dirs = []string{""}
+	}
+	names := []string{name}
+	if !strings.HasSuffix(name, ".awk") {
+		names = append(names, name+".awk")
+	}
+	for _, dir := range dirs {
+		for _, candidate := range names {
+			candidate = join(dir, candidate)
+			src, err := readFile(candidate)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				panic(ast.PosErrorf(pos, "can't read @include file %q: %v", candidate, err))
+			}
+			key, err := clean(candidate)
+			if err != nil {
+				key = candidate
+			}
+			return candidate, key, src
+		}
+	}
+	panic(ast.PosErrorf(pos, "can't find @include file %q", name))
+}
+
+// Return the number of lines in src, counting a final line that doesn't end
+// with a newline.
+func countLines(src []byte) int {
+	n := bytes.Count(src, []byte{'\n'})
+	if len(src) > 0 && src[len(src)-1] != '\n' {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,22 @@
+//go:build go1.16
+// +build go1.16
+
+package parser
+
+import (
+	"io/fs"
+)
+
+// NewIncludeFS returns an IncludeFS that reads @include files from fsys,
+// for use in ParserConfig.IncludeFS.
+func NewIncludeFS(fsys fs.FS) IncludeFS {
+	return includeFS{fsys}
+}
+
+type includeFS struct {
+	fsys fs.FS
+}
+
+func (f includeFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(f.fsys, name)
+}
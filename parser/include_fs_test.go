@@ -0,0 +1,29 @@
+//go:build go1.16
+// +build go1.16
+
+package parser_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/nuvolaris/goawk/parser"
+)
+
+func TestIncludeFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"awk/lib.awk": &fstest.MapFile{Data: []byte(`function f() { return 42 }`)},
+	}
+	config := &parser.ParserConfig{
+		IncludePaths: []string{"awk"},
+		IncludeFS:    parser.NewIncludeFS(fsys),
+	}
+	prog, err := parser.ParseProgram([]byte(`@include "lib"`), config)
+	if err != nil {
+		t.Fatalf("error parsing program: %v", err)
+	}
+	expected := "function f() {\n    return 42\n}"
+	if prog.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, prog.String())
+	}
+}
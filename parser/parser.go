@@ -44,6 +44,57 @@ type ParserConfig struct {
 	// Map of named Go functions to allow calling from AWK. See docs
 	// on interp.Config.Funcs for details.
 	Funcs map[string]interface{}
+
+	// Directories to search for files named in @include directives (the
+	// goawk command sets this from the AWKPATH environment variable). If
+	// empty, only the current directory is searched. Names that contain a
+	// slash aren't searched for, and ".awk" is added to names that don't
+	// have it if the name as given isn't found.
+	IncludePaths []string
+
+	// File system to read @include files from. If nil (the default),
+	// files are read from the operating system's file system if
+	// IncludeOSFiles is true, and @include directives are an error if not.
+	IncludeFS IncludeFS
+
+	// Allow @include directives to read any file from the operating
+	// system's file system when IncludeFS is nil. This is off by default so
+	// that embedders that restrict file access (for example, with
+	// interp.Config.NoFileReads) don't have to guard against @include as
+	// well. The goawk command turns this on.
+	IncludeOSFiles bool
+
+	// If non-nil, AddFile is called with the path and source of each file
+	// included by an @include directive, in the order they're parsed.
+	// Included files are only parsed once, even if included several times.
+	//
+	// Line numbers in positions (for example, in a *ParseError) continue
+	// on from the end of the program source: the first included file
+	// starts on the line after the last line of the program source, the
+	// second starts after the last line of the first, and so on. So if
+	// AddFile concatenates the sources in the order it receives them, as
+	// the goawk command does, a line number can be mapped back to the
+	// file it's in.
+	IncludeSources SourceAdder
+
+	// Disable the compiler's optimizations, such as constant folding (the
+	// goawk command's -noopt option). This doesn't change how the program
+	// behaves, but is useful for comparing disassembly output.
+	NoOptimize bool
+}
+
+// IncludeFS is the interface used to read @include files. It has the same
+// method as fs.ReadFileFS, so an embed.FS or fstest.MapFS can be used
+// directly. With Go 1.16 and later, use NewIncludeFS to read files from any
+// fs.FS.
+type IncludeFS interface {
+	ReadFile(name string) ([]byte, error)
+}
+
+// SourceAdder is the interface used to record the source of @include files
+// (see ParserConfig.IncludeSources).
+type SourceAdder interface {
+	AddFile(path string, source io.Reader) error
 }
 
 func (c *ParserConfig) toResolverConfig() *resolver.Config {
@@ -57,6 +108,15 @@ func (c *ParserConfig) toResolverConfig() *resolver.Config {
 	}
 }
 
+func (c *ParserConfig) toCompilerConfig() *compiler.Config {
+	if c == nil {
+		return nil
+	}
+	return &compiler.Config{
+		NoOptimize: c.NoOptimize,
+	}
+}
+
 // ParseProgram parses an entire AWK program, returning the *Program
 // abstract syntax tree or a *ParseError on error. "config" describes
 // the parser configuration (and is allowed to be nil).
@@ -75,8 +135,9 @@ func ParseProgram(src []byte, config *ParserConfig) (prog *Program, err error) {
 		}
 	}()
 	lexer := NewLexer(src)
-	p := parser{lexer: lexer}
+	p := parser{lexer: lexer, config: config}
 	p.multiExprs = make(map[*ast.MultiExpr]Position, 3)
+	p.numLines = countLines(src)
 
 	p.next() // initialize p.tok
 
@@ -89,7 +150,7 @@ func ParseProgram(src []byte, config *ParserConfig) (prog *Program, err error) {
 	prog.ResolvedProgram = *resolver.Resolve(astProg, config.toResolverConfig())
 
 	// Compile to virtual machine code
-	prog.Compiled, err = compiler.Compile(&prog.ResolvedProgram)
+	prog.Compiled, err = compiler.Compile(&prog.ResolvedProgram, config.toCompilerConfig())
 	return prog, err
 }
 
@@ -115,6 +176,37 @@ func (p *Program) Disassemble(writer io.Writer) error {
 	return p.Compiled.Disassemble(writer)
 }
 
+// MarshalBinary encodes the compiled form of the program, so that it can
+// be saved and later loaded with UnmarshalBinary, which is much faster
+// than parsing the source again. The syntax tree isn't included, so String
+// returns an empty string for a loaded program.
+func (p *Program) MarshalBinary() ([]byte, error) {
+	return p.Compiled.MarshalBinary()
+}
+
+// UnmarshalBinary loads a program encoded by MarshalBinary. It returns an
+// error if the data was produced by a version of GoAWK with a different
+// instruction set. If the program calls native Go functions, the
+// interpreter's Config.Funcs must have the same names and signatures as
+// the ParserConfig used when the program was originally parsed.
+func (p *Program) UnmarshalBinary(data []byte) error {
+	compiled := &compiler.Program{}
+	err := compiled.UnmarshalBinary(data)
+	if err != nil {
+		return err
+	}
+	scalars, arrays := compiled.VarIndexes()
+	*p = Program{
+		ResolvedProgram: ast.ResolvedProgram{
+			Scalars:     scalars,
+			Arrays:      arrays,
+			NativeFuncs: compiled.NativeFuncs(),
+		},
+		Compiled: compiled,
+	}
+	return nil
+}
+
 // Parser state
 type parser struct {
 	// Lexer instance and current token values
@@ -131,12 +223,25 @@ type parser struct {
 
 	// Variable tracking and resolving
 	multiExprs map[*ast.MultiExpr]Position // tracks comma-separated expressions
+
+	// Handling of @include directives
+	config       *ParserConfig
+	lineOffset   int             // added to line numbers (non-zero in included files)
+	numLines     int             // number of lines in source and included files so far
+	included     map[string]bool // keys of files already included
+	includeStack []includeFile   // files currently being included
 }
 
 // Parse an entire AWK program.
 func (p *parser) program() *ast.Program {
 	prog := &ast.Program{}
+	p.items(prog)
+	p.checkMultiExprs()
+	return prog
+}
 
+// Parse top-level items till EOF, adding them to prog.
+func (p *parser) items(prog *ast.Program) {
 	// Terminator "(SEMICOLON|NEWLINE) NEWLINE*" is required after each item
 	// with two exceptions where it is optional:
 	//
@@ -169,6 +274,9 @@ func (p *parser) program() *ast.Program {
 		case FUNCTION:
 			function := p.function()
 			prog.Functions = append(prog.Functions, function)
+		case INCLUDE:
+			p.include(prog)
+			needsTerminator = true
 		default:
 			p.inAction = true
 			// Allow empty pattern, normal pattern, or range pattern
@@ -191,10 +299,6 @@ func (p *parser) program() *ast.Program {
 			p.inAction = false
 		}
 	}
-
-	p.checkMultiExprs()
-
-	return prog
 }
 
 // Parse a list of statements.
@@ -259,18 +363,14 @@ func (p *parser) simpleStmt() ast.Stmt {
 		}
 	case DELETE:
 		p.next()
-		ref := ast.ArrayRef(p.val, p.pos)
+		name := p.val
+		namePos := p.pos
 		p.expect(NAME)
-		var index []ast.Expr
 		if p.tok == LBRACKET {
-			p.next()
-			index = p.exprList(p.expr)
-			if len(index) == 0 {
-				panic(p.errorf("expected expression instead of ]"))
-			}
-			p.expect(RBRACKET)
+			expr := indexExpr(name, namePos, p.subscripts())
+			return &ast.DeleteStmt{expr.Array, expr.Index, startPos, p.pos}
 		}
-		return &ast.DeleteStmt{ref, index, startPos, p.pos}
+		return &ast.DeleteStmt{ast.ArrayRef(name, namePos), nil, startPos, p.pos}
 	case IF, FOR, WHILE, DO, BREAK, CONTINUE, NEXT, EXIT, RETURN:
 		panic(p.errorf("expected print/printf, delete, or expression"))
 	default:
@@ -527,16 +627,12 @@ func (p *parser) getLine() ast.Expr {
 //
 //	lvalue [assign_op assign]
 //
-// An lvalue is a variable name, an array[expr] index expression, or
-// an $expr field expression.
+// An lvalue is a variable name, an array[expr] index expression, an
+// $expr field expression, or an @expr named field expression.
 func (p *parser) _assign(higher func() ast.Expr) ast.Expr {
 	expr := higher()
-	_, isNamedField := expr.(*ast.NamedFieldExpr)
-	if (isNamedField || ast.IsLValue(expr)) && p.matches(ASSIGN, ADD_ASSIGN, DIV_ASSIGN,
+	if ast.IsLValue(expr) && p.matches(ASSIGN, ADD_ASSIGN, DIV_ASSIGN,
 		MOD_ASSIGN, MUL_ASSIGN, POW_ASSIGN, SUB_ASSIGN) {
-		if isNamedField {
-			panic(p.errorf("assigning @ expression not supported"))
-		}
 		op := p.tok
 		p.next()
 		right := p._assign(higher)
@@ -603,9 +699,7 @@ func (p *parser) _in(higher func() ast.Expr) ast.Expr {
 	expr := higher()
 	for p.tok == IN {
 		p.next()
-		ref := ast.ArrayRef(p.val, p.pos)
-		p.expect(NAME)
-		expr = &ast.InExpr{[]ast.Expr{expr}, ref}
+		expr = &ast.InExpr{[]ast.Expr{expr}, p.arrayRef()}
 	}
 	return expr
 }
@@ -690,7 +784,7 @@ func (p *parser) primary() ast.Expr {
 		s := strings.TrimRight(p.val, "eE")
 		n, _ := strconv.ParseFloat(s, 64)
 		p.next()
-		return &ast.NumExpr{n}
+		return &ast.NumExpr{Value: n, Text: s}
 	case STRING:
 		s := p.val
 		p.next()
@@ -724,14 +818,9 @@ func (p *parser) primary() ast.Expr {
 		namePos := p.pos
 		p.next()
 		if p.tok == LBRACKET {
-			// a[x] or a[x, y] array index expression
-			p.next()
-			index := p.exprList(p.expr)
-			if len(index) == 0 {
-				panic(p.errorf("expected expression instead of ]"))
-			}
-			p.expect(RBRACKET)
-			return &ast.IndexExpr{ast.ArrayRef(name, namePos), index}
+			// a[x] or a[x, y] array index expression, or a[x][y]
+			// subarray index expression
+			return indexExpr(name, namePos, p.subscripts())
 		} else if p.tok == LPAREN && !p.lexer.HadSpace() {
 			// Grammar requires no space between function name and
 			// left paren for user function calls, hence the funky
@@ -754,9 +843,7 @@ func (p *parser) primary() ast.Expr {
 			p.expect(RPAREN)
 			if p.tok == IN {
 				p.next()
-				ref := ast.ArrayRef(p.val, p.pos)
-				p.expect(NAME)
-				return &ast.InExpr{exprs, ref}
+				return &ast.InExpr{exprs, p.arrayRef()}
 			}
 			// MultiExpr is used as a pseudo-expression for print[f] parsing.
 			return p.multiExpr(exprs, parenPos)
@@ -793,6 +880,21 @@ func (p *parser) primary() ast.Expr {
 		}
 		p.expect(RPAREN)
 		return &ast.CallExpr{op, args}
+	case F_GENSUB:
+		p.next()
+		p.expect(LPAREN)
+		regex := p.regexStr(p.expr)
+		p.commaNewlines()
+		repl := p.expr()
+		p.commaNewlines()
+		how := p.expr()
+		args := []ast.Expr{regex, repl, how}
+		if p.tok == COMMA {
+			p.commaNewlines()
+			args = append(args, p.expr())
+		}
+		p.expect(RPAREN)
+		return &ast.CallExpr{F_GENSUB, args}
 	case F_SPLIT:
 		p.next()
 		p.expect(LPAREN)
@@ -804,22 +906,87 @@ func (p *parser) primary() ast.Expr {
 		if p.tok == COMMA {
 			p.commaNewlines()
 			args = append(args, p.regexStr(p.expr))
+			if p.tok == COMMA {
+				p.commaNewlines()
+				args = append(args, p.regexStr(p.expr))
+			}
 		}
 		p.expect(RPAREN)
 		return &ast.CallExpr{F_SPLIT, args}
+	case F_PRINTROW:
+		p.next()
+		p.expect(LPAREN)
+		ref := ast.ArrayRef(p.val, p.pos)
+		p.expect(NAME)
+		args := []ast.Expr{ref}
+		if p.tok == COMMA {
+			p.commaNewlines()
+			args = append(args, ast.ArrayRef(p.val, p.pos))
+			p.expect(NAME)
+		}
+		p.expect(RPAREN)
+		return &ast.CallExpr{F_PRINTROW, args}
+	case F_ASORT, F_ASORTI:
+		op := p.tok
+		p.next()
+		p.expect(LPAREN)
+		ref := ast.ArrayRef(p.val, p.pos)
+		p.expect(NAME)
+		args := []ast.Expr{ref}
+		if p.tok == COMMA {
+			p.commaNewlines()
+			args = append(args, ast.ArrayRef(p.val, p.pos))
+			p.expect(NAME)
+			if p.tok == COMMA {
+				p.commaNewlines()
+				args = append(args, p.expr())
+			}
+		}
+		p.expect(RPAREN)
+		return &ast.CallExpr{op, args}
 	case F_MATCH:
 		p.next()
 		p.expect(LPAREN)
 		str := p.expr()
 		p.commaNewlines()
 		regex := p.regexStr(p.expr)
+		args := []ast.Expr{str, regex}
+		if p.tok == COMMA {
+			p.commaNewlines()
+			args = append(args, ast.ArrayRef(p.val, p.pos))
+			p.expect(NAME)
+		}
+		p.expect(RPAREN)
+		return &ast.CallExpr{F_MATCH, args}
+	case F_RAND, F_SYSTIME:
+		op := p.tok
+		p.next()
+		p.expect(LPAREN)
 		p.expect(RPAREN)
-		return &ast.CallExpr{F_MATCH, []ast.Expr{str, regex}}
-	case F_RAND:
+		return &ast.CallExpr{op, nil}
+	case F_STRFTIME:
 		p.next()
 		p.expect(LPAREN)
+		var args []ast.Expr
+		if p.tok != RPAREN {
+			args = append(args, p.expr())
+			for len(args) < 3 && p.tok == COMMA {
+				p.commaNewlines()
+				args = append(args, p.expr())
+			}
+		}
+		p.expect(RPAREN)
+		return &ast.CallExpr{F_STRFTIME, args}
+	case F_MKTIME:
+		p.next()
+		p.expect(LPAREN)
+		args := []ast.Expr{p.expr()}
+		if p.tok == COMMA {
+			p.commaNewlines()
+			args = append(args, p.expr())
+		}
 		p.expect(RPAREN)
-		return &ast.CallExpr{F_RAND, nil}
+		return &ast.CallExpr{F_MKTIME, args}
 	case F_SRAND:
 		p.next()
 		p.expect(LPAREN)
@@ -873,7 +1040,21 @@ func (p *parser) primary() ast.Expr {
 		}
 		p.expect(RPAREN)
 		return &ast.CallExpr{F_FFLUSH, args}
-	case F_COS, F_SIN, F_EXP, F_LOG, F_SQRT, F_INT, F_TOLOWER, F_TOUPPER, F_SYSTEM, F_CLOSE:
+	case F_AND, F_OR, F_XOR:
+		// Bitwise functions that take two or more arguments
+		op := p.tok
+		p.next()
+		p.expect(LPAREN)
+		args := []ast.Expr{p.expr()}
+		p.commaNewlines()
+		args = append(args, p.expr())
+		for p.tok == COMMA {
+			p.commaNewlines()
+			args = append(args, p.expr())
+		}
+		p.expect(RPAREN)
+		return &ast.CallExpr{op, args}
+	case F_COS, F_SIN, F_EXP, F_LOG, F_SQRT, F_INT, F_TOLOWER, F_TOUPPER, F_SYSTEM, F_CLOSE, F_ISARRAY, F_COMPL:
 		// Simple 1-argument functions
 		op := p.tok
 		p.next()
@@ -881,7 +1062,7 @@ func (p *parser) primary() ast.Expr {
 		arg := p.expr()
 		p.expect(RPAREN)
 		return &ast.CallExpr{op, []ast.Expr{arg}}
-	case F_ATAN2, F_INDEX:
+	case F_ATAN2, F_INDEX, F_LSHIFT, F_RSHIFT:
 		// Simple 2-argument functions
 		op := p.tok
 		p.next()
@@ -908,24 +1089,58 @@ func (p *parser) optionalLValue() ast.Expr {
 		namePos := p.pos
 		p.next()
 		if p.tok == LBRACKET {
-			// a[x] or a[x, y] array index expression
-			p.next()
-			index := p.exprList(p.expr)
-			if len(index) == 0 {
-				panic(p.errorf("expected expression instead of ]"))
-			}
-			p.expect(RBRACKET)
-			return &ast.IndexExpr{ast.ArrayRef(name, namePos), index}
+			// a[x] or a[x, y] array index expression, or a[x][y]
+			// subarray index expression
+			return indexExpr(name, namePos, p.subscripts())
 		}
 		return ast.VarRef(name, namePos)
 	case DOLLAR:
 		p.next()
 		return &ast.FieldExpr{p.primary()}
+	case AT:
+		p.next()
+		return &ast.NamedFieldExpr{p.primary()}
 	default:
 		return nil
 	}
 }
 
+// Parse one or more array subscripts, like the [x] or [x, y] in a[x, y],
+// or the [x] and [y] in the subarray reference a[x][y].
+func (p *parser) subscripts() [][]ast.Expr {
+	var subscripts [][]ast.Expr
+	for p.tok == LBRACKET {
+		p.next()
+		index := p.exprList(p.expr)
+		if len(index) == 0 {
+			panic(p.errorf("expected expression instead of ]"))
+		}
+		p.expect(RBRACKET)
+		subscripts = append(subscripts, index)
+	}
+	return subscripts
+}
+
+// Return the index expression for array name with the given subscripts,
+// for example a[x][y] is the subarray a[x] indexed by y.
+func indexExpr(name string, pos Position, subscripts [][]ast.Expr) *ast.IndexExpr {
+	ref := ast.ArrayRef(name, pos)
+	last := len(subscripts) - 1
+	if last > 0 {
+		ref.Subscripts = subscripts[:last]
+	}
+	return &ast.IndexExpr{ref, subscripts[last]}
+}
+
+// Parse an array name, optionally followed by subscripts that refer to a
+// subarray (as in "k in a[x]").
+func (p *parser) arrayRef() *ast.ArrayExpr {
+	ref := ast.ArrayRef(p.val, p.pos)
+	p.expect(NAME)
+	ref.Subscripts = p.subscripts()
+	return ref
+}
+
 // Parse /.../ regex or generic expression:
 //
 //	REGEX | expr
@@ -976,6 +1191,7 @@ func (p *parser) optionalNewlines() {
 func (p *parser) next() {
 	p.prevTok = p.tok
 	p.pos, p.tok, p.val = p.lexer.Scan()
+	p.pos.Line += p.lineOffset
 	if p.tok == ILLEGAL {
 		panic(p.errorf("%s", p.val))
 	}
@@ -985,6 +1201,7 @@ func (p *parser) next() {
 // DIV_ASSIGN token).
 func (p *parser) nextRegex() string {
 	p.pos, p.tok, p.val = p.lexer.ScanRegex()
+	p.pos.Line += p.lineOffset
 	if p.tok == ILLEGAL {
 		panic(p.errorf("%s", p.val))
 	}
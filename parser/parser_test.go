@@ -5,10 +5,12 @@ package parser_test
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"strings"
 	"testing"
 
 	"github.com/nuvolaris/goawk/internal/ast"
+	"github.com/nuvolaris/goawk/internal/parseutil"
 	"github.com/nuvolaris/goawk/parser"
 )
 
@@ -40,6 +42,7 @@ $0 {
     print "x" >>"append"
     print "y" |"prog"
     delete a[k]
+    delete a[k][j]
     if (c) {
         get(a, k)
     }
@@ -55,6 +58,9 @@ $0 {
     for (k in a) {
         break
     }
+    for (j in a[k]) {
+        break
+    }
     while (0) {
         print "x"
     }
@@ -89,6 +95,7 @@ $0 {
     ((b && c) || d)
     (k in a)
     ((x, y, z) in a)
+    (j in a[k])
     (s ~ "foo")
     (b < 1)
     (c <= 2)
@@ -120,16 +127,42 @@ $0 {
     var
     a[key]
     a[x, y, z]
+    a[key][x, y][z]
     f()
     set(a, k, v)
     sub(regex, repl)
     sub(regex, repl, s)
     gsub(regex, repl)
     gsub(regex, repl, s)
+    gensub(regex, repl, "g")
+    gensub(regex, repl, 2, s)
     split(s, a)
     split(s, a, regex)
+    split(s, a, regex, regex)
     match(s, regex)
+    match(s, regex, a)
+    printrow(a)
+    printrow(a, fields)
+    asort(a)
+    asort(a, sorted)
+    asort(a, sorted, "@val_num_desc")
+    asorti(a)
+    asorti(a, sorted, "cmp")
+    isarray(a)
+    isarray(a[k])
+    and(x, 255)
+    or(x, y, z)
+    xor(x, 1)
+    lshift(x, 2)
+    rshift(x, 2)
+    compl(x)
     rand()
+    systime()
+    strftime()
+    strftime("%Y")
+    strftime("%Y", t, 1)
+    mktime("2022 1 2 3 4 5")
+    mktime(s, 1)
     srand()
     srand(1)
     length()
@@ -342,3 +375,95 @@ func Example_error() {
 	// Output:
 	// parse error at 1:7: expected ( instead of if
 }
+
+// Simple in-memory IncludeFS for testing @include
+type mapFS map[string]string
+
+func (m mapFS) ReadFile(name string) ([]byte, error) {
+	src, ok := m[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return []byte(src), nil
+}
+
+func TestInclude(t *testing.T) {
+	files := mapFS{
+		"lib/math.awk":  "function double(x) {\n\treturn x * 2\n}\n@include \"util\"\n",
+		"lib/util.awk":  "function util() { return 1 }",
+		"lib/bad.awk":   "\n\nfunction bad( {\n",
+		"lib/cycle.awk": "@include \"cycle2\"\n",
+		"lib/cycle2.awk": "function c() {}\n" +
+			"@include \"cycle\"\n",
+		"local.awk": "BEGIN { print \"local\" }\n",
+	}
+	tests := []struct {
+		src     string
+		paths   []string
+		program string
+		err     string
+		errFile string
+		errLine int
+	}{
+		{`@include "local.awk"`, nil, "BEGIN {\n    print \"local\"\n}", "", "", 0},
+		{"@include \"./local\"; BEGIN { print double(1) }\n@include \"math\"\n@include \"lib/math.awk\"", []string{"lib"},
+			"BEGIN {\n    print \"local\"\n}\n\nBEGIN {\n    print double(1)\n}\n\n" +
+				"function double(x) {\n    return (x * 2)\n}\n\nfunction util() {\n    return 1\n}", "", "", 0},
+		{`@include "math"`, nil, "", `parse error at 1:1: can't find @include file "math"`, "<main>", 1},
+		{`@include "nosuch"`, []string{"lib"}, "", `parse error at 1:1: can't find @include file "nosuch"`, "<main>", 1},
+		{"BEGIN {}\n@include \"bad\"", []string{"lib"}, "", "parse error at 5:15: expected name instead of {", "lib/bad.awk", 3},
+		{`@include "cycle"`, []string{"lib"}, "", "parse error at 4:1: @include cycle: lib/cycle.awk -> lib/cycle2.awk -> lib/cycle.awk", "lib/cycle2.awk", 2},
+		{`@include x`, nil, "", "parse error at 1:10: expected file name string after @include", "<main>", 1},
+		{`@include "local" BEGIN {}`, nil, "", "parse error at 1:18: expected ; or newline between items", "<main>", 1},
+		{`BEGIN { @include "local" }`, nil, "", "parse error at 1:9: expected expression instead of @include", "<main>", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			fileReader := &parseutil.FileReader{}
+			err := fileReader.AddFile("<main>", strings.NewReader(test.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			config := &parser.ParserConfig{
+				IncludePaths:   test.paths,
+				IncludeFS:      files,
+				IncludeSources: fileReader,
+			}
+			prog, err := parser.ParseProgram(fileReader.Source(), config)
+			if test.err != "" {
+				if err == nil {
+					t.Fatalf("expected error %q, got none", test.err)
+				}
+				if err.Error() != test.err {
+					t.Fatalf("expected error %q, got %q", test.err, err.Error())
+				}
+				path, line := fileReader.FileLine(err.(*parser.ParseError).Position.Line)
+				if path != test.errFile || line != test.errLine {
+					t.Fatalf("expected error in %s:%d, got %s:%d", test.errFile, test.errLine, path, line)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error parsing program: %v", err)
+			}
+			progStr := strings.TrimSpace(prog.String())
+			if progStr != test.program {
+				t.Fatalf("expected first, got second:\n%s\n----------\n%s", test.program, progStr)
+			}
+		})
+	}
+}
+
+func TestIncludeOSFiles(t *testing.T) {
+	src := []byte(`@include "../testdata/parseerror/good.awk"`)
+	_, err := parser.ParseProgram(src, nil)
+	expected := `parse error at 1:1: can't @include "../testdata/parseerror/good.awk": IncludeFS or IncludeOSFiles not set`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected error %q, got %v", expected, err)
+	}
+
+	_, err = parser.ParseProgram(src, &parser.ParserConfig{IncludeOSFiles: true})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}